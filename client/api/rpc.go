@@ -32,6 +32,17 @@ func (s *rpcServer) Create(ctx context.Context, in *pb.CreateRequest) (*pb.Creat
 		return nil, fmt.Errorf("image manifest is not valid: %v", err)
 	}
 
+	// validate the manifests for any additional apps in the pod
+	for _, app := range in.Apps {
+		var appManifest *schema.ImageManifest
+		if err := json.Unmarshal(app.Manifest, &appManifest); err != nil {
+			return nil, fmt.Errorf("invalid image manifest for app %q: %v", app.Name, err)
+		}
+		if err := validateImageManifest(appManifest); err != nil {
+			return nil, fmt.Errorf("image manifest for app %q is not valid: %v", app.Name, err)
+		}
+	}
+
 	// send the request to the backend
 	return s.client.Create(ctx, in)
 }
@@ -87,3 +98,107 @@ func (s *rpcServer) Get(ctx context.Context, in *pb.ContainerRequest) (*pb.Conta
 	s.log.Debug("Received container get request for %s", in.Uuid)
 	return s.client.Get(ctx, in)
 }
+
+func (s *rpcServer) Watch(in *pb.ContainerRequest, outStream pb.Kurma_WatchServer) error {
+	s.log.Debugf("Received container watch request for %q", in.Uuid)
+
+	inStream, err := s.client.Watch(outStream.Context(), in)
+	if err != nil {
+		return err
+	}
+
+	for {
+		event, err := inStream.Recv()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if err := outStream.Send(event); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *rpcServer) Logs(in *pb.LogsRequest, outStream pb.Kurma_LogsServer) error {
+	s.log.Debugf("Received container logs request for %q", in.Uuid)
+
+	inStream, err := s.client.Logs(outStream.Context(), in)
+	if err != nil {
+		return err
+	}
+
+	for {
+		line, err := inStream.Recv()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if err := outStream.Send(line); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *rpcServer) Exec(inStream pb.Kurma_ExecServer) error {
+	s.log.Debug("Received exec request")
+
+	outStream, err := s.client.Exec(inStream.Context())
+	if err != nil {
+		return err
+	}
+
+	// forward the request and stdin to the backend
+	go func() {
+		for {
+			req, err := inStream.Recv()
+			if err != nil {
+				break
+			}
+			if err := outStream.Send(req); err != nil {
+				break
+			}
+		}
+		outStream.CloseSend()
+	}()
+
+	// and the output back to the client
+	for {
+		resp, err := outStream.Recv()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if err := inStream.Send(resp); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *rpcServer) Stats(ctx context.Context, in *pb.StatsRequest) (*pb.StatsResponse, error) {
+	s.log.Debugf("Received container stats request for %q", in.Uuid)
+	return s.client.Stats(ctx, in)
+}
+
+func (s *rpcServer) StreamStats(in *pb.StatsRequest, outStream pb.Kurma_StreamStatsServer) error {
+	s.log.Debugf("Received container stats stream request for %q", in.Uuid)
+
+	inStream, err := s.client.StreamStats(outStream.Context(), in)
+	if err != nil {
+		return err
+	}
+
+	for {
+		resp, err := inStream.Recv()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if err := outStream.Send(resp); err != nil {
+			return err
+		}
+	}
+}
@@ -11,8 +11,8 @@ import (
 func (s *rpcServer) Enter(inStream pb.Kurma_EnterServer) error {
 	s.log.Debug("Received enter request")
 
-	// read the first chunk to make sure its real and get the container ID
-	chunk, err := inStream.Recv()
+	// read the first request to make sure its real and get the container ID
+	req, err := inStream.Recv()
 	if err != nil {
 		return err
 	}
@@ -23,22 +23,28 @@ func (s *rpcServer) Enter(inStream pb.Kurma_EnterServer) error {
 		return err
 	}
 
-	// Create our inbound streams
-	inWriter := pb.NewByteStreamWriter(inStream, chunk.StreamId)
-	inReader := pb.NewByteStreamReader(inStream, nil)
-
-	// Create our outbound streams
-	outWriter := pb.NewByteStreamWriter(outStream, chunk.StreamId)
-	outReader := pb.NewByteStreamReader(outStream, nil)
-
-	// write the first byte to the backend so it is initialized
-	if _, err := outWriter.Write(nil); err != nil {
+	// send the first request to the backend so it is initialized
+	if err := outStream.Send(req); err != nil {
 		return err
 	}
 
-	// stream between!
-	go io.Copy(outWriter, inReader)
+	// forward the input and control messages to the backend
+	go func() {
+		for {
+			req, err := inStream.Recv()
+			if err != nil {
+				break
+			}
+			if err := outStream.Send(req); err != nil {
+				break
+			}
+		}
+		outStream.CloseSend()
+	}()
+
+	// and stream the output back
+	inWriter := pb.NewByteStreamWriter(inStream, req.Uuid)
+	outReader := pb.NewByteStreamReader(outStream, nil)
 	io.Copy(inWriter, outReader)
-	outStream.CloseSend()
 	return nil
 }
@@ -5,7 +5,10 @@ package commands
 import (
 	_ "github.com/apcera/kurma/client/cli/commands/create"
 	_ "github.com/apcera/kurma/client/cli/commands/enter"
+	_ "github.com/apcera/kurma/client/cli/commands/exec"
 	_ "github.com/apcera/kurma/client/cli/commands/list"
+	_ "github.com/apcera/kurma/client/cli/commands/logs"
 	_ "github.com/apcera/kurma/client/cli/commands/show"
+	_ "github.com/apcera/kurma/client/cli/commands/stats"
 	_ "github.com/apcera/kurma/client/cli/commands/stop"
 )
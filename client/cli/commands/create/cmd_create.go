@@ -3,16 +3,20 @@
 package create
 
 import (
+	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/apcera/kurma/client/cli"
 	"github.com/apcera/util/tarhelper"
+	"github.com/appc/spec/schema/types"
 
 	pb "github.com/apcera/kurma/stage1/client"
+	"github.com/apcera/kurma/stage1/image"
 	"golang.org/x/net/context"
 )
 
@@ -20,56 +24,142 @@ func init() {
 	cli.DefineCommand("create", parseFlags, create, cliCreate, "FIXME")
 }
 
+var (
+	volumes volumeList
+)
+
+// volumeList is a flag value which accumulates each volume given on the
+// command line, in the form "name,kind=host,source=/path,readOnly=true".
+type volumeList []types.Volume
+
+func (l *volumeList) String() string {
+	names := make([]string, len(*l))
+	for i, v := range *l {
+		names[i] = v.Name.String()
+	}
+	return strings.Join(names, ",")
+}
+
+func (l *volumeList) Set(s string) error {
+	v, err := types.VolumeFromString(s)
+	if err != nil {
+		return err
+	}
+	*l = append(*l, *v)
+	return nil
+}
+
 func parseFlags(cmd *cli.Cmd) {
+	cmd.Flags.Var(&volumes, "volume", "")
 }
 
 func cliCreate(cmd *cli.Cmd) error {
-	if len(cmd.Args) == 0 || len(cmd.Args) > 1 {
+	if len(cmd.Args) == 0 {
 		return fmt.Errorf("Invalid command options specified.")
 	}
 	return cmd.Run()
 }
 
 func create(cmd *cli.Cmd) error {
-	// open the file
-	f, err := os.Open(cmd.Args[0])
-	if err != nil {
-		return err
-	}
-	defer f.Close()
+	// open each of the files, the first is the primary app of the pod and any
+	// others are run alongside it
+	files := make([]*os.File, len(cmd.Args))
+	manifests := make([][]byte, len(cmd.Args))
+	imageIds := make([]string, len(cmd.Args))
+	signatures := make([][]byte, len(cmd.Args))
+	for i, path := range cmd.Args {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		files[i] = f
 
-	// find the manifest file, then rewind
-	manifest, err := findManifest(f)
-	if err != nil {
-		return err
-	}
-	if _, err := f.Seek(0, 0); err != nil {
-		return err
+		// find the manifest file, then rewind
+		manifest, err := findManifest(f)
+		if err != nil {
+			return err
+		}
+		if _, err := f.Seek(0, 0); err != nil {
+			return err
+		}
+		manifests[i] = manifest
+
+		// calculate the image ID so the upload can be skipped if the host
+		// already has the image, then rewind
+		imageIds[i], err = image.ImageID(f)
+		if err != nil {
+			return err
+		}
+		if _, err := f.Seek(0, 0); err != nil {
+			return err
+		}
+
+		// include the detached signature if one is next to the image
+		signature, err := ioutil.ReadFile(path + ".asc")
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		signatures[i] = signature
 	}
 
 	req := &pb.CreateRequest{
-		Manifest: manifest,
+		Manifest:  manifests[0],
+		ImageId:   imageIds[0],
+		Signature: signatures[0],
+	}
+	for i, manifest := range manifests[1:] {
+		req.Apps = append(req.Apps, &pb.AppRequest{
+			Manifest:  manifest,
+			ImageId:   imageIds[i+1],
+			Signature: signatures[i+1],
+		})
+	}
+	if len(volumes) > 0 {
+		b, err := json.Marshal(volumes)
+		if err != nil {
+			return err
+		}
+		req.Volumes = b
 	}
 
-	// trigger container creation then upload the ACI image
+	// trigger container creation then upload the ACI images
 	resp, err := cmd.Client.Create(context.Background(), req)
 	if err != nil {
 		return err
 	}
+	if len(resp.AppUploadIds) != len(req.Apps) {
+		return fmt.Errorf("expected %d app upload IDs, received %d", len(req.Apps), len(resp.AppUploadIds))
+	}
+
+	uploadIds := append([]string{resp.ImageUploadId}, resp.AppUploadIds...)
+	for i, f := range files {
+		// a blank upload ID means the image is already stored on the host
+		if uploadIds[i] == "" {
+			continue
+		}
+		if err := uploadImage(cmd, uploadIds[i], f); err != nil {
+			return err
+		}
+	}
+
+	// fmt.Printf("Launched container %s\n", resp.Uuid)
+	return nil
+}
+
+func uploadImage(cmd *cli.Cmd, uploadId string, f *os.File) error {
 	stream, err := cmd.Client.UploadImage(context.Background())
 	if err != nil {
 		return err
 	}
 
-	w := pb.NewByteStreamWriter(stream, resp.ImageUploadId)
+	w := pb.NewByteStreamWriter(stream, uploadId)
 	if _, err := io.Copy(w, f); err != nil {
 		return fmt.Errorf("write error: %v", err)
 	}
 	if _, err := stream.CloseAndRecv(); err != nil {
 		return err
 	}
-
-	// fmt.Printf("Launched container %s\n", resp.Uuid)
 	return nil
 }
 
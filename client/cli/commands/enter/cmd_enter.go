@@ -6,9 +6,13 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
 
 	"github.com/apcera/kurma/client/cli"
 	"github.com/creack/termios/raw"
+	"github.com/kr/pty"
 
 	pb "github.com/apcera/kurma/stage1/client"
 	"golang.org/x/net/context"
@@ -18,7 +22,35 @@ func init() {
 	cli.DefineCommand("enter", parseFlags, enter, cliEnter, "FIXME")
 }
 
+var (
+	app         string
+	environment envList
+	user        string
+	group       string
+)
+
+// envList is a flag value which accumulates each environment variable given on
+// the command line, in the form "KEY=VALUE".
+type envList []string
+
+func (l *envList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *envList) Set(s string) error {
+	if !strings.Contains(s, "=") {
+		return fmt.Errorf("environment variables must be in the form KEY=VALUE")
+	}
+	*l = append(*l, s)
+	return nil
+}
+
 func parseFlags(cmd *cli.Cmd) {
+	cmd.Flags.StringVar(&app, "app", "", "")
+	cmd.Flags.Var(&environment, "env", "")
+	cmd.Flags.Var(&environment, "e", "")
+	cmd.Flags.StringVar(&user, "user", "", "")
+	cmd.Flags.StringVar(&group, "group", "", "")
 }
 
 func cliEnter(cmd *cli.Cmd) error {
@@ -37,20 +69,84 @@ func enter(cmd *cli.Cmd) error {
 	}
 	defer raw.TcSetAttr(os.Stdin.Fd(), termios)
 
-	// Initialize the call and send the first packet so that it knows what
-	// container we're connecting to.
+	// Initialize the call and send the first request so that it knows what
+	// container we're connecting to, what to run, and the size of the terminal.
+	// Without a command, the user's shell is run.
 	stream, err := cmd.Client.Enter(context.Background())
 	if err != nil {
 		return err
 	}
-	w := pb.NewByteStreamWriter(stream, cmd.Args[0])
-	r := pb.NewByteStreamReader(stream, nil)
-	if _, err := w.Write(nil); err != nil {
+	err = stream.Send(&pb.EnterRequest{
+		Uuid:        cmd.Args[0],
+		App:         app,
+		Command:     cmd.RawSubFlags,
+		Environment: environment,
+		User:        user,
+		Group:       group,
+		WindowSize:  windowSize(),
+	})
+	if err != nil {
 		return err
 	}
 
-	go io.Copy(w, os.Stdin)
+	// Relay the terminal's input along with any changes to its size and any
+	// signals received. These are all sent from one goroutine since sends on
+	// the stream can't be made concurrently.
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGWINCH, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP, syscall.SIGQUIT)
+	defer signal.Stop(signals)
+	input := make(chan []byte)
+	go readInput(input)
+	go func() {
+		for {
+			var req *pb.EnterRequest
+			select {
+			case b, ok := <-input:
+				if !ok {
+					stream.CloseSend()
+					return
+				}
+				req = &pb.EnterRequest{Stdin: b}
+			case sig := <-signals:
+				if sig == syscall.SIGWINCH {
+					req = &pb.EnterRequest{WindowSize: windowSize()}
+				} else {
+					req = &pb.EnterRequest{Signal: int32(sig.(syscall.Signal))}
+				}
+			}
+			if err := stream.Send(req); err != nil {
+				return
+			}
+		}
+	}()
+
+	r := pb.NewByteStreamReader(stream, nil)
 	io.Copy(os.Stdout, r)
-	stream.CloseSend()
 	return nil
 }
+
+// readInput sends the data read from stdin on the channel, closing it once
+// stdin is exhausted.
+func readInput(ch chan<- []byte) {
+	defer close(ch)
+	for {
+		buf := make([]byte, 1024)
+		n, err := os.Stdin.Read(buf)
+		if n > 0 {
+			ch <- buf[:n]
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// windowSize returns the current size of the local terminal, or nil if it
+// can't be determined.
+func windowSize() *pb.WindowSize {
+	rows, columns, err := pty.Getsize(os.Stdin)
+	if err != nil {
+		return nil
+	}
+	return &pb.WindowSize{Rows: uint32(rows), Columns: uint32(columns)}
+}
@@ -0,0 +1,142 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package exec
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/apcera/kurma/client/cli"
+	"github.com/apcera/util/str"
+	"github.com/creack/termios/raw"
+
+	pb "github.com/apcera/kurma/stage1/client"
+	"golang.org/x/net/context"
+)
+
+func init() {
+	cli.DefineCommand("exec", parseFlags, exec, cliExec, "FIXME")
+}
+
+var (
+	app              string
+	environment      envList
+	workingDirectory string
+	user             string
+	group            string
+	tty              bool
+)
+
+// envList is a flag value which accumulates each environment variable given on
+// the command line, in the form "KEY=VALUE".
+type envList []string
+
+func (l *envList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *envList) Set(s string) error {
+	if !strings.Contains(s, "=") {
+		return fmt.Errorf("environment variables must be in the form KEY=VALUE")
+	}
+	*l = append(*l, s)
+	return nil
+}
+
+func parseFlags(cmd *cli.Cmd) {
+	cmd.Flags.StringVar(&app, "app", "", "")
+	cmd.Flags.Var(&environment, "env", "")
+	cmd.Flags.Var(&environment, "e", "")
+	cmd.Flags.StringVar(&workingDirectory, "workdir", "", "")
+	cmd.Flags.StringVar(&user, "user", "", "")
+	cmd.Flags.StringVar(&group, "group", "", "")
+	cmd.Flags.BoolVar(&tty, "tty", false, "")
+	cmd.Flags.BoolVar(&tty, "t", false, "")
+}
+
+func cliExec(cmd *cli.Cmd) error {
+	if len(cmd.Args) != 1 || len(cmd.RawSubFlags) == 0 {
+		return fmt.Errorf("Invalid command options specified.")
+	}
+	return cmd.Run()
+}
+
+func exec(cmd *cli.Cmd) error {
+	exitCode, err := run(cmd)
+	if err != nil {
+		return err
+	}
+	if exitCode != 0 {
+		os.Exit(exitCode)
+	}
+	return nil
+}
+
+// run executes the command within the container and returns its exit code.
+func run(cmd *cli.Cmd) (int, error) {
+	// With a tty, set the local terminal in raw mode to turn off buffering and
+	// local echo. Also defers setting it back to normal for when the call is
+	// done.
+	if tty && str.IsTerminal(os.Stdin) {
+		termios, err := raw.MakeRaw(os.Stdin.Fd())
+		if err != nil {
+			return 0, err
+		}
+		defer raw.TcSetAttr(os.Stdin.Fd(), termios)
+	}
+
+	stream, err := cmd.Client.Exec(context.Background())
+	if err != nil {
+		return 0, err
+	}
+	err = stream.Send(&pb.ExecRequest{
+		Uuid:             cmd.Args[0],
+		App:              app,
+		Command:          cmd.RawSubFlags,
+		Environment:      environment,
+		WorkingDirectory: workingDirectory,
+		User:             user,
+		Group:            group,
+		Tty:              tty,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	// send stdin, closing our side of the stream once it is exhausted
+	go func() {
+		buf := make([]byte, 32*1024)
+		for {
+			n, err := os.Stdin.Read(buf)
+			if n > 0 {
+				if stream.Send(&pb.ExecRequest{Stdin: buf[:n]}) != nil {
+					return
+				}
+			}
+			if err != nil {
+				stream.CloseSend()
+				return
+			}
+		}
+	}()
+
+	for {
+		resp, err := stream.Recv()
+		if err == io.EOF {
+			return 0, fmt.Errorf("the stream ended before the process exited")
+		} else if err != nil {
+			return 0, err
+		}
+		if len(resp.Stdout) > 0 {
+			os.Stdout.Write(resp.Stdout)
+		}
+		if len(resp.Stderr) > 0 {
+			os.Stderr.Write(resp.Stderr)
+		}
+		if resp.Exited {
+			return int(resp.ExitCode), nil
+		}
+	}
+}
@@ -5,6 +5,7 @@ package list
 import (
 	"encoding/json"
 	"fmt"
+	"strings"
 
 	"github.com/apcera/kurma/client/cli"
 	"github.com/apcera/termtables"
@@ -37,7 +38,7 @@ func list(cmd *cli.Cmd) error {
 	// create the table
 	table := termtables.CreateTable()
 
-	table.AddHeaders("UUID", "Name", "State")
+	table.AddHeaders("UUID", "Name", "State", "Exit Status")
 
 	for _, container := range resp.Containers {
 		var pod *schema.PodManifest
@@ -49,7 +50,16 @@ func list(cmd *cli.Cmd) error {
 			appName = app.Name.String()
 			break
 		}
-		table.AddRow(container.Uuid, appName, container.State.String())
+		var exitStatuses []string
+		if container.StartupError != "" {
+			exitStatuses = append(exitStatuses, container.StartupError)
+		}
+		for _, status := range container.AppStatuses {
+			if !status.Running {
+				exitStatuses = append(exitStatuses, status.Description())
+			}
+		}
+		table.AddRow(container.Uuid, appName, container.State.String(), strings.Join(exitStatuses, ", "))
 	}
 	fmt.Printf("%s", table.Render())
 	return nil
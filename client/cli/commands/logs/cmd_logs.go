@@ -0,0 +1,97 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package logs
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/apcera/kurma/client/cli"
+
+	pb "github.com/apcera/kurma/stage1/client"
+	"golang.org/x/net/context"
+)
+
+func init() {
+	cli.DefineCommand("logs", parseFlags, logs, cliLogs, "FIXME")
+}
+
+var (
+	app        string
+	stdout     bool
+	stderr     bool
+	stage2     bool
+	tail       int
+	follow     bool
+	timestamps bool
+)
+
+func parseFlags(cmd *cli.Cmd) {
+	cmd.Flags.StringVar(&app, "app", "", "")
+	cmd.Flags.BoolVar(&stdout, "stdout", false, "")
+	cmd.Flags.BoolVar(&stderr, "stderr", false, "")
+	cmd.Flags.BoolVar(&stage2, "stage2", false, "")
+	cmd.Flags.IntVar(&tail, "tail", 0, "")
+	cmd.Flags.IntVar(&tail, "n", 0, "")
+	cmd.Flags.BoolVar(&follow, "follow", false, "")
+	cmd.Flags.BoolVar(&follow, "f", false, "")
+	cmd.Flags.BoolVar(&timestamps, "timestamps", false, "")
+	cmd.Flags.BoolVar(&timestamps, "t", false, "")
+}
+
+func cliLogs(cmd *cli.Cmd) error {
+	if len(cmd.Args) == 0 || len(cmd.Args) > 1 {
+		return fmt.Errorf("Invalid command options specified.")
+	}
+	if stage2 && (stdout || stderr || app != "") {
+		return fmt.Errorf("The stage2 log can't be combined with app logs.")
+	}
+	return cmd.Run()
+}
+
+func logs(cmd *cli.Cmd) error {
+	req := &pb.LogsRequest{
+		Uuid:       cmd.Args[0],
+		App:        app,
+		Tail:       int32(tail),
+		Follow:     follow,
+		Timestamps: timestamps,
+	}
+	switch {
+	case stage2:
+		req.Stream = pb.LogsRequest_STAGE2
+	case stdout && !stderr:
+		req.Stream = pb.LogsRequest_STDOUT
+	case stderr && !stdout:
+		req.Stream = pb.LogsRequest_STDERR
+	default:
+		req.Stream = pb.LogsRequest_ALL
+	}
+
+	stream, err := cmd.Client.Logs(context.Background(), req)
+	if err != nil {
+		return err
+	}
+
+	for {
+		line, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		w := os.Stdout
+		if line.Stream == pb.LogsRequest_STDERR {
+			w = os.Stderr
+		}
+		if timestamps {
+			ts := time.Unix(0, line.Timestamp).Format(time.RFC3339Nano)
+			fmt.Fprintf(w, "%s %s\n", ts, line.Line)
+		} else {
+			fmt.Fprintf(w, "%s\n", line.Line)
+		}
+	}
+}
@@ -36,6 +36,21 @@ func show(cmd *cli.Cmd) error {
 	}
 
 	fmt.Printf("Container %s:\n\n", resp.Uuid)
+	fmt.Printf("State: %s\n", resp.State.String())
+	if resp.StartupError != "" {
+		fmt.Printf("Error: %s\n", resp.StartupError)
+	}
+	fmt.Printf("Restarts: %d\n", resp.RestartCount)
+	if resp.OomKills > 0 {
+		fmt.Printf("OOM Kills: %d\n", resp.OomKills)
+	}
+	if len(resp.AppStatuses) > 0 {
+		fmt.Printf("Apps:\n")
+		for _, status := range resp.AppStatuses {
+			fmt.Printf("  %s: %s\n", status.Name, status.Description())
+		}
+	}
+	fmt.Printf("\n")
 
 	// convert the manifest to the object
 	var pod *schema.PodManifest
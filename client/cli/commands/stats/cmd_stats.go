@@ -0,0 +1,127 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package stats
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/apcera/kurma/client/cli"
+	"github.com/apcera/termtables"
+
+	pb "github.com/apcera/kurma/stage1/client"
+	"golang.org/x/net/context"
+)
+
+func init() {
+	cli.DefineCommand("stats", parseFlags, stats, cliStats, "FIXME")
+}
+
+var (
+	noStream bool
+	interval int
+)
+
+func parseFlags(cmd *cli.Cmd) {
+	cmd.Flags.BoolVar(&noStream, "no-stream", false, "")
+	cmd.Flags.IntVar(&interval, "interval", 1, "")
+}
+
+func cliStats(cmd *cli.Cmd) error {
+	if len(cmd.Args) > 1 || interval < 1 {
+		return fmt.Errorf("Invalid command options specified.")
+	}
+	return cmd.Run()
+}
+
+func stats(cmd *cli.Cmd) error {
+	req := &pb.StatsRequest{Interval: int32(interval)}
+	if len(cmd.Args) == 1 {
+		req.Uuid = cmd.Args[0]
+	}
+
+	if noStream {
+		resp, err := cmd.Client.Stats(context.Background(), req)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s", render(resp, nil))
+		return nil
+	}
+
+	stream, err := cmd.Client.StreamStats(context.Background(), req)
+	if err != nil {
+		return err
+	}
+
+	// Redraw the table with each sample. The previous sample is kept so that the
+	// CPU utilization over the interval can be calculated.
+	var previous *pb.StatsResponse
+	for {
+		resp, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		fmt.Printf("\033[2J\033[H%s", render(resp, previous))
+		previous = resp
+	}
+}
+
+// render creates the table of the containers' resource usage. The CPU
+// utilization is only included when there is a previous sample to compare
+// against.
+func render(resp, previous *pb.StatsResponse) string {
+	table := termtables.CreateTable()
+	table.AddHeaders("UUID", "Name", "CPU Time", "CPU %", "Memory", "Disk", "Tasks")
+
+	for _, c := range resp.Containers {
+		cpu := "-"
+		if prev := findStats(previous, c.Uuid); prev != nil && resp.Timestamp > previous.Timestamp {
+			elapsed := float64(resp.Timestamp - previous.Timestamp)
+			cpu = fmt.Sprintf("%.1f%%", float64(c.CpuTime-prev.CpuTime)/elapsed*100)
+		}
+
+		memory := formatBytes(c.MemoryUsed)
+		if c.MemoryLimit > 0 {
+			memory = fmt.Sprintf("%s / %s", memory, formatBytes(c.MemoryLimit))
+		}
+
+		table.AddRow(
+			c.Uuid, c.Name,
+			time.Duration(c.CpuTime).String(), cpu,
+			memory, formatBytes(c.DiskUsed), c.Tasks)
+	}
+	return table.Render()
+}
+
+// findStats returns the stats for the container within the sample, or nil if
+// it isn't present.
+func findStats(resp *pb.StatsResponse, uuid string) *pb.ContainerStats {
+	if resp == nil {
+		return nil
+	}
+	for _, c := range resp.Containers {
+		if c.Uuid == uuid {
+			return c
+		}
+	}
+	return nil
+}
+
+// formatBytes returns the number of bytes in a human readable form using binary
+// units.
+func formatBytes(b int64) string {
+	const unit = 1024
+	if b < unit {
+		return fmt.Sprintf("%d B", b)
+	}
+	div, exp := int64(unit), 0
+	for n := b / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(b)/float64(div), "KMGTPE"[exp])
+}
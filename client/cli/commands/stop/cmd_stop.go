@@ -15,7 +15,13 @@ func init() {
 	cli.DefineCommand("stop", parseFlags, stop, cliStop, "FIXME")
 }
 
+var (
+	gracePeriod int
+)
+
 func parseFlags(cmd *cli.Cmd) {
+	cmd.Flags.IntVar(&gracePeriod, "grace-period", 0, "")
+	cmd.Flags.IntVar(&gracePeriod, "t", 0, "")
 }
 
 func cliStop(cmd *cli.Cmd) error {
@@ -26,7 +32,10 @@ func cliStop(cmd *cli.Cmd) error {
 }
 
 func stop(cmd *cli.Cmd) error {
-	req := &pb.ContainerRequest{Uuid: cmd.Args[0]}
+	req := &pb.ContainerRequest{
+		Uuid:        cmd.Args[0],
+		GracePeriod: int32(gracePeriod),
+	}
 
 	if _, err := cmd.Client.Destroy(context.Background(), req); err != nil {
 		return err
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net"
+	"net/url"
 	"os"
 	"os/exec"
 	"os/signal"
@@ -15,11 +16,14 @@ import (
 	"syscall"
 
 	"github.com/apcera/kurma/stage1/container"
+	"github.com/apcera/kurma/stage1/image"
 	"github.com/apcera/kurma/stage1/server"
 	"github.com/apcera/kurma/util"
 	"github.com/apcera/logray"
 	"github.com/apcera/util/aciremote"
 	"github.com/apcera/util/proc"
+	"github.com/appc/spec/discovery"
+	"github.com/appc/spec/schema/types"
 	"github.com/vishvananda/netlink"
 )
 
@@ -278,8 +282,21 @@ func (r *runner) mountDisks() error {
 	return nil
 }
 
+// recoverPods has the container manager recover the pods remaining from a
+// previous run of the daemon. This is done once the disks are mounted and the
+// storage driver is configured, since the pods and their root filesystems are
+// kept on the disks.
+func (r *runner) recoverPods() error {
+	if err := r.manager.Recover(); err != nil {
+		return fmt.Errorf("failed to recover existing pods: %v", err)
+	}
+	return nil
+}
+
 // cleanOldPods removes the directories for any pods remaining from a previous
-// run. If the host is booting up, those pods are obviously dead and stale.
+// run. If the host is booting up, those pods are obviously dead and stale. Pods
+// which were recovered by the container manager are left in place, as are the
+// files the manager keeps alongside the pods.
 func (r *runner) cleanOldPods() error {
 	podsPath := filepath.Join(kurmaPath, string(kurmaPathPods))
 	fis, err := ioutil.ReadDir(podsPath)
@@ -288,7 +305,15 @@ func (r *runner) cleanOldPods() error {
 		return nil
 	}
 
+	recovered := make(map[string]bool)
+	for _, c := range r.manager.Containers() {
+		recovered[c.ShortName()] = true
+	}
+
 	for _, fi := range fis {
+		if !fi.IsDir() || recovered[fi.Name()] {
+			continue
+		}
 		if err := os.RemoveAll(filepath.Join(podsPath, fi.Name())); err != nil {
 			r.log.Errorf("failed to cleanup existing pods: %v", err)
 		}
@@ -392,11 +417,13 @@ func (r *runner) configureNetwork() error {
 	return nil
 }
 
-// createDirectories ensures the specified storage paths for pods and volumes
-// exist.
+// createDirectories ensures the specified storage paths for pods, volumes, and
+// images exist.
 func (r *runner) createDirectories() error {
 	podsPath := filepath.Join(kurmaPath, string(kurmaPathPods))
 	volumesPath := filepath.Join(kurmaPath, string(kurmaPathVolumes))
+	imagesPath := filepath.Join(kurmaPath, string(kurmaPathImages))
+	storagePath := filepath.Join(kurmaPath, string(kurmaPathStorage))
 
 	if err := os.MkdirAll(podsPath, os.FileMode(0755)); err != nil {
 		return fmt.Errorf("failed to create pods directory: %v", err)
@@ -404,6 +431,12 @@ func (r *runner) createDirectories() error {
 	if err := os.MkdirAll(volumesPath, os.FileMode(0755)); err != nil {
 		return fmt.Errorf("failed to create volumes directory: %v", err)
 	}
+	if err := os.MkdirAll(imagesPath, os.FileMode(0755)); err != nil {
+		return fmt.Errorf("failed to create images directory: %v", err)
+	}
+	if err := os.MkdirAll(storagePath, os.FileMode(0755)); err != nil {
+		return fmt.Errorf("failed to create storage directory: %v", err)
+	}
 	return nil
 }
 
@@ -441,10 +474,22 @@ func (r *runner) displayNetwork() error {
 // launchManager creates the container manager to allow containers to be
 // launched.
 func (r *runner) launchManager() error {
+	hooks, err := r.config.Hooks.hooks()
+	if err != nil {
+		return err
+	}
+
 	mopts := &container.Options{
 		ParentCgroupName:   r.config.ParentCgroupName,
 		ContainerDirectory: filepath.Join(kurmaPath, string(kurmaPathPods)),
+		VolumeDirectory:    filepath.Join(kurmaPath, string(kurmaPathVolumes)),
 		RequiredNamespaces: r.config.RequiredNamespaces,
+
+		UserNamespaceOffset:    r.config.UserNamespaces.Offset,
+		UserNamespaceRangeSize: r.config.UserNamespaces.RangeSize,
+
+		MetadataPort: r.config.MetadataPort,
+		Hooks:        hooks,
 	}
 	m, err := container.NewManager(mopts)
 	if err != nil {
@@ -458,6 +503,137 @@ func (r *runner) launchManager() error {
 	return nil
 }
 
+// launchImageManager creates the image manager which stores the images used to
+// launch containers. This is done after the disks are mounted so that the
+// images are stored on them.
+func (r *runner) launchImageManager() error {
+	iopts := &image.Options{
+		Directory: filepath.Join(kurmaPath, string(kurmaPathImages)),
+		Keystore:  r.keystore,
+		Retriever: r.retriever,
+	}
+	m, err := image.NewManager(iopts)
+	if err != nil {
+		return fmt.Errorf("failed to create the image manager: %v", err)
+	}
+	m.Log = r.log.Clone()
+	r.images = m
+	r.manager.ImageManager = m
+	r.log.Trace("Image Manager has been initialized.")
+	return nil
+}
+
+// configureStorage sets the storage driver used for the root filesystems of
+// containers. This is done once the disks are mounted, since the drivers keep
+// their data on disk.
+func (r *runner) configureStorage() error {
+	if r.config.StorageDriver == "" {
+		return nil
+	}
+	storagePath := filepath.Join(kurmaPath, string(kurmaPathStorage))
+	if err := r.manager.SetStorageDriver(r.config.StorageDriver, storagePath); err != nil {
+		return fmt.Errorf("failed to configure the %q storage driver: %v", r.config.StorageDriver, err)
+	}
+	r.log.Tracef("Using the %q storage driver.", r.config.StorageDriver)
+	return nil
+}
+
+// configureImageRetrieval sets up the proxies and policy used when retrieving
+// images. The proxies are given through the environment, where they're used by
+// all HTTP requests.
+func (r *runner) configureImageRetrieval() error {
+	cfg := r.config.ImageRetrieval
+	proxies := map[string]string{
+		"HTTP_PROXY":  cfg.HTTPProxy,
+		"HTTPS_PROXY": cfg.HTTPSProxy,
+		"NO_PROXY":    cfg.NoProxy,
+	}
+	for name, value := range proxies {
+		if value != "" {
+			os.Setenv(name, value)
+		}
+	}
+
+	r.retriever = &image.Retriever{
+		Insecure: cfg.AllowInsecure != nil && *cfg.AllowInsecure,
+		Policy: &image.Policy{
+			AllowedURLs:  cfg.AllowedURLs,
+			DeniedURLs:   cfg.DeniedURLs,
+			AllowedNames: cfg.AllowedNames,
+			DeniedNames:  cfg.DeniedNames,
+		},
+	}
+	return nil
+}
+
+// loadTrustedKeys sets up the keystore of keys trusted to sign images, if
+// images are required to be signed.
+func (r *runner) loadTrustedKeys() error {
+	if r.config.ImageTrust.Required == nil || !*r.config.ImageTrust.Required {
+		r.log.Trace("Skipping image signature verification")
+		return nil
+	}
+
+	ks, err := image.NewKeystore(r.config.ImageTrust.Directory)
+	if err != nil {
+		return fmt.Errorf("failed to load the trusted keys: %v", err)
+	}
+	for _, key := range r.config.ImageTrust.Keys {
+		if err := ks.AddKey(key.Prefix, strings.NewReader(key.Key)); err != nil {
+			return fmt.Errorf("failed to load the trusted key for prefix %q: %v", key.Prefix, err)
+		}
+	}
+	r.keystore = ks
+	return nil
+}
+
+// retrieveImage returns the image for the given image URI. Images referenced
+// by name and labels are used from the image store if a matching image is
+// already stored, otherwise the image is retrieved and added to the store. When
+// images are required to be signed, the image's signature is retrieved and
+// verified.
+func (r *runner) retrieveImage(imageUri string) (aciremote.ReaderCloserSeeker, error) {
+	// the image store isn't available until the disks are mounted
+	if r.images == nil {
+		f, err := r.retriever.RetrieveImage(imageUri)
+		if err != nil {
+			return nil, err
+		}
+		if r.keystore != nil {
+			signature, err := r.retriever.RetrieveSignature(imageUri)
+			if err != nil {
+				f.Close()
+				return nil, err
+			}
+			if _, err := r.keystore.VerifyImage(f, signature); err != nil {
+				f.Close()
+				return nil, err
+			}
+		}
+		return f, nil
+	}
+
+	// check the image store for images referenced through discovery
+	if u, err := url.Parse(imageUri); err == nil && u.Scheme == "" {
+		if app, err := discovery.NewAppFromString(imageUri); err == nil {
+			var labels types.Labels
+			for name, value := range app.Labels {
+				labels = append(labels, types.Label{Name: name, Value: value})
+			}
+			if hash, _ := r.images.FindImage(app.Name.String(), labels); hash != "" {
+				r.log.Debugf("Using stored image %s for %q", hash, imageUri)
+				return r.images.OpenImage(hash)
+			}
+		}
+	}
+
+	hash, _, err := r.images.RetrieveImage(imageUri)
+	if err != nil {
+		return nil, err
+	}
+	return r.images.OpenImage(hash)
+}
+
 // startSignalHandling configures the necessary signal handlers for the init
 // process.
 func (r *runner) startSignalHandling() error {
@@ -472,6 +648,8 @@ func (r *runner) startSignalHandling() error {
 func (r *runner) startServer() error {
 	opts := &server.Options{
 		ContainerManager: r.manager,
+		ImageManager:     r.images,
+		MetricsAddress:   r.config.MetricsAddress,
 	}
 
 	s := server.New(opts)
@@ -484,7 +662,7 @@ func (r *runner) startServer() error {
 func (r *runner) startInitContainers() error {
 	for _, img := range r.config.InitContainers {
 		func() {
-			f, err := aciremote.RetrieveImage(img, true)
+			f, err := r.retrieveImage(img)
 			if err != nil {
 				r.log.Errorf("Failed to retrieve image %q: %v", img, err)
 				return
@@ -519,7 +697,7 @@ func (r *runner) startUdev() error {
 		return nil
 	}
 
-	f, err := aciremote.RetrieveImage(r.config.Services.Udev.ACI, true)
+	f, err := r.retrieveImage(r.config.Services.Udev.ACI)
 	if err != nil {
 		r.log.Errorf("Failed to retrieve udev image: %v", err)
 		return nil
@@ -546,7 +724,7 @@ func (r *runner) startUdev() error {
 
 	container.Wait()
 	r.log.Trace("Udev is finished")
-	if err := container.Stop(); err != nil {
+	if err := container.Stop(0); err != nil {
 		r.log.Errorf("Failed to stop udev cleanly: %v", err)
 		return nil
 	}
@@ -563,7 +741,7 @@ func (r *runner) startNTP() error {
 
 	r.log.Info("Updating system clock via NTP...")
 
-	f, err := aciremote.RetrieveImage(r.config.Services.NTP.ACI, true)
+	f, err := r.retrieveImage(r.config.Services.NTP.ACI)
 	if err != nil {
 		r.log.Errorf("Failed to retrieve NTP image: %v", err)
 		return nil
@@ -600,7 +778,7 @@ func (r *runner) startConsole() error {
 		return nil
 	}
 
-	f, err := aciremote.RetrieveImage(r.config.Services.Console.ACI, true)
+	f, err := r.retrieveImage(r.config.Services.Console.ACI)
 	if err != nil {
 		r.log.Errorf("Failed to retrieve console image: %v", err)
 		return nil
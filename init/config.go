@@ -12,10 +12,59 @@ type kurmaConfig struct {
 	Disks              []*kurmaDiskConfiguration `json:"disks,omitempty"`
 	ParentCgroupName   string                    `json:"parent_cgroup_name,omitempty"`
 	RequiredNamespaces []string                  `json:"required_namespaces,omitempty"`
+	UserNamespaces     kurmaUserNamespaces       `json:"user_namespaces,omitempty"`
+	StorageDriver      string                    `json:"storage_driver,omitempty"`
+	MetadataPort       int                       `json:"metadata_port,omitempty"`
+	MetricsAddress     string                    `json:"metrics_address,omitempty"`
+	Hooks              kurmaHooks                `json:"hooks,omitempty"`
+	ImageTrust         kurmaImageTrust           `json:"image_trust,omitempty"`
+	ImageRetrieval     kurmaImageRetrieval       `json:"image_retrieval,omitempty"`
 	Services           kurmaServices             `json:"services,omitempty"`
 	InitContainers     []string                  `json:"init_containers,omitempty"`
 }
 
+type kurmaUserNamespaces struct {
+	Offset    int `json:"offset,omitempty"`
+	RangeSize int `json:"range_size,omitempty"`
+}
+
+type kurmaImageTrust struct {
+	Required  *bool              `json:"required,omitempty"`
+	Directory string             `json:"directory,omitempty"`
+	Keys      []*kurmaTrustedKey `json:"keys,omitempty"`
+}
+
+type kurmaTrustedKey struct {
+	Prefix string `json:"prefix,omitempty"`
+	Key    string `json:"key"`
+}
+
+type kurmaImageRetrieval struct {
+	HTTPProxy    string   `json:"http_proxy,omitempty"`
+	HTTPSProxy   string   `json:"https_proxy,omitempty"`
+	NoProxy      string   `json:"no_proxy,omitempty"`
+	AllowedURLs  []string `json:"allowed_urls,omitempty"`
+	DeniedURLs   []string `json:"denied_urls,omitempty"`
+	AllowedNames []string `json:"allowed_names,omitempty"`
+	DeniedNames  []string `json:"denied_names,omitempty"`
+
+	// AllowInsecure permits images to be retrieved and discovered over plain
+	// HTTP and from hosts with invalid certificates.
+	AllowInsecure *bool `json:"allow_insecure,omitempty"`
+}
+
+type kurmaHooks struct {
+	PreStart  []*kurmaHook `json:"pre_start,omitempty"`
+	PostStart []*kurmaHook `json:"post_start,omitempty"`
+	PostStop  []*kurmaHook `json:"post_stop,omitempty"`
+}
+
+type kurmaHook struct {
+	Exec           []string `json:"exec"`
+	Timeout        string   `json:"timeout,omitempty"`
+	AbortOnFailure bool     `json:"abort_on_failure,omitempty"`
+}
+
 type OEMConfig struct {
 	Device     string `json:"device"`
 	ConfigPath string `json:"config_path"`
@@ -48,6 +97,8 @@ type kurmaPathUsage string
 const (
 	kurmaPathPods    = kurmaPathUsage("pods")
 	kurmaPathVolumes = kurmaPathUsage("volumes")
+	kurmaPathImages  = kurmaPathUsage("images")
+	kurmaPathStorage = kurmaPathUsage("storage")
 
 	kurmaPath = "/var/kurma"
 	mountPath = "/mnt"
@@ -123,6 +174,71 @@ func (cfg *kurmaConfig) mergeConfig(o *kurmaConfig) {
 		cfg.ParentCgroupName = o.ParentCgroupName
 	}
 
+	// replace user namespace ranges
+	if o.UserNamespaces.Offset != 0 {
+		cfg.UserNamespaces.Offset = o.UserNamespaces.Offset
+	}
+	if o.UserNamespaces.RangeSize != 0 {
+		cfg.UserNamespaces.RangeSize = o.UserNamespaces.RangeSize
+	}
+
+	// replace storage driver
+	if o.StorageDriver != "" {
+		cfg.StorageDriver = o.StorageDriver
+	}
+
+	// replace metadata service port
+	if o.MetadataPort != 0 {
+		cfg.MetadataPort = o.MetadataPort
+	}
+
+	// replace metrics address
+	if o.MetricsAddress != "" {
+		cfg.MetricsAddress = o.MetricsAddress
+	}
+
+	// append hooks
+	cfg.Hooks.PreStart = append(cfg.Hooks.PreStart, o.Hooks.PreStart...)
+	cfg.Hooks.PostStart = append(cfg.Hooks.PostStart, o.Hooks.PostStart...)
+	cfg.Hooks.PostStop = append(cfg.Hooks.PostStop, o.Hooks.PostStop...)
+
+	// image trust, the trusted keys are appended
+	if o.ImageTrust.Required != nil {
+		cfg.ImageTrust.Required = o.ImageTrust.Required
+	}
+	if o.ImageTrust.Directory != "" {
+		cfg.ImageTrust.Directory = o.ImageTrust.Directory
+	}
+	if len(o.ImageTrust.Keys) > 0 {
+		cfg.ImageTrust.Keys = append(cfg.ImageTrust.Keys, o.ImageTrust.Keys...)
+	}
+
+	// image retrieval, the proxies and policy lists are replaced
+	if o.ImageRetrieval.HTTPProxy != "" {
+		cfg.ImageRetrieval.HTTPProxy = o.ImageRetrieval.HTTPProxy
+	}
+	if o.ImageRetrieval.HTTPSProxy != "" {
+		cfg.ImageRetrieval.HTTPSProxy = o.ImageRetrieval.HTTPSProxy
+	}
+	if o.ImageRetrieval.NoProxy != "" {
+		cfg.ImageRetrieval.NoProxy = o.ImageRetrieval.NoProxy
+	}
+	if len(o.ImageRetrieval.AllowedURLs) > 0 {
+		cfg.ImageRetrieval.AllowedURLs = o.ImageRetrieval.AllowedURLs
+	}
+	if len(o.ImageRetrieval.DeniedURLs) > 0 {
+		cfg.ImageRetrieval.DeniedURLs = o.ImageRetrieval.DeniedURLs
+	}
+	if len(o.ImageRetrieval.AllowedNames) > 0 {
+		cfg.ImageRetrieval.AllowedNames = o.ImageRetrieval.AllowedNames
+	}
+	if len(o.ImageRetrieval.DeniedNames) > 0 {
+		cfg.ImageRetrieval.DeniedNames = o.ImageRetrieval.DeniedNames
+	}
+	if o.ImageRetrieval.AllowInsecure != nil {
+		cfg.ImageRetrieval.AllowInsecure = o.ImageRetrieval.AllowInsecure
+	}
+
 	// append init containers
 	if len(o.InitContainers) > 0 {
 		cfg.InitContainers = append(cfg.InitContainers, o.InitContainers...)
@@ -12,12 +12,17 @@ var (
 		(*runner).loadConfigurationFile,
 		(*runner).configureLogging,
 		(*runner).configureEnvironment,
+		(*runner).configureImageRetrieval,
 		(*runner).mountCgroups,
 		(*runner).loadModules,
+		(*runner).loadTrustedKeys,
 		(*runner).launchManager,
 		(*runner).createDirectories,
 		(*runner).startUdev,
 		(*runner).mountDisks,
+		(*runner).launchImageManager,
+		(*runner).configureStorage,
+		(*runner).recoverPods,
 		(*runner).cleanOldPods,
 		(*runner).configureHostname,
 		(*runner).configureNetwork,
@@ -52,5 +57,6 @@ func defaultConfiguration() *kurmaConfig {
 				},
 			},
 		},
+		MetadataPort: 18112,
 	}
 }
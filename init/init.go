@@ -6,6 +6,7 @@ import (
 	"fmt"
 
 	"github.com/apcera/kurma/stage1/container"
+	"github.com/apcera/kurma/stage1/image"
 	"github.com/apcera/logray"
 )
 
@@ -16,6 +17,15 @@ type runner struct {
 	config  *kurmaConfig
 	log     *logray.Logger
 	manager *container.Manager
+	images  *image.Manager
+
+	// keystore holds the keys trusted to sign images, it is nil when images
+	// are not required to be signed.
+	keystore *image.Keystore
+
+	// retriever is used for all image retrieval, enforcing the configured
+	// image retrieval policy.
+	retriever *image.Retriever
 }
 
 // Run takes over the process and launches KurmaOS.
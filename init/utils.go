@@ -11,7 +11,9 @@ import (
 	"os/exec"
 	"path/filepath"
 	"syscall"
+	"time"
 
+	"github.com/apcera/kurma/stage1/container"
 	"github.com/apcera/util/tarhelper"
 	"github.com/appc/spec/schema"
 	"github.com/vishvananda/netlink"
@@ -200,3 +202,42 @@ func getConfigurationFromFile(file string) (*kurmaConfig, error) {
 	}
 	return config, nil
 }
+
+// hooks converts the configured hooks into the form used by the container
+// manager, parsing each of their timeouts.
+func (h kurmaHooks) hooks() (container.Hooks, error) {
+	var hooks container.Hooks
+	var err error
+	if hooks.PreStart, err = convertHooks(h.PreStart); err != nil {
+		return hooks, err
+	}
+	if hooks.PostStart, err = convertHooks(h.PostStart); err != nil {
+		return hooks, err
+	}
+	if hooks.PostStop, err = convertHooks(h.PostStop); err != nil {
+		return hooks, err
+	}
+	return hooks, nil
+}
+
+func convertHooks(configs []*kurmaHook) ([]*container.Hook, error) {
+	hooks := make([]*container.Hook, 0, len(configs))
+	for _, cfg := range configs {
+		if len(cfg.Exec) == 0 {
+			return nil, fmt.Errorf("a hook was configured without a command")
+		}
+		hook := &container.Hook{
+			Exec:           cfg.Exec,
+			AbortOnFailure: cfg.AbortOnFailure,
+		}
+		if cfg.Timeout != "" {
+			d, err := time.ParseDuration(cfg.Timeout)
+			if err != nil {
+				return nil, fmt.Errorf("invalid timeout for hook %q: %v", cfg.Exec[0], err)
+			}
+			hook.Timeout = d
+		}
+		hooks = append(hooks, hook)
+	}
+	return hooks, nil
+}
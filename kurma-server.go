@@ -4,6 +4,7 @@ package main
 
 import (
 	"os"
+	"path/filepath"
 
 	"github.com/apcera/kurma/stage1/server"
 	"github.com/apcera/logray"
@@ -20,6 +21,7 @@ func main() {
 	opts := &server.Options{
 		ParentCgroupName:   "kurma",
 		ContainerDirectory: directory,
+		ImageDirectory:     filepath.Join(directory, "images"),
 	}
 
 	s := server.New(opts)
@@ -0,0 +1,20 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package schema
+
+const (
+	// StopSignalAnnotation is the image annotation specifying the signal that is
+	// sent to the app to request it to exit when the container is stopped, such
+	// as "SIGTERM" or "SIGINT".
+	StopSignalAnnotation = "kurma/stop-signal"
+
+	// StopGracePeriodAnnotation is the image annotation specifying how long to
+	// wait for the app to exit after the stop signal before it is killed. It is
+	// in the form of a Go duration, such as "30s".
+	StopGracePeriodAnnotation = "kurma/stop-grace-period"
+
+	// HookTimeoutAnnotation is the image annotation specifying how long each of
+	// the app's event handlers may run before it is considered to have failed. It
+	// is in the form of a Go duration, such as "1m".
+	HookTimeoutAnnotation = "kurma/hook-timeout"
+)
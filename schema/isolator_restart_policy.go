@@ -0,0 +1,108 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/appc/spec/schema/types"
+)
+
+const (
+	RestartPolicyName = "kurma/restart-policy"
+
+	RestartNever     = "never"
+	RestartOnFailure = "on-failure"
+	RestartAlways    = "always"
+
+	defaultRestartBackoff    = time.Second
+	defaultRestartMaxBackoff = time.Minute
+)
+
+func init() {
+	types.AddIsolatorValueConstructor(RestartPolicyName, newRestartPolicy)
+}
+
+func newRestartPolicy() types.IsolatorValue {
+	return &RestartPolicy{
+		Policy:     RestartNever,
+		backoff:    defaultRestartBackoff,
+		maxBackoff: defaultRestartMaxBackoff,
+	}
+}
+
+// RestartPolicy describes whether the apps within a container should be
+// relaunched when they exit. MaxRetries of 0 allows unlimited restarts. The
+// backoff between restarts doubles with each restart, up to MaxBackoff.
+type RestartPolicy struct {
+	Policy     string
+	MaxRetries int
+
+	backoff    time.Duration
+	maxBackoff time.Duration
+}
+
+type restartPolicyValue struct {
+	Policy     string `json:"policy"`
+	MaxRetries int    `json:"max_retries,omitempty"`
+	Backoff    string `json:"backoff,omitempty"`
+	MaxBackoff string `json:"max_backoff,omitempty"`
+}
+
+func (n *RestartPolicy) UnmarshalJSON(b []byte) error {
+	var v restartPolicyValue
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+
+	if v.Policy != "" {
+		n.Policy = v.Policy
+	}
+	n.MaxRetries = v.MaxRetries
+
+	if v.Backoff != "" {
+		d, err := time.ParseDuration(v.Backoff)
+		if err != nil {
+			return fmt.Errorf("invalid backoff %q: %v", v.Backoff, err)
+		}
+		n.backoff = d
+	}
+	if v.MaxBackoff != "" {
+		d, err := time.ParseDuration(v.MaxBackoff)
+		if err != nil {
+			return fmt.Errorf("invalid max_backoff %q: %v", v.MaxBackoff, err)
+		}
+		n.maxBackoff = d
+	}
+	return nil
+}
+
+func (n *RestartPolicy) AssertValid() error {
+	switch n.Policy {
+	case RestartNever, RestartOnFailure, RestartAlways:
+	default:
+		return fmt.Errorf("unrecognized restart policy %q", n.Policy)
+	}
+	if n.MaxRetries < 0 {
+		return fmt.Errorf("max_retries must not be negative")
+	}
+	if n.backoff <= 0 || n.maxBackoff <= 0 {
+		return fmt.Errorf("backoff durations must be positive")
+	}
+	return nil
+}
+
+// Backoff returns the amount of time to wait before performing the restart
+// following the provided number of previous restarts.
+func (n *RestartPolicy) Backoff(restarts int) time.Duration {
+	d := n.backoff
+	for i := 0; i < restarts && d < n.maxBackoff; i++ {
+		d *= 2
+	}
+	if d > n.maxBackoff {
+		d = n.maxBackoff
+	}
+	return d
+}
@@ -0,0 +1,78 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package schema
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/appc/spec/schema/types"
+
+	. "github.com/apcera/util/testtool"
+)
+
+// parseRestartPolicy parses the restart policy isolator with the given value.
+func parseRestartPolicy(t *testing.T, value string) (*RestartPolicy, error) {
+	var iso types.Isolator
+	b := []byte(`{"name":"` + RestartPolicyName + `","value":` + value + `}`)
+	if err := json.Unmarshal(b, &iso); err != nil {
+		return nil, err
+	}
+	policy, ok := iso.Value().(*RestartPolicy)
+	if !ok {
+		Fatalf(t, "Expected a restart policy, got %T", iso.Value())
+	}
+	return policy, nil
+}
+
+func TestRestartPolicy_Backoff(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	// The defaults start at a second and are capped at a minute.
+	policy, err := parseRestartPolicy(t, `{"policy":"always"}`)
+	TestExpectSuccess(t, err)
+	defaults := []time.Duration{
+		time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second,
+		16 * time.Second, 32 * time.Second, time.Minute, time.Minute,
+	}
+	for restarts, want := range defaults {
+		TestEqual(t, policy.Backoff(restarts), want)
+	}
+	TestEqual(t, policy.Backoff(1000), time.Minute)
+
+	policy, err = parseRestartPolicy(t, `{"policy":"on-failure","backoff":"100ms","max_backoff":"1s"}`)
+	TestExpectSuccess(t, err)
+	configured := []time.Duration{
+		100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond,
+		800 * time.Millisecond, time.Second, time.Second,
+	}
+	for restarts, want := range configured {
+		TestEqual(t, policy.Backoff(restarts), want)
+	}
+
+	// A backoff above the maximum is capped from the first restart.
+	policy, err = parseRestartPolicy(t, `{"policy":"always","backoff":"5s","max_backoff":"2s"}`)
+	TestExpectSuccess(t, err)
+	TestEqual(t, policy.Backoff(0), 2*time.Second)
+}
+
+func TestRestartPolicy_Invalid(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	tests := []string{
+		`{"policy":"sometimes"}`,
+		`{"policy":"always","max_retries":-1}`,
+		`{"policy":"always","backoff":"soon"}`,
+		`{"policy":"always","max_backoff":"later"}`,
+		`{"policy":"always","backoff":"-1s"}`,
+		`{"policy":"always","max_backoff":"0s"}`,
+	}
+	for _, value := range tests {
+		if _, err := parseRestartPolicy(t, value); err == nil {
+			Fatalf(t, "Expected an error for the restart policy %s", value)
+		}
+	}
+}
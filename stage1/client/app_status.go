@@ -0,0 +1,25 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package client
+
+import (
+	"fmt"
+	"syscall"
+)
+
+// Description returns a human readable summary of whether the app is running,
+// or how it terminated.
+func (s *AppStatus) Description() string {
+	switch {
+	case s.Running:
+		return "running"
+	case s.OomKilled:
+		return fmt.Sprintf("killed by signal %d (%s) after running out of memory", s.Signal, syscall.Signal(s.Signal))
+	case s.Exited:
+		return fmt.Sprintf("exited with code %d", s.ExitCode)
+	case s.Signaled:
+		return fmt.Sprintf("killed by signal %d (%s)", s.Signal, syscall.Signal(s.Signal))
+	default:
+		return "unknown"
+	}
+}
@@ -10,11 +10,23 @@ It is generated from these files:
 
 It has these top-level messages:
 	CreateRequest
+	AppRequest
 	CreateResponse
 	ContainerRequest
 	ListResponse
+	LogsRequest
+	LogLine
+	ExecRequest
+	ExecResponse
+	StatsRequest
+	StatsResponse
+	ContainerStats
+	EnterRequest
+	WindowSize
 	ByteChunk
 	Container
+	AppStatus
+	ContainerEvent
 	None
 */
 package client
@@ -33,6 +45,32 @@ var _ grpc.ClientConn
 // Reference imports to suppress errors if they are not otherwise used.
 var _ = proto.Marshal
 
+type LogsRequest_Stream int32
+
+const (
+	LogsRequest_ALL    LogsRequest_Stream = 0
+	LogsRequest_STDOUT LogsRequest_Stream = 1
+	LogsRequest_STDERR LogsRequest_Stream = 2
+	LogsRequest_STAGE2 LogsRequest_Stream = 3
+)
+
+var LogsRequest_Stream_name = map[int32]string{
+	0: "ALL",
+	1: "STDOUT",
+	2: "STDERR",
+	3: "STAGE2",
+}
+var LogsRequest_Stream_value = map[string]int32{
+	"ALL":    0,
+	"STDOUT": 1,
+	"STDERR": 2,
+	"STAGE2": 3,
+}
+
+func (x LogsRequest_Stream) String() string {
+	return proto.EnumName(LogsRequest_Stream_name, int32(x))
+}
+
 type Container_State int32
 
 const (
@@ -42,6 +80,7 @@ const (
 	Container_STOPPING Container_State = 3
 	Container_STOPPED  Container_State = 4
 	Container_EXITED   Container_State = 5
+	Container_FAILED   Container_State = 6
 )
 
 var Container_State_name = map[int32]string{
@@ -51,6 +90,7 @@ var Container_State_name = map[int32]string{
 	3: "STOPPING",
 	4: "STOPPED",
 	5: "EXITED",
+	6: "FAILED",
 }
 var Container_State_value = map[string]int32{
 	"NEW":      0,
@@ -59,6 +99,7 @@ var Container_State_value = map[string]int32{
 	"STOPPING": 3,
 	"STOPPED":  4,
 	"EXITED":   5,
+	"FAILED":   6,
 }
 
 func (x Container_State) String() string {
@@ -66,17 +107,40 @@ func (x Container_State) String() string {
 }
 
 type CreateRequest struct {
-	Name     string `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
-	Manifest []byte `protobuf:"bytes,2,opt,name=manifest,proto3" json:"manifest,omitempty"`
+	Name      string        `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
+	Manifest  []byte        `protobuf:"bytes,2,opt,name=manifest,proto3" json:"manifest,omitempty"`
+	Apps      []*AppRequest `protobuf:"bytes,3,rep,name=apps" json:"apps,omitempty"`
+	Volumes   []byte        `protobuf:"bytes,4,opt,name=volumes,proto3" json:"volumes,omitempty"`
+	ImageId   string        `protobuf:"bytes,5,opt,name=image_id" json:"image_id,omitempty"`
+	Signature []byte        `protobuf:"bytes,6,opt,name=signature,proto3" json:"signature,omitempty"`
 }
 
 func (m *CreateRequest) Reset()         { *m = CreateRequest{} }
 func (m *CreateRequest) String() string { return proto.CompactTextString(m) }
 func (*CreateRequest) ProtoMessage()    {}
 
+func (m *CreateRequest) GetApps() []*AppRequest {
+	if m != nil {
+		return m.Apps
+	}
+	return nil
+}
+
+type AppRequest struct {
+	Name      string `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
+	Manifest  []byte `protobuf:"bytes,2,opt,name=manifest,proto3" json:"manifest,omitempty"`
+	ImageId   string `protobuf:"bytes,3,opt,name=image_id" json:"image_id,omitempty"`
+	Signature []byte `protobuf:"bytes,4,opt,name=signature,proto3" json:"signature,omitempty"`
+}
+
+func (m *AppRequest) Reset()         { *m = AppRequest{} }
+func (m *AppRequest) String() string { return proto.CompactTextString(m) }
+func (*AppRequest) ProtoMessage()    {}
+
 type CreateResponse struct {
 	ImageUploadId string     `protobuf:"bytes,1,opt,name=image_upload_id" json:"image_upload_id,omitempty"`
 	Container     *Container `protobuf:"bytes,2,opt,name=container" json:"container,omitempty"`
+	AppUploadIds  []string   `protobuf:"bytes,3,rep,name=app_upload_ids" json:"app_upload_ids,omitempty"`
 }
 
 func (m *CreateResponse) Reset()         { *m = CreateResponse{} }
@@ -91,7 +155,8 @@ func (m *CreateResponse) GetContainer() *Container {
 }
 
 type ContainerRequest struct {
-	Uuid string `protobuf:"bytes,1,opt,name=uuid" json:"uuid,omitempty"`
+	Uuid        string `protobuf:"bytes,1,opt,name=uuid" json:"uuid,omitempty"`
+	GracePeriod int32  `protobuf:"varint,2,opt,name=grace_period" json:"grace_period,omitempty"`
 }
 
 func (m *ContainerRequest) Reset()         { *m = ContainerRequest{} }
@@ -113,6 +178,128 @@ func (m *ListResponse) GetContainers() []*Container {
 	return nil
 }
 
+type LogsRequest struct {
+	Uuid       string             `protobuf:"bytes,1,opt,name=uuid" json:"uuid,omitempty"`
+	App        string             `protobuf:"bytes,2,opt,name=app" json:"app,omitempty"`
+	Stream     LogsRequest_Stream `protobuf:"varint,3,opt,name=stream,enum=client.LogsRequest_Stream" json:"stream,omitempty"`
+	Tail       int32              `protobuf:"varint,4,opt,name=tail" json:"tail,omitempty"`
+	Follow     bool               `protobuf:"varint,5,opt,name=follow" json:"follow,omitempty"`
+	Timestamps bool               `protobuf:"varint,6,opt,name=timestamps" json:"timestamps,omitempty"`
+}
+
+func (m *LogsRequest) Reset()         { *m = LogsRequest{} }
+func (m *LogsRequest) String() string { return proto.CompactTextString(m) }
+func (*LogsRequest) ProtoMessage()    {}
+
+type LogLine struct {
+	App       string             `protobuf:"bytes,1,opt,name=app" json:"app,omitempty"`
+	Stream    LogsRequest_Stream `protobuf:"varint,2,opt,name=stream,enum=client.LogsRequest_Stream" json:"stream,omitempty"`
+	Line      []byte             `protobuf:"bytes,3,opt,name=line,proto3" json:"line,omitempty"`
+	Timestamp int64              `protobuf:"varint,4,opt,name=timestamp" json:"timestamp,omitempty"`
+}
+
+func (m *LogLine) Reset()         { *m = LogLine{} }
+func (m *LogLine) String() string { return proto.CompactTextString(m) }
+func (*LogLine) ProtoMessage()    {}
+
+type ExecRequest struct {
+	Uuid             string   `protobuf:"bytes,1,opt,name=uuid" json:"uuid,omitempty"`
+	App              string   `protobuf:"bytes,2,opt,name=app" json:"app,omitempty"`
+	Command          []string `protobuf:"bytes,3,rep,name=command" json:"command,omitempty"`
+	Environment      []string `protobuf:"bytes,4,rep,name=environment" json:"environment,omitempty"`
+	WorkingDirectory string   `protobuf:"bytes,5,opt,name=working_directory" json:"working_directory,omitempty"`
+	User             string   `protobuf:"bytes,6,opt,name=user" json:"user,omitempty"`
+	Group            string   `protobuf:"bytes,7,opt,name=group" json:"group,omitempty"`
+	Tty              bool     `protobuf:"varint,8,opt,name=tty" json:"tty,omitempty"`
+	Stdin            []byte   `protobuf:"bytes,9,opt,name=stdin,proto3" json:"stdin,omitempty"`
+}
+
+func (m *ExecRequest) Reset()         { *m = ExecRequest{} }
+func (m *ExecRequest) String() string { return proto.CompactTextString(m) }
+func (*ExecRequest) ProtoMessage()    {}
+
+type ExecResponse struct {
+	Stdout   []byte `protobuf:"bytes,1,opt,name=stdout,proto3" json:"stdout,omitempty"`
+	Stderr   []byte `protobuf:"bytes,2,opt,name=stderr,proto3" json:"stderr,omitempty"`
+	Exited   bool   `protobuf:"varint,3,opt,name=exited" json:"exited,omitempty"`
+	ExitCode int32  `protobuf:"varint,4,opt,name=exit_code" json:"exit_code,omitempty"`
+}
+
+func (m *ExecResponse) Reset()         { *m = ExecResponse{} }
+func (m *ExecResponse) String() string { return proto.CompactTextString(m) }
+func (*ExecResponse) ProtoMessage()    {}
+
+type StatsRequest struct {
+	Uuid     string `protobuf:"bytes,1,opt,name=uuid" json:"uuid,omitempty"`
+	Interval int32  `protobuf:"varint,2,opt,name=interval" json:"interval,omitempty"`
+}
+
+func (m *StatsRequest) Reset()         { *m = StatsRequest{} }
+func (m *StatsRequest) String() string { return proto.CompactTextString(m) }
+func (*StatsRequest) ProtoMessage()    {}
+
+type StatsResponse struct {
+	Timestamp  int64             `protobuf:"varint,1,opt,name=timestamp" json:"timestamp,omitempty"`
+	Containers []*ContainerStats `protobuf:"bytes,2,rep,name=containers" json:"containers,omitempty"`
+}
+
+func (m *StatsResponse) Reset()         { *m = StatsResponse{} }
+func (m *StatsResponse) String() string { return proto.CompactTextString(m) }
+func (*StatsResponse) ProtoMessage()    {}
+
+func (m *StatsResponse) GetContainers() []*ContainerStats {
+	if m != nil {
+		return m.Containers
+	}
+	return nil
+}
+
+type ContainerStats struct {
+	Uuid        string `protobuf:"bytes,1,opt,name=uuid" json:"uuid,omitempty"`
+	Name        string `protobuf:"bytes,2,opt,name=name" json:"name,omitempty"`
+	CpuTime     int64  `protobuf:"varint,3,opt,name=cpu_time" json:"cpu_time,omitempty"`
+	MemoryUsed  int64  `protobuf:"varint,4,opt,name=memory_used" json:"memory_used,omitempty"`
+	MemoryLimit int64  `protobuf:"varint,5,opt,name=memory_limit" json:"memory_limit,omitempty"`
+	DiskUsed    int64  `protobuf:"varint,6,opt,name=disk_used" json:"disk_used,omitempty"`
+	Tasks       int32  `protobuf:"varint,7,opt,name=tasks" json:"tasks,omitempty"`
+}
+
+func (m *ContainerStats) Reset()         { *m = ContainerStats{} }
+func (m *ContainerStats) String() string { return proto.CompactTextString(m) }
+func (*ContainerStats) ProtoMessage()    {}
+
+type EnterRequest struct {
+	Uuid        string      `protobuf:"bytes,1,opt,name=uuid" json:"uuid,omitempty"`
+	Stdin       []byte      `protobuf:"bytes,2,opt,name=stdin,proto3" json:"stdin,omitempty"`
+	WindowSize  *WindowSize `protobuf:"bytes,3,opt,name=window_size" json:"window_size,omitempty"`
+	Signal      int32       `protobuf:"varint,4,opt,name=signal" json:"signal,omitempty"`
+	App         string      `protobuf:"bytes,5,opt,name=app" json:"app,omitempty"`
+	Command     []string    `protobuf:"bytes,6,rep,name=command" json:"command,omitempty"`
+	Environment []string    `protobuf:"bytes,7,rep,name=environment" json:"environment,omitempty"`
+	User        string      `protobuf:"bytes,8,opt,name=user" json:"user,omitempty"`
+	Group       string      `protobuf:"bytes,9,opt,name=group" json:"group,omitempty"`
+}
+
+func (m *EnterRequest) Reset()         { *m = EnterRequest{} }
+func (m *EnterRequest) String() string { return proto.CompactTextString(m) }
+func (*EnterRequest) ProtoMessage()    {}
+
+func (m *EnterRequest) GetWindowSize() *WindowSize {
+	if m != nil {
+		return m.WindowSize
+	}
+	return nil
+}
+
+type WindowSize struct {
+	Rows    uint32 `protobuf:"varint,1,opt,name=rows" json:"rows,omitempty"`
+	Columns uint32 `protobuf:"varint,2,opt,name=columns" json:"columns,omitempty"`
+}
+
+func (m *WindowSize) Reset()         { *m = WindowSize{} }
+func (m *WindowSize) String() string { return proto.CompactTextString(m) }
+func (*WindowSize) ProtoMessage()    {}
+
 type ByteChunk struct {
 	StreamId string `protobuf:"bytes,1,opt,name=stream_id" json:"stream_id,omitempty"`
 	Bytes    []byte `protobuf:"bytes,2,opt,name=bytes,proto3" json:"bytes,omitempty"`
@@ -123,15 +310,52 @@ func (m *ByteChunk) String() string { return proto.CompactTextString(m) }
 func (*ByteChunk) ProtoMessage()    {}
 
 type Container struct {
-	Uuid     string          `protobuf:"bytes,1,opt,name=uuid" json:"uuid,omitempty"`
-	Manifest []byte          `protobuf:"bytes,2,opt,name=manifest,proto3" json:"manifest,omitempty"`
-	State    Container_State `protobuf:"varint,3,opt,name=state,enum=client.Container_State" json:"state,omitempty"`
+	Uuid         string          `protobuf:"bytes,1,opt,name=uuid" json:"uuid,omitempty"`
+	Manifest     []byte          `protobuf:"bytes,2,opt,name=manifest,proto3" json:"manifest,omitempty"`
+	State        Container_State `protobuf:"varint,3,opt,name=state,enum=client.Container_State" json:"state,omitempty"`
+	RestartCount int32           `protobuf:"varint,4,opt,name=restart_count" json:"restart_count,omitempty"`
+	AppStatuses  []*AppStatus    `protobuf:"bytes,5,rep,name=app_statuses" json:"app_statuses,omitempty"`
+	StartupError string          `protobuf:"bytes,6,opt,name=startup_error" json:"startup_error,omitempty"`
+	OomKills     int32           `protobuf:"varint,7,opt,name=oom_kills" json:"oom_kills,omitempty"`
 }
 
 func (m *Container) Reset()         { *m = Container{} }
 func (m *Container) String() string { return proto.CompactTextString(m) }
 func (*Container) ProtoMessage()    {}
 
+func (m *Container) GetAppStatuses() []*AppStatus {
+	if m != nil {
+		return m.AppStatuses
+	}
+	return nil
+}
+
+type AppStatus struct {
+	Name      string `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
+	Running   bool   `protobuf:"varint,2,opt,name=running" json:"running,omitempty"`
+	Exited    bool   `protobuf:"varint,3,opt,name=exited" json:"exited,omitempty"`
+	ExitCode  int32  `protobuf:"varint,4,opt,name=exit_code" json:"exit_code,omitempty"`
+	Signaled  bool   `protobuf:"varint,5,opt,name=signaled" json:"signaled,omitempty"`
+	Signal    int32  `protobuf:"varint,6,opt,name=signal" json:"signal,omitempty"`
+	OomKilled bool   `protobuf:"varint,7,opt,name=oom_killed" json:"oom_killed,omitempty"`
+}
+
+func (m *AppStatus) Reset()         { *m = AppStatus{} }
+func (m *AppStatus) String() string { return proto.CompactTextString(m) }
+func (*AppStatus) ProtoMessage()    {}
+
+type ContainerEvent struct {
+	Uuid      string          `protobuf:"bytes,1,opt,name=uuid" json:"uuid,omitempty"`
+	State     Container_State `protobuf:"varint,2,opt,name=state,enum=client.Container_State" json:"state,omitempty"`
+	Timestamp int64           `protobuf:"varint,3,opt,name=timestamp" json:"timestamp,omitempty"`
+	Reason    string          `protobuf:"bytes,4,opt,name=reason" json:"reason,omitempty"`
+	Oom       bool            `protobuf:"varint,5,opt,name=oom" json:"oom,omitempty"`
+}
+
+func (m *ContainerEvent) Reset()         { *m = ContainerEvent{} }
+func (m *ContainerEvent) String() string { return proto.CompactTextString(m) }
+func (*ContainerEvent) ProtoMessage()    {}
+
 type None struct {
 }
 
@@ -140,6 +364,7 @@ func (m *None) String() string { return proto.CompactTextString(m) }
 func (*None) ProtoMessage()    {}
 
 func init() {
+	proto.RegisterEnum("client.LogsRequest_Stream", LogsRequest_Stream_name, LogsRequest_Stream_value)
 	proto.RegisterEnum("client.Container_State", Container_State_name, Container_State_value)
 }
 
@@ -152,6 +377,11 @@ type KurmaClient interface {
 	List(ctx context.Context, in *None, opts ...grpc.CallOption) (*ListResponse, error)
 	Get(ctx context.Context, in *ContainerRequest, opts ...grpc.CallOption) (*Container, error)
 	Enter(ctx context.Context, opts ...grpc.CallOption) (Kurma_EnterClient, error)
+	Watch(ctx context.Context, in *ContainerRequest, opts ...grpc.CallOption) (Kurma_WatchClient, error)
+	Logs(ctx context.Context, in *LogsRequest, opts ...grpc.CallOption) (Kurma_LogsClient, error)
+	Exec(ctx context.Context, opts ...grpc.CallOption) (Kurma_ExecClient, error)
+	Stats(ctx context.Context, in *StatsRequest, opts ...grpc.CallOption) (*StatsResponse, error)
+	StreamStats(ctx context.Context, in *StatsRequest, opts ...grpc.CallOption) (Kurma_StreamStatsClient, error)
 }
 
 type kurmaClient struct {
@@ -242,7 +472,7 @@ func (c *kurmaClient) Enter(ctx context.Context, opts ...grpc.CallOption) (Kurma
 }
 
 type Kurma_EnterClient interface {
-	Send(*ByteChunk) error
+	Send(*EnterRequest) error
 	Recv() (*ByteChunk, error)
 	grpc.ClientStream
 }
@@ -251,7 +481,7 @@ type kurmaEnterClient struct {
 	grpc.ClientStream
 }
 
-func (x *kurmaEnterClient) Send(m *ByteChunk) error {
+func (x *kurmaEnterClient) Send(m *EnterRequest) error {
 	return x.ClientStream.SendMsg(m)
 }
 
@@ -263,6 +493,142 @@ func (x *kurmaEnterClient) Recv() (*ByteChunk, error) {
 	return m, nil
 }
 
+func (c *kurmaClient) Watch(ctx context.Context, in *ContainerRequest, opts ...grpc.CallOption) (Kurma_WatchClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_Kurma_serviceDesc.Streams[2], c.cc, "/client.Kurma/Watch", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &kurmaWatchClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Kurma_WatchClient interface {
+	Recv() (*ContainerEvent, error)
+	grpc.ClientStream
+}
+
+type kurmaWatchClient struct {
+	grpc.ClientStream
+}
+
+func (x *kurmaWatchClient) Recv() (*ContainerEvent, error) {
+	m := new(ContainerEvent)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *kurmaClient) Logs(ctx context.Context, in *LogsRequest, opts ...grpc.CallOption) (Kurma_LogsClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_Kurma_serviceDesc.Streams[3], c.cc, "/client.Kurma/Logs", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &kurmaLogsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Kurma_LogsClient interface {
+	Recv() (*LogLine, error)
+	grpc.ClientStream
+}
+
+type kurmaLogsClient struct {
+	grpc.ClientStream
+}
+
+func (x *kurmaLogsClient) Recv() (*LogLine, error) {
+	m := new(LogLine)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *kurmaClient) Exec(ctx context.Context, opts ...grpc.CallOption) (Kurma_ExecClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_Kurma_serviceDesc.Streams[4], c.cc, "/client.Kurma/Exec", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &kurmaExecClient{stream}
+	return x, nil
+}
+
+type Kurma_ExecClient interface {
+	Send(*ExecRequest) error
+	Recv() (*ExecResponse, error)
+	grpc.ClientStream
+}
+
+type kurmaExecClient struct {
+	grpc.ClientStream
+}
+
+func (x *kurmaExecClient) Send(m *ExecRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *kurmaExecClient) Recv() (*ExecResponse, error) {
+	m := new(ExecResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *kurmaClient) Stats(ctx context.Context, in *StatsRequest, opts ...grpc.CallOption) (*StatsResponse, error) {
+	out := new(StatsResponse)
+	err := grpc.Invoke(ctx, "/client.Kurma/Stats", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *kurmaClient) StreamStats(ctx context.Context, in *StatsRequest, opts ...grpc.CallOption) (Kurma_StreamStatsClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_Kurma_serviceDesc.Streams[5], c.cc, "/client.Kurma/StreamStats", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &kurmaStreamStatsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Kurma_StreamStatsClient interface {
+	Recv() (*StatsResponse, error)
+	grpc.ClientStream
+}
+
+type kurmaStreamStatsClient struct {
+	grpc.ClientStream
+}
+
+func (x *kurmaStreamStatsClient) Recv() (*StatsResponse, error) {
+	m := new(StatsResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 // Server API for Kurma service
 
 type KurmaServer interface {
@@ -272,6 +638,11 @@ type KurmaServer interface {
 	List(context.Context, *None) (*ListResponse, error)
 	Get(context.Context, *ContainerRequest) (*Container, error)
 	Enter(Kurma_EnterServer) error
+	Watch(*ContainerRequest, Kurma_WatchServer) error
+	Logs(*LogsRequest, Kurma_LogsServer) error
+	Exec(Kurma_ExecServer) error
+	Stats(context.Context, *StatsRequest) (*StatsResponse, error)
+	StreamStats(*StatsRequest, Kurma_StreamStatsServer) error
 }
 
 func RegisterKurmaServer(s *grpc.Server, srv KurmaServer) {
@@ -358,7 +729,7 @@ func _Kurma_Enter_Handler(srv interface{}, stream grpc.ServerStream) error {
 
 type Kurma_EnterServer interface {
 	Send(*ByteChunk) error
-	Recv() (*ByteChunk, error)
+	Recv() (*EnterRequest, error)
 	grpc.ServerStream
 }
 
@@ -370,14 +741,115 @@ func (x *kurmaEnterServer) Send(m *ByteChunk) error {
 	return x.ServerStream.SendMsg(m)
 }
 
-func (x *kurmaEnterServer) Recv() (*ByteChunk, error) {
-	m := new(ByteChunk)
+func (x *kurmaEnterServer) Recv() (*EnterRequest, error) {
+	m := new(EnterRequest)
 	if err := x.ServerStream.RecvMsg(m); err != nil {
 		return nil, err
 	}
 	return m, nil
 }
 
+func _Kurma_Watch_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ContainerRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(KurmaServer).Watch(m, &kurmaWatchServer{stream})
+}
+
+type Kurma_WatchServer interface {
+	Send(*ContainerEvent) error
+	grpc.ServerStream
+}
+
+type kurmaWatchServer struct {
+	grpc.ServerStream
+}
+
+func (x *kurmaWatchServer) Send(m *ContainerEvent) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Kurma_Logs_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(LogsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(KurmaServer).Logs(m, &kurmaLogsServer{stream})
+}
+
+type Kurma_LogsServer interface {
+	Send(*LogLine) error
+	grpc.ServerStream
+}
+
+type kurmaLogsServer struct {
+	grpc.ServerStream
+}
+
+func (x *kurmaLogsServer) Send(m *LogLine) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Kurma_Exec_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(KurmaServer).Exec(&kurmaExecServer{stream})
+}
+
+type Kurma_ExecServer interface {
+	Send(*ExecResponse) error
+	Recv() (*ExecRequest, error)
+	grpc.ServerStream
+}
+
+type kurmaExecServer struct {
+	grpc.ServerStream
+}
+
+func (x *kurmaExecServer) Send(m *ExecResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *kurmaExecServer) Recv() (*ExecRequest, error) {
+	m := new(ExecRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _Kurma_Stats_Handler(srv interface{}, ctx context.Context, codec grpc.Codec, buf []byte) (interface{}, error) {
+	in := new(StatsRequest)
+	if err := codec.Unmarshal(buf, in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(KurmaServer).Stats(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Kurma_StreamStats_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(StatsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(KurmaServer).StreamStats(m, &kurmaStreamStatsServer{stream})
+}
+
+type Kurma_StreamStatsServer interface {
+	Send(*StatsResponse) error
+	grpc.ServerStream
+}
+
+type kurmaStreamStatsServer struct {
+	grpc.ServerStream
+}
+
+func (x *kurmaStreamStatsServer) Send(m *StatsResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
 var _Kurma_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "client.Kurma",
 	HandlerType: (*KurmaServer)(nil),
@@ -398,6 +870,10 @@ var _Kurma_serviceDesc = grpc.ServiceDesc{
 			MethodName: "Get",
 			Handler:    _Kurma_Get_Handler,
 		},
+		{
+			MethodName: "Stats",
+			Handler:    _Kurma_Stats_Handler,
+		},
 	},
 	Streams: []grpc.StreamDesc{
 		{
@@ -411,5 +887,26 @@ var _Kurma_serviceDesc = grpc.ServiceDesc{
 			ServerStreams: true,
 			ClientStreams: true,
 		},
+		{
+			StreamName:    "Watch",
+			Handler:       _Kurma_Watch_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "Logs",
+			Handler:       _Kurma_Logs_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "Exec",
+			Handler:       _Kurma_Exec_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "StreamStats",
+			Handler:       _Kurma_StreamStats_Handler,
+			ServerStreams: true,
+		},
 	},
 }
@@ -0,0 +1,46 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package client
+
+import (
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+)
+
+// RPCObserver is called as each call handled by the server completes, with the
+// name of the method, how long the call took, and the error it returned.
+type RPCObserver func(method string, duration time.Duration, err error)
+
+// RegisterInstrumentedKurmaServer registers the server in the same way as
+// RegisterKurmaServer, while reporting every completed call to the observer.
+func RegisterInstrumentedKurmaServer(s *grpc.Server, srv KurmaServer, observe RPCObserver) {
+	desc := _Kurma_serviceDesc
+
+	desc.Methods = make([]grpc.MethodDesc, len(_Kurma_serviceDesc.Methods))
+	for i, m := range _Kurma_serviceDesc.Methods {
+		name, handler := m.MethodName, m.Handler
+		m.Handler = func(srv interface{}, ctx context.Context, codec grpc.Codec, buf []byte) (interface{}, error) {
+			start := time.Now()
+			out, err := handler(srv, ctx, codec, buf)
+			observe(name, time.Since(start), err)
+			return out, err
+		}
+		desc.Methods[i] = m
+	}
+
+	desc.Streams = make([]grpc.StreamDesc, len(_Kurma_serviceDesc.Streams))
+	for i, sd := range _Kurma_serviceDesc.Streams {
+		name, handler := sd.StreamName, sd.Handler
+		sd.Handler = func(srv interface{}, stream grpc.ServerStream) error {
+			start := time.Now()
+			err := handler(srv, stream)
+			observe(name, time.Since(start), err)
+			return err
+		}
+		desc.Streams[i] = sd
+	}
+
+	s.RegisterService(&desc, srv)
+}
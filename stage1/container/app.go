@@ -0,0 +1,312 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package container
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	kschema "github.com/apcera/kurma/schema"
+	"github.com/apcera/kurma/stage1/image"
+	"github.com/apcera/util/envmap"
+	"github.com/appc/spec/schema"
+	"github.com/appc/spec/schema/types"
+)
+
+// appsDirectory is the location within the pod's root filesystem where each
+// app's image is extracted when the pod contains multiple apps.
+const appsDirectory = "opt/stage2"
+
+// PodApp describes an app to be run within a pod, along with the ACI it is run
+// from.
+type PodApp struct {
+	Name          string
+	ImageManifest *schema.ImageManifest
+	Image         io.ReadCloser
+}
+
+// app tracks an individual app running within the container's pod.
+type app struct {
+	name        string
+	image       *schema.ImageManifest
+	environment *envmap.EnvMap
+	restarts    int
+
+	initialImageFile io.ReadCloser
+}
+
+// key returns a flat form of the app's name, which is used where the app is
+// given a file or directory of its own. App names may contain slashes, while
+// underscores aren't valid within them, so the key is unique within the pod.
+func (a *app) key() string {
+	return strings.Replace(a.name, "/", "_", -1)
+}
+
+// restartPolicy returns the restart policy isolator for the app, or nil if one
+// was not specified.
+func (a *app) restartPolicy() *kschema.RestartPolicy {
+	if iso := a.image.App.Isolators.GetByName(kschema.RestartPolicyName); iso != nil {
+		if riso, ok := iso.Value().(*kschema.RestartPolicy); ok {
+			return riso
+		}
+	}
+	return nil
+}
+
+// command returns the command arguments with any references to the app's
+// environment variables filled in.
+func (a *app) command(exec types.Exec) []string {
+	envmap := a.environment.Map()
+	envfunc := func(env string) string { return envmap[env] }
+	cmdargs := make([]string, len(exec))
+	for i, s := range exec {
+		cmdargs[i] = os.Expand(s, envfunc)
+	}
+	return cmdargs
+}
+
+// workingDirectory returns the directory the app's processes are started in.
+func (a *app) workingDirectory() string {
+	if a.image.App.WorkingDirectory == "" {
+		return "/"
+	}
+	return a.image.App.WorkingDirectory
+}
+
+// isHostPrivileged returns whether the image specifies the host privileged
+// isolator.
+func isHostPrivileged(image *schema.ImageManifest) bool {
+	if iso := image.App.Isolators.GetByName(kschema.HostPrivlegedName); iso != nil {
+		if piso, ok := iso.Value().(*kschema.HostPrivileged); ok {
+			return bool(*piso)
+		}
+	}
+	return false
+}
+
+// cpuLimit returns the limit from the image's resource/cpu isolator in
+// milliseconds of CPU time per second, and whether a limit was specified.
+func cpuLimit(image *schema.ImageManifest) (int64, bool) {
+	if iso := image.App.Isolators.GetByName(types.ResourceCPUName); iso != nil {
+		if ciso, ok := iso.Value().(*types.ResourceCPU); ok && ciso.Limit() != nil {
+			return ciso.Limit().MilliValue(), true
+		}
+	}
+	return 0, false
+}
+
+// memoryLimit returns the limit from the image's resource/memory isolator in
+// bytes, and whether a limit was specified.
+func memoryLimit(image *schema.ImageManifest) (int64, bool) {
+	if iso := image.App.Isolators.GetByName(types.ResourceMemoryName); iso != nil {
+		if miso, ok := iso.Value().(*types.ResourceMemory); ok && miso.Limit() != nil {
+			return miso.Limit().Value(), true
+		}
+	}
+	return 0, false
+}
+
+// droppedCapabilities returns the sorted list of capabilities which should be
+// dropped from the apps based on the image's capabilities isolators. A retain
+// set drops every capability not listed, while a revoke set drops only those
+// listed. Capabilities the kernel doesn't support are never held, so they're
+// left out.
+func droppedCapabilities(image *schema.ImageManifest) ([]int, error) {
+	retain := image.App.Isolators.GetByName(types.LinuxCapabilitiesRetainSetName)
+	revoke := image.App.Isolators.GetByName(types.LinuxCapabilitiesRevokeSetName)
+	if retain != nil && revoke != nil {
+		return nil, fmt.Errorf("only one of the %s and %s isolators may be specified",
+			types.LinuxCapabilitiesRetainSetName, types.LinuxCapabilitiesRevokeSetName)
+	}
+
+	last := lastCapability()
+	var drop []int
+	switch {
+	case retain != nil:
+		set, ok := retain.Value().(types.LinuxCapabilitiesSet)
+		if !ok {
+			return nil, nil
+		}
+		keep := make(map[int]bool)
+		for _, name := range set.Set() {
+			c, err := parseCapability(string(name))
+			if err != nil {
+				return nil, err
+			}
+			keep[c] = true
+		}
+		for _, c := range capabilityNames {
+			if c <= last && !keep[c] {
+				drop = append(drop, c)
+			}
+		}
+
+	case revoke != nil:
+		set, ok := revoke.Value().(types.LinuxCapabilitiesSet)
+		if !ok {
+			return nil, nil
+		}
+		seen := make(map[int]bool)
+		for _, name := range set.Set() {
+			c, err := parseCapability(string(name))
+			if err != nil {
+				return nil, err
+			}
+			if c <= last && !seen[c] {
+				seen[c] = true
+				drop = append(drop, c)
+			}
+		}
+	}
+
+	sort.Ints(drop)
+	return drop, nil
+}
+
+// podLimit totals the resource limit returned by limitFunc across the apps in
+// the pod. It returns false if any of the apps are unlimited.
+func (c *Container) podLimit(limitFunc func(*schema.ImageManifest) (int64, bool)) (int64, bool) {
+	var total int64
+	for _, a := range c.apps {
+		limit, ok := limitFunc(a.image)
+		if !ok {
+			return 0, false
+		}
+		total += limit
+	}
+	return total, true
+}
+
+// primaryApp returns the first app within the pod. Settings which apply to the
+// pod as a whole, such as its namespaces and host privilege, are taken from its
+// image.
+func (c *Container) primaryApp() *app {
+	return c.apps[0]
+}
+
+// findApp returns the app with the given name, or the pod's primary app if the
+// name is blank.
+func (c *Container) findApp(name string) (*app, error) {
+	if name == "" {
+		return c.primaryApp(), nil
+	}
+	for _, a := range c.apps {
+		if a.name == name {
+			return a, nil
+		}
+	}
+	return nil, fmt.Errorf("app %q not found in the pod", name)
+}
+
+// closeImageFiles closes any of the ACI files provided for the apps which have
+// not yet been consumed.
+func (c *Container) closeImageFiles() {
+	for _, a := range c.apps {
+		if a.initialImageFile != nil {
+			a.initialImageFile.Close()
+			a.initialImageFile = nil
+		}
+	}
+}
+
+// imageID calculates the image ID of the app's image, and rewinds the image
+// file so that it can then be extracted. Images which can't be rewound are
+// first spooled to a temporary file within the directory.
+func (a *app) imageID(directory string) (string, error) {
+	f, ok := a.initialImageFile.(io.ReadSeeker)
+	if !ok {
+		tmp, err := ioutil.TempFile(directory, "image")
+		if err != nil {
+			return "", err
+		}
+		os.Remove(tmp.Name())
+		_, err = io.Copy(tmp, a.initialImageFile)
+		a.initialImageFile.Close()
+		a.initialImageFile = tmp
+		if err != nil {
+			return "", err
+		}
+		f = tmp
+	}
+
+	if _, err := f.Seek(0, 0); err != nil {
+		return "", err
+	}
+	hash, err := image.ImageID(f)
+	if err != nil {
+		return "", err
+	}
+	if _, err := f.Seek(0, 0); err != nil {
+		return "", err
+	}
+	return hash, nil
+}
+
+// appPath returns the host path the app's image is extracted to. When the pod
+// has a single app, its image makes up the pod's root filesystem. Otherwise
+// each app is given its own directory within the pod's root filesystem.
+func (c *Container) appPath(a *app) string {
+	if len(c.apps) == 1 {
+		return c.directory
+	}
+	return filepath.Join(c.stage3Path(), appsDirectory, a.key())
+}
+
+// appRootfs returns the host path to the app's root filesystem.
+func (c *Container) appRootfs(a *app) string {
+	return filepath.Join(c.appPath(a), "rootfs")
+}
+
+// appInitdRoot returns the path to the app's root filesystem as seen by the
+// initd, which is passed along when starting the app's process. It is blank
+// when the app's root filesystem is the pod's.
+func (c *Container) appInitdRoot(a *app) string {
+	if len(c.apps) == 1 {
+		return ""
+	}
+	return filepath.Join("/", appsDirectory, a.key(), "rootfs")
+}
+
+// appLogPath returns the path, relative to the pod's root filesystem, that the
+// app's output stream is written to. A pod with a single app keeps its output
+// in /app.stdout and /app.stderr.
+func (c *Container) appLogPath(a *app, stream string) string {
+	if len(c.apps) == 1 {
+		return filepath.Join("/", "app."+stream)
+	}
+	return filepath.Join("/", a.key()+"."+stream)
+}
+
+// AppNames returns the names of the apps within the container's pod.
+func (c *Container) AppNames() []string {
+	names := make([]string, len(c.apps))
+	for i, a := range c.apps {
+		names[i] = a.name
+	}
+	return names
+}
+
+// LogPath returns the host path of the log that the named app's output stream,
+// either "stdout" or "stderr", is written to.
+func (c *Container) LogPath(name, stream string) (string, error) {
+	if stream != "stdout" && stream != "stderr" {
+		return "", fmt.Errorf("unknown output stream %q", stream)
+	}
+	for _, a := range c.apps {
+		if a.name == name {
+			return filepath.Join(c.stage3Path(), c.appLogPath(a, stream)), nil
+		}
+	}
+	return "", fmt.Errorf("app %q not found in the pod", name)
+}
+
+// Stage2LogPath returns the host path of the log that the output of the
+// container's stage2 process is written to.
+func (c *Container) Stage2LogPath() string {
+	return c.stage2LogPath()
+}
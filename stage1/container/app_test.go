@@ -0,0 +1,116 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package container
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/appc/spec/schema"
+	"github.com/appc/spec/schema/types"
+
+	. "github.com/apcera/util/testtool"
+)
+
+// imageWithIsolators returns an image manifest whose app has the isolators
+// given in JSON.
+func imageWithIsolators(t *testing.T, isolators string) *schema.ImageManifest {
+	var isos types.Isolators
+	TestExpectSuccess(t, json.Unmarshal([]byte(isolators), &isos))
+	return &schema.ImageManifest{App: &types.App{Isolators: isos}}
+}
+
+// setLastCapability makes the kernel appear to support capabilities up to
+// last, returning a function which restores the real value.
+func setLastCapability(t *testing.T, last string) func() {
+	path := filepath.Join(TempDir(t), "cap_last_cap")
+	TestExpectSuccess(t, ioutil.WriteFile(path, []byte(last+"\n"), 0644))
+	original := capLastCapPath
+	capLastCapPath = path
+	return func() { capLastCapPath = original }
+}
+
+func TestDroppedCapabilities(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+	defer setLastCapability(t, "37")()
+
+	// Without either isolator, nothing is dropped.
+	drop, err := droppedCapabilities(imageWithIsolators(t, `[]`))
+	TestExpectSuccess(t, err)
+	TestEqual(t, len(drop), 0)
+
+	// A revoke set drops only those listed, once each and in order.
+	drop, err = droppedCapabilities(imageWithIsolators(t, `[{
+		"name": "os/linux/capabilities-revoke-set",
+		"value": {"set": ["CAP_SYS_ADMIN", "net_raw", "CAP_CHOWN", "CAP_NET_RAW"]}
+	}]`))
+	TestExpectSuccess(t, err)
+	TestEqual(t, drop, []int{0, 13, 21})
+
+	// A retain set drops every capability not listed.
+	drop, err = droppedCapabilities(imageWithIsolators(t, `[{
+		"name": "os/linux/capabilities-retain-set",
+		"value": {"set": ["CAP_CHOWN", "kill"]}
+	}]`))
+	TestExpectSuccess(t, err)
+	TestEqual(t, len(drop), len(capabilityNames)-2)
+	for i, c := range drop {
+		if c == 0 || c == 5 {
+			Fatalf(t, "Retained capability %d was dropped", c)
+		}
+		if i > 0 && drop[i-1] >= c {
+			Fatalf(t, "Dropped capabilities are not sorted: %v", drop)
+		}
+	}
+
+	// Unknown capabilities and specifying both sets are errors.
+	failures := []string{
+		`[{"name": "os/linux/capabilities-revoke-set", "value": {"set": ["CAP_FOO"]}}]`,
+		`[{"name": "os/linux/capabilities-retain-set", "value": {"set": ["CAP_FOO"]}}]`,
+		`[{"name": "os/linux/capabilities-retain-set", "value": {"set": ["CAP_CHOWN"]}},
+		  {"name": "os/linux/capabilities-revoke-set", "value": {"set": ["CAP_KILL"]}}]`,
+	}
+	for _, isolators := range failures {
+		if _, err := droppedCapabilities(imageWithIsolators(t, isolators)); err == nil {
+			Fatalf(t, "Expected an error for the isolators %s", isolators)
+		}
+	}
+}
+
+func TestDroppedCapabilities_KernelLastCapability(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	// An older kernel which supports up to CAP_SETFCAP (31).
+	defer setLastCapability(t, "31")()
+	TestEqual(t, lastCapability(), 31)
+
+	// A retain set only drops the capabilities the kernel supports.
+	drop, err := droppedCapabilities(imageWithIsolators(t, `[{
+		"name": "os/linux/capabilities-retain-set",
+		"value": {"set": ["CAP_CHOWN"]}
+	}]`))
+	TestExpectSuccess(t, err)
+	TestEqual(t, len(drop), 31)
+	TestEqual(t, drop[len(drop)-1], 31)
+
+	// Revoking an unsupported capability is accepted, but nothing is dropped.
+	drop, err = droppedCapabilities(imageWithIsolators(t, `[{
+		"name": "os/linux/capabilities-revoke-set",
+		"value": {"set": ["CAP_AUDIT_READ", "CAP_KILL"]}
+	}]`))
+	TestExpectSuccess(t, err)
+	TestEqual(t, drop, []int{5})
+
+	// A kernel reporting more capabilities than are known, or an unreadable
+	// value, is limited to the known capabilities.
+	for _, last := range []string{"40", "unknown"} {
+		defer setLastCapability(t, last)()
+		TestEqual(t, lastCapability(), 37)
+	}
+	capLastCapPath = filepath.Join(TempDir(t), "missing")
+	TestEqual(t, lastCapability(), 37)
+}
@@ -4,16 +4,18 @@ package container
 
 import (
 	"fmt"
-	"io"
+	"net"
 	"os"
+	"strings"
 	"sync"
+	"syscall"
+	"time"
 
 	kschema "github.com/apcera/kurma/schema"
 	client2 "github.com/apcera/kurma/stage2/client"
 	client3 "github.com/apcera/kurma/stage3/client"
 	"github.com/apcera/kurma/util/cgroups"
 	"github.com/apcera/logray"
-	"github.com/apcera/util/envmap"
 	"github.com/appc/spec/schema"
 
 	_ "github.com/apcera/kurma/schema"
@@ -21,6 +23,16 @@ import (
 
 type ContainerState int
 
+const (
+	// defaultStopSignal is the signal sent to the apps when the container is
+	// stopped, if the image doesn't specify one.
+	defaultStopSignal = syscall.SIGTERM
+
+	// defaultStopGracePeriod is how long the apps are given to exit after the
+	// stop signal, if neither the request or the image specify one.
+	defaultStopGracePeriod = 10 * time.Second
+)
+
 const (
 	NEW = ContainerState(iota)
 	STARTING
@@ -28,28 +40,59 @@ const (
 	STOPPING
 	STOPPED
 	EXITED
+	FAILED
 )
 
+// String returns the name of the state.
+func (s ContainerState) String() string {
+	switch s {
+	case NEW:
+		return "NEW"
+	case STARTING:
+		return "STARTING"
+	case RUNNING:
+		return "RUNNING"
+	case STOPPING:
+		return "STOPPING"
+	case STOPPED:
+		return "STOPPED"
+	case EXITED:
+		return "EXITED"
+	case FAILED:
+		return "FAILED"
+	default:
+		return "UNKNOWN"
+	}
+}
+
 // Container represents the operation and management of an individual container
 // on the current system.
 type Container struct {
 	manager *Manager
 	log     *logray.Logger
 
-	image            *schema.ImageManifest
-	pod              *schema.PodManifest
-	uuid             string
-	initialImageFile io.ReadCloser
+	apps []*app
+	pod  *schema.PodManifest
+	uuid string
 
-	cgroup      *cgroups.Cgroup
-	directory   string
-	environment *envmap.EnvMap
+	cgroup    *cgroups.Cgroup
+	directory string
+	storage   StorageDriver
 
-	initdClient  client3.Client
-	shuttingDown bool
-	state        ContainerState
-	mutex        sync.Mutex
-	waitch       chan bool
+	userNamespaceOffset int
+
+	metadataToken    string
+	metadataListener net.Listener
+
+	initdClient     client3.Client
+	shuttingDown    bool
+	stopGracePeriod time.Duration
+	state           ContainerState
+	startupError    string
+	appStatuses     map[string]*client3.ProcessStatus
+	oomKills        int
+	mutex           sync.Mutex
+	waitch          chan bool
 }
 
 // Manifest returns the current pod manifest for the App Container
@@ -65,6 +108,77 @@ func (container *Container) State() ContainerState {
 	return container.state
 }
 
+// RestartCount returns the number of times the apps within the container have
+// been restarted by their restart policies.
+func (container *Container) RestartCount() int {
+	container.mutex.Lock()
+	defer container.mutex.Unlock()
+	restarts := 0
+	for _, a := range container.apps {
+		restarts += a.restarts
+	}
+	return restarts
+}
+
+// AppStatuses returns the most recent status of each of the named processes
+// within the container, including the exit code or signal for any processes
+// which have terminated.
+func (container *Container) AppStatuses() map[string]*client3.ProcessStatus {
+	container.mutex.Lock()
+	defer container.mutex.Unlock()
+	statuses := make(map[string]*client3.ProcessStatus, len(container.appStatuses))
+	for name, status := range container.appStatuses {
+		s := *status
+		statuses[name] = &s
+	}
+	return statuses
+}
+
+// OOMKills returns the number of times the kernel's OOM killer has been invoked
+// because the container reached its memory limit.
+func (container *Container) OOMKills() int {
+	container.mutex.Lock()
+	defer container.mutex.Unlock()
+	return container.oomKills
+}
+
+// StartupError returns the error which caused the container to fail to start,
+// if it is in the FAILED state.
+func (container *Container) StartupError() string {
+	container.mutex.Lock()
+	defer container.mutex.Unlock()
+	return container.startupError
+}
+
+// stopSignal returns the signal to send to the apps to request that they exit
+// when the container is stopped.
+func (container *Container) stopSignal() syscall.Signal {
+	if v, ok := container.primaryApp().image.Annotations.Get(kschema.StopSignalAnnotation); ok {
+		if sig, err := parseSignal(v); err == nil {
+			return sig
+		}
+	}
+	return defaultStopSignal
+}
+
+// gracePeriod returns how long to wait for the apps to exit after being sent
+// the stop signal before they're killed. A grace period given to Stop takes
+// precedence over the one specified by the image.
+func (container *Container) gracePeriod() time.Duration {
+	container.mutex.Lock()
+	d := container.stopGracePeriod
+	container.mutex.Unlock()
+	if d > 0 {
+		return d
+	}
+	if v, ok := container.primaryApp().image.Annotations.Get(kschema.StopGracePeriodAnnotation); ok {
+		if d, err := time.ParseDuration(v); err == nil {
+			return d
+		}
+	}
+	return defaultStopGracePeriod
+}
+
 // isShuttingDown returns whether the container is currently in the state of
 // being shut down. This is an internal flag, separate from the State.
 func (container *Container) isShuttingDown() bool {
@@ -76,30 +190,31 @@ func (container *Container) isShuttingDown() bool {
 // start is an internal function which launches and starts the processes within
 // the container.
 func (container *Container) start() {
-	container.mutex.Lock()
-	container.state = STARTING
-	container.mutex.Unlock()
+	container.setState(STARTING, "container is being created")
 
 	// loop over the container startup functions
 	for _, f := range containerStartup {
 		if err := f(container); err != nil {
-			// FIXME more error handling
 			container.log.Errorf("startup error: %v", err)
+			container.markFailed(err)
 			return
 		}
 	}
 
-	container.mutex.Lock()
-	container.state = RUNNING
-	container.mutex.Unlock()
+	container.setState(RUNNING, "container started")
+	container.persist()
 }
 
-// Stop triggers the shutdown of the Container.
-func (container *Container) Stop() error {
+// Stop triggers the shutdown of the Container. The apps are first sent their
+// stop signal and given the grace period to exit before they're killed. A
+// gracePeriod of 0 uses the grace period specified by the image, or the
+// default.
+func (container *Container) Stop(gracePeriod time.Duration) error {
 	container.mutex.Lock()
 	container.shuttingDown = true
-	container.state = STOPPING
+	container.stopGracePeriod = gracePeriod
 	container.mutex.Unlock()
+	container.setState(STOPPING, "stop requested")
 
 	// loop over the container stopping functions
 	for _, f := range containerStopping {
@@ -110,9 +225,7 @@ func (container *Container) Stop() error {
 		}
 	}
 
-	container.mutex.Lock()
-	container.state = STOPPED
-	container.mutex.Unlock()
+	container.setState(STOPPED, "container stopped")
 	return nil
 }
 
@@ -135,46 +248,142 @@ func (container *Container) ShortName() string {
 	return container.uuid
 }
 
+// ExecOptions describes a process to run within a container.
+type ExecOptions struct {
+	// App is the name of the app whose root filesystem and environment the
+	// process is run within. The pod's first app is used if it is blank.
+	App string
+
+	// Command is the command to run and its arguments.
+	Command []string
+
+	// Environment holds "KEY=VALUE" variables which are set in addition to,
+	// or in place of, the app's environment.
+	Environment []string
+
+	// WorkingDirectory is the directory the process is started in. The app's
+	// working directory is used if it is blank.
+	WorkingDirectory string
+
+	// User and Group are who the process runs as. The app's user and group
+	// are used if they're blank.
+	User  string
+	Group string
+
+	Stdin  *os.File
+	Stdout *os.File
+	Stderr *os.File
+}
+
 // Enter is used to load a console session within the container. It re-enters
 // the container through the stage2 rather than through the initd so that it can
-// easily stream in and out.
-func (c *Container) Enter(stream *os.File) error {
+// easily stream in and out. If no command is given, the user's shell from the
+// app's /etc/passwd is run, or the app's SHELL if the user has none listed.
+func (c *Container) Enter(opts *ExecOptions) error {
+	if len(opts.Command) == 0 {
+		a, err := c.findApp(opts.App)
+		if err != nil {
+			return err
+		}
+		user := opts.User
+		if user == "" {
+			user = a.image.App.User
+		}
+		shell, _ := a.environment.Get("SHELL")
+		opts.Command = []string{userShell(c.appRootfs(a), user, shell)}
+	}
+
+	p, err := c.Exec(opts)
+	if err != nil {
+		return err
+	}
+	_, err = p.Wait()
+	return err
+}
+
+// Exec launches a process within the container, joining the namespaces and
+// cgroup of its running processes through the stage2. The caller is
+// responsible for waiting on the returned process.
+func (c *Container) Exec(opts *ExecOptions) (*os.Process, error) {
+	a, err := c.findApp(opts.App)
+	if err != nil {
+		return nil, err
+	}
+	if len(opts.Command) == 0 {
+		return nil, fmt.Errorf("no command was given")
+	}
+	if c.cgroup == nil {
+		return nil, fmt.Errorf("the container is not running")
+	}
+
+	// the given environment is layered on top of the app's
+	environment := a.environment.NewChild()
+	for _, e := range opts.Environment {
+		parts := strings.SplitN(e, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid environment variable %q", e)
+		}
+		environment.Set(parts[0], parts[1])
+	}
+
+	// Check the command exists, since the stage2 can only report the failure
+	// to exec it through the process's exit code.
+	path, _ := environment.Get("PATH")
+	if err := findExecutable(c.appRootfs(a), opts.Command[0], path); err != nil {
+		return nil, err
+	}
+
 	launcher := &client2.Launcher{
-		Environment: c.environment.Strings(),
-		Taskfiles:   c.cgroup.TasksFiles(),
-		Stdin:       stream,
-		Stdout:      stream,
-		Stderr:      stream,
-		User:        c.image.App.User,
-		Group:       c.image.App.Group,
+		Environment:      environment.Strings(),
+		Taskfiles:        c.cgroup.TasksFiles(),
+		WorkingDirectory: opts.WorkingDirectory,
+		Stdin:            opts.Stdin,
+		Stdout:           opts.Stdout,
+		Stderr:           opts.Stderr,
+		User:             opts.User,
+		Group:            opts.Group,
+	}
+	if launcher.WorkingDirectory == "" {
+		launcher.WorkingDirectory = a.workingDirectory()
+	}
+	if launcher.User == "" {
+		launcher.User = a.image.App.User
+	}
+	if launcher.Group == "" {
+		launcher.Group = a.image.App.Group
+	}
+
+	// The pod's root filesystem only holds the apps' filesystems when it has
+	// multiple apps, so enter the app's within a new mount namespace.
+	if root := c.appInitdRoot(a); root != "" {
+		launcher.Directory = root
+		launcher.BindDirectory = client3.DefaultChrootPath
+		launcher.NewMountNamespace = true
+		launcher.Chroot = true
 	}
 
 	// Check for a privileged isolator
-	if iso := c.image.App.Isolators.GetByName(kschema.HostPrivlegedName); iso != nil {
-		if piso, ok := iso.Value().(*kschema.HostPrivileged); ok {
-			if *piso {
-				launcher.HostPrivileged = true
-			}
-		}
+	launcher.HostPrivileged = isHostPrivileged(a.image)
+
+	// Determine the capabilities the process isn't permitted to have
+	caps, err := droppedCapabilities(a.image)
+	if err != nil {
+		return nil, err
 	}
+	launcher.DropCapabilities = caps
 
 	// Get a process from the container and copy its namespaces
 	tasks, err := c.cgroup.Tasks()
 	if err != nil {
-		return err
+		return nil, err
 	}
 	if len(tasks) == 0 {
-		return fmt.Errorf("no processes are running inside the container")
+		return nil, fmt.Errorf("no processes are running inside the container")
 	}
 	launcher.SetNS(tasks[0])
 
 	// launch!
-	p, err := launcher.Run("/bin/bash")
-	if err != nil {
-		return err
-	}
-	_, err = p.Wait()
-	return err
+	return launcher.Run(opts.Command...)
 }
 
 // getInitdClient is an accessor to get current initd client object. This should
@@ -188,14 +397,58 @@ func (c *Container) getInitdClient() client3.Client {
 	return c.initdClient
 }
 
-// markFailed is used to transition the container to the exited state.
-func (c *Container) markExited() {
+// markExited is used to transition the container to the exited state. The
+// reason is included in the event sent to any subscribers.
+func (c *Container) markExited(reason string) {
 	c.mutex.Lock()
-	if c.state != EXITED {
+	exited := c.state == EXITED || c.state == FAILED
+	if !exited {
 		close(c.waitch)
+		c.state = EXITED
 	}
-	c.state = EXITED
 	c.mutex.Unlock()
+	if !exited {
+		c.publishState(EXITED, reason)
+	}
+	c.persist()
+}
+
+// markFailed is used to transition the container to the failed state when an
+// error occurs during startup. The error is retained so that it can be
+// reported, and any resources already set up for the container are released.
+// The container remains in the Manager until it is destroyed.
+func (c *Container) markFailed(err error) {
+	c.mutex.Lock()
+	if c.state != EXITED && c.state != FAILED {
+		close(c.waitch)
+	}
+	c.state = FAILED
+	c.startupError = err.Error()
+	initdClient := c.initdClient
+	c.mutex.Unlock()
+	c.closeImageFiles()
+	c.publishState(FAILED, err.Error())
+
+	if initdClient != nil {
+		initdClient.Stop()
+	}
+
+	// loop over the container cleanup functions
+	for _, f := range containerCleanup {
+		if err := f(c); err != nil {
+			c.log.Errorf("cleanup error: %v", err)
+		}
+	}
+
+	// the cleanup removes the container's directory, so recreate it to hold the
+	// state record alone. This keeps the failure and its error reported after
+	// the daemon restarts, until the container is destroyed.
+	if c.directory != "" {
+		if err := os.MkdirAll(c.directory, os.FileMode(0755)); err != nil {
+			c.log.Errorf("failed to recreate the container directory: %v", err)
+		}
+	}
+	c.persist()
 }
 
 // Wait can be used to block until the processes within a container are finished
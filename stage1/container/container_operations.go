@@ -13,8 +13,7 @@ import (
 	"github.com/apcera/kurma/schema"
 	"github.com/apcera/kurma/stage3/client"
 	"github.com/apcera/util/envmap"
-	"github.com/apcera/util/hashutil"
-	"github.com/apcera/util/tarhelper"
+	"github.com/appc/spec/schema/types"
 )
 
 var (
@@ -24,18 +23,33 @@ var (
 		(*Container).startingBaseDirectories,
 		(*Container).startingFilesystem,
 		(*Container).startingNetworking,
+		(*Container).startingVolumes,
 		(*Container).startingEnvironment,
 		(*Container).startingCgroups,
+		(*Container).startingPreStartHooks,
 		(*Container).launchStage2,
+		(*Container).startingPostStartHooks,
 	}
 
 	// These are the functions that will be called in order to handle container
 	// teardown.
 	containerStopping = []func(*Container) error{
+		(*Container).stoppingApps,
+		(*Container).stoppingAppHooks,
+		(*Container).stoppingMetadata,
 		(*Container).stoppingCgroups,
+		(*Container).stoppingPostStopHooks,
 		(*Container).stoppingDirectories,
 		(*Container).stoppingrRemoveFromParent,
 	}
+
+	// These are the functions that will be called in order to release the
+	// resources of a container which failed to start.
+	containerCleanup = []func(*Container) error{
+		(*Container).stoppingMetadata,
+		(*Container).stoppingCgroups,
+		(*Container).stoppingDirectories,
+	}
 )
 
 // startingBaseDirectories handles creating the directory to store the container
@@ -53,51 +67,95 @@ func (c *Container) startingBaseDirectories() error {
 		return err
 	}
 
+	// With multiple apps, each app gets its own directory within the pod's
+	// root filesystem.
+	if len(c.apps) > 1 {
+		appDirs := []string{
+			c.stage3Path(),
+			filepath.Join(c.stage3Path(), filepath.Dir(appsDirectory)),
+			filepath.Join(c.stage3Path(), appsDirectory),
+		}
+		for _, a := range c.apps {
+			appDirs = append(appDirs, c.appPath(a))
+		}
+		if err := mkdirs(appDirs, mode, true); err != nil {
+			return err
+		}
+		dirs = append(dirs, appDirs...)
+	}
+
 	// Ensure the directories are owned by the uid/gid that is root inside the
 	// container
-	// if err := chowns(dirs, c.manager.namespaceUidOffset, c.manager.namespaceGidOffset); err != nil {
-	// 	return err
-	// }
+	if c.usesUserNamespace() {
+		if err := chowns(dirs, c.userNamespaceOffset, c.userNamespaceOffset); err != nil {
+			return err
+		}
+	}
+
+	// Record the container as starting as soon as it has a directory, so that
+	// whatever it has set up can be released if the daemon restarts before the
+	// startup completes.
+	if err := c.saveState(); err != nil {
+		return fmt.Errorf("failed to save container state: %v", err)
+	}
 
 	c.log.Debug("Done setting up directories.")
 	return nil
 }
 
-// startingFilesystem extracts the provided ACI file into the container
-// filesystem.
+// startingFilesystem creates the root filesystem of each app from its ACI file
+// and dependencies through the storage driver.
 func (c *Container) startingFilesystem() error {
 	c.log.Debug("Setting up stage2 filesystem")
 
-	if c.initialImageFile == nil {
-		c.log.Error("Initial image filesystem is nil")
-		return fmt.Errorf("initial image filesystem is nil")
-	}
+	defer c.closeImageFiles()
 
-	defer func() {
-		c.initialImageFile.Close()
-		c.initialImageFile = nil
-	}()
+	for i, a := range c.apps {
+		if a.initialImageFile == nil {
+			c.log.Errorf("Initial image filesystem for %q is nil", a.name)
+			return fmt.Errorf("initial image filesystem for %q is nil", a.name)
+		}
+
+		// handle reading the sha
+		imageID, err := a.imageID(c.directory)
+		if err != nil {
+			return fmt.Errorf("failed to read stage2 image for %q: %v", a.name, err)
+		}
 
-	// handle reading the sha
-	sr := hashutil.NewSha512(c.initialImageFile)
+		// The rendered image is shifted into the user namespace range, so it
+		// can only be shared with containers using the same range.
+		layer := imageID
+		if c.usesUserNamespace() {
+			layer = fmt.Sprintf("%s-%d", imageID, c.userNamespaceOffset)
+		}
 
-	// untar the file
-	tarfile := tarhelper.NewUntar(sr, filepath.Join(c.directory))
-	tarfile.PreserveOwners = true
-	tarfile.PreservePermissions = true
-	tarfile.Compression = tarhelper.DETECT
-	tarfile.AbsoluteRoot = c.directory
-	if err := tarfile.Extract(); err != nil {
-		return fmt.Errorf("failed to extract stage2 image filesystem: %v", err)
-	}
+		render := func(dir string) error {
+			// render the image's dependencies beneath it
+			if err := c.renderDependencies(a.image, dir, 0); err != nil {
+				return fmt.Errorf("failed to render the dependencies for %q: %v", a.name, err)
+			}
 
-	// put the hash on the pod manifest
-	for i, app := range c.pod.Apps {
-		if app.Image.Name.Equals(c.image.Name) {
-			if err := app.Image.ID.Set(fmt.Sprintf("sha512-%s", sr.Sha512())); err != nil {
-				return err
+			// untar the file
+			if err := extractImage(a.initialImageFile, dir); err != nil {
+				return fmt.Errorf("failed to extract stage2 image filesystem for %q: %v", a.name, err)
 			}
-			c.pod.Apps[i] = app
+			if err := applyPathWhitelist(filepath.Join(dir, "rootfs"), a.image.PathWhitelist); err != nil {
+				return fmt.Errorf("failed to apply the path whitelist for %q: %v", a.name, err)
+			}
+
+			// shift the ownership of the filesystem into the user namespace
+			if err := c.shiftOwnership(dir); err != nil {
+				return fmt.Errorf("failed to set ownership of the stage2 image filesystem for %q: %v", a.name, err)
+			}
+			return nil
+		}
+		if err := c.storage.Prepare(c.storageID(a), c.appPath(a), layer, render); err != nil {
+			return err
+		}
+
+		// put the hash on the pod manifest
+		if err := c.pod.Apps[i].Image.ID.Set(imageID); err != nil {
+			return err
 		}
 	}
 
@@ -111,58 +169,175 @@ func (c *Container) startingNetworking() error {
 	c.log.Debug("Configuring network for container")
 
 	if _, err := os.Lstat("/etc/resolv.conf"); err == nil {
-		etcPath, err := c.ensureContainerPathExists("etc")
-		if err != nil {
+		for _, a := range c.apps {
+			if err := c.copyResolvConf(a); err != nil {
+				return err
+			}
+		}
+	}
+
+	c.log.Debug("Done configuring networking")
+	return nil
+}
+
+// copyResolvConf copies the host's resolv.conf into the app's filesystem.
+func (c *Container) copyResolvConf(a *app) error {
+	etcPath, err := c.ensureContainerPathExists(c.appRootfs(a), "etc")
+	if err != nil {
+		return err
+	}
+	resolvPath := filepath.Join(etcPath, "resolv.conf")
+
+	if _, err := os.Lstat(resolvPath); err == nil {
+		if err := os.RemoveAll(resolvPath); err != nil {
 			return err
 		}
-		resolvPath := filepath.Join(etcPath, "resolv.conf")
+	}
 
-		if _, err := os.Lstat(resolvPath); err == nil {
-			if err := os.RemoveAll(resolvPath); err != nil {
-				return err
+	hf, err := os.Open("/etc/resolv.conf")
+	if err != nil {
+		return err
+	}
+	defer hf.Close()
+
+	cf, err := os.Create(resolvPath)
+	if err != nil {
+		return err
+	}
+	defer cf.Close()
+
+	if _, err := io.Copy(cf, hf); err != nil {
+		return err
+	}
+	return c.chownToNamespace(resolvPath, 0, 0)
+}
+
+// startingVolumes bind mounts the pod's volumes into each app's filesystem at
+// the app's mount points. Volumes of kind "host" are a directory on the host,
+// while "empty" volumes are a new directory created for the pod.
+func (c *Container) startingVolumes() error {
+	if len(c.pod.Volumes) == 0 {
+		return nil
+	}
+	c.log.Debug("Setting up volumes")
+
+	// resolve the host directory backing each volume
+	sources := make(map[types.ACName]string)
+	for _, v := range c.pod.Volumes {
+		source, err := c.volumeSource(v)
+		if err != nil {
+			return fmt.Errorf("failed to set up volume %q: %v", v.Name, err)
+		}
+		sources[v.Name] = source
+	}
+
+	for _, a := range c.apps {
+		for _, mp := range a.image.App.MountPoints {
+			v := c.volume(mp.Name)
+			if v == nil {
+				return fmt.Errorf("no volume was provided for mount point %q of app %q", mp.Name, a.name)
+			}
+			readOnly := mp.ReadOnly || (v.ReadOnly != nil && *v.ReadOnly)
+			if err := c.mountVolume(a, sources[v.Name], mp.Path, readOnly); err != nil {
+				return fmt.Errorf("failed to mount volume %q for app %q: %v", v.Name, a.name, err)
 			}
 		}
+	}
 
-		hf, err := os.Open("/etc/resolv.conf")
-		if err != nil {
-			return err
+	c.log.Debug("Done setting up volumes")
+	return nil
+}
+
+// volume returns the pod's volume with the given name, or nil if the pod has no
+// such volume.
+func (c *Container) volume(name types.ACName) *types.Volume {
+	for i := range c.pod.Volumes {
+		if c.pod.Volumes[i].Name == name {
+			return &c.pod.Volumes[i]
 		}
-		defer hf.Close()
+	}
+	return nil
+}
 
-		cf, err := os.Create(resolvPath)
+// volumeSource returns the host directory which backs the volume, creating it
+// for empty volumes.
+func (c *Container) volumeSource(v types.Volume) (string, error) {
+	switch v.Kind {
+	case "host":
+		fi, err := os.Stat(v.Source)
 		if err != nil {
-			return err
+			return "", err
+		}
+		if !fi.IsDir() {
+			return "", fmt.Errorf("source %q is not a directory", v.Source)
 		}
-		defer cf.Close()
+		return v.Source, nil
 
-		if _, err := io.Copy(cf, hf); err != nil {
-			return err
+	case "empty":
+		if c.manager.volumeDirectory == "" {
+			return "", fmt.Errorf("no volume directory is configured")
 		}
+		source := filepath.Join(c.volumesPath(), v.Name.String())
+		if err := os.MkdirAll(source, os.FileMode(0755)); err != nil {
+			return "", err
+		}
+		if err := c.chownToNamespace(source, 0, 0); err != nil {
+			return "", err
+		}
+		return source, nil
+
+	default:
+		return "", fmt.Errorf("unrecognized volume kind %q", v.Kind)
 	}
+}
 
-	c.log.Debug("Done configuring networking")
+// mountVolume bind mounts the source directory at the path within the app's
+// root filesystem. The path is resolved within the root filesystem so that
+// symlinks within the image can't be used to mount outside of it.
+func (c *Container) mountVolume(a *app, source, path string, readOnly bool) error {
+	target, err := c.ensureContainerPathExists(c.appRootfs(a), path)
+	if err != nil {
+		return err
+	}
+
+	if err := syscall.Mount(source, target, "", syscall.MS_BIND|syscall.MS_REC, ""); err != nil {
+		return fmt.Errorf("failed to bind mount %q: %v", path, err)
+	}
+
+	// Read only bind mounts need a remount to apply the read only flag.
+	if readOnly {
+		flags := uintptr(syscall.MS_BIND | syscall.MS_REMOUNT | syscall.MS_RDONLY)
+		if err := syscall.Mount(source, target, "", flags, ""); err != nil {
+			return fmt.Errorf("failed to remount %q read only: %v", path, err)
+		}
+	}
 	return nil
 }
 
-// startingEnvironment sets up the environment variables for the container.
+// startingEnvironment sets up the environment variables for each of the apps
+// within the container.
 func (c *Container) startingEnvironment() error {
-	c.environment = envmap.NewEnvMap()
-	c.environment.Set("HOME", "/")
-	c.environment.Set("PATH", "/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin")
-	c.environment.Set("SHELL", "/bin/sh")
-	c.environment.Set("TMPDIR", "/tmp")
-	c.environment.Set("USER", c.image.App.User)
-	c.environment.Set("LOGNAME", c.image.App.User)
-
-	c.environment.Set("AC_APP_NAME", c.image.Name.String())
-	// FIXME set AC_METADATA_URL once metadata API is added
+	for _, a := range c.apps {
+		environment := envmap.NewEnvMap()
+		environment.Set("HOME", "/")
+		environment.Set("PATH", "/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin")
+		environment.Set("SHELL", "/bin/sh")
+		environment.Set("TMPDIR", "/tmp")
+		environment.Set("USER", a.image.App.User)
+		environment.Set("LOGNAME", a.image.App.User)
+
+		environment.Set("AC_APP_NAME", a.name)
+		if c.usesMetadata() {
+			environment.Set("AC_METADATA_URL", c.metadataURL())
+		}
 
-	// Add the application's environment
-	appenv := c.environment.NewChild()
-	for _, env := range c.image.App.Environment {
-		appenv.Set(env.Name, env.Value)
+		// Add the application's environment
+		appenv := environment.NewChild()
+		for _, env := range a.image.App.Environment {
+			appenv.Set(env.Name, env.Value)
+		}
+		a.environment = appenv
 	}
-	c.environment = appenv
 
 	return nil
 }
@@ -178,15 +353,64 @@ func (c *Container) startingCgroups() error {
 		c.log.Debugf("Error setting up the cgroup: %v", err)
 		return err
 	} else {
+		c.mutex.Lock()
 		c.cgroup = cgroup
+		c.mutex.Unlock()
 	}
 
-	// FIXME add OOM notification handler
+	// Apply the resource limits. The apps share the cgroup, so the pod is only
+	// limited when every app specifies a limit, and then by their sum.
+	if limit, ok := c.podLimit(cpuLimit); ok {
+		c.log.Debugf("Limiting CPU to %d ms/sec.", limit)
+		if err := c.cgroup.LimitCPU(limit); err != nil {
+			return fmt.Errorf("failed to limit CPU: %v", err)
+		}
+	}
+	if limit, ok := c.podLimit(memoryLimit); ok {
+		c.log.Debugf("Limiting memory to %d bytes.", limit)
+		if err := c.cgroup.LimitMemory(limit); err != nil {
+			return fmt.Errorf("failed to limit memory: %v", err)
+		}
+	}
+
+	c.watchOOM()
 
 	c.log.Debug("Done setting up cgroup.")
 	return nil
 }
 
+// watchOOM registers for notifications of the OOM killer being invoked within
+// the container's cgroup, so that apps it kills aren't mistaken for having
+// exited on their own. Each is recorded on the container and published as an
+// event. Failing to register isn't fatal to the container.
+func (c *Container) watchOOM() {
+	ch, err := c.cgroup.NotifyOOM()
+	if err != nil {
+		c.log.Warnf("Failed to register for OOM notifications: %v", err)
+		return
+	}
+
+	go func() {
+		for n := range ch {
+			c.mutex.Lock()
+			c.oomKills += n
+			total := c.oomKills
+			state := c.state
+			c.mutex.Unlock()
+
+			c.log.Errorf("Container reached its memory limit, the OOM killer has been invoked %d times", total)
+			c.manager.publish(&Event{
+				UUID:   c.uuid,
+				State:  state,
+				Time:   time.Now(),
+				Reason: "out of memory, the OOM killer was invoked",
+				OOM:    true,
+			})
+			c.persist()
+		}
+	}()
+}
+
 // Start the initd. This doesn't actually configure it, just starts it so we
 // have a process and namespace to work with in the networking side of the
 // world.
@@ -212,15 +436,16 @@ func (c *Container) launchStage2() error {
 		Stderr:     stage2Stdout,
 	}
 
-	// Configure which linux namespaces to create
+	// Configure which linux namespaces to create. These are shared by all the
+	// apps, so they're taken from the pod's primary app.
+	image := c.primaryApp().image
 	nsisolators := false
-	if iso := c.image.App.Isolators.GetByName(schema.LinuxNamespacesName); iso != nil {
+	if iso := image.App.Isolators.GetByName(schema.LinuxNamespacesName); iso != nil {
 		if niso, ok := iso.Value().(*schema.LinuxNamespaces); ok {
 			launcher.NewIPCNamespace = niso.IPC()
 			launcher.NewMountNamespace = niso.Mount()
 			launcher.NewNetworkNamespace = niso.Net()
 			launcher.NewPIDNamespace = niso.PID()
-			launcher.NewUserNamespace = niso.User()
 			launcher.NewUTSNamespace = niso.UTS()
 			nsisolators = true
 		}
@@ -233,14 +458,22 @@ func (c *Container) launchStage2() error {
 		launcher.NewUTSNamespace = true
 	}
 
+	// Configure the user namespace, mapping the container's range of ids
+	if c.usesUserNamespace() {
+		launcher.NewUserNamespace = true
+		launcher.Uidmap = c.userNamespaceMap()
+		launcher.Gidmap = c.userNamespaceMap()
+	}
+
 	// Check for a privileged isolator
-	if iso := c.image.App.Isolators.GetByName(schema.HostPrivlegedName); iso != nil {
-		if piso, ok := iso.Value().(*schema.HostPrivileged); ok {
-			if *piso {
-				launcher.HostPrivileged = true
-			}
-		}
+	launcher.HostPrivileged = isHostPrivileged(image)
+
+	// Determine the capabilities the apps aren't permitted to have
+	caps, err := droppedCapabilities(image)
+	if err != nil {
+		return err
 	}
+	launcher.DropCapabilities = caps
 
 	client, err := launcher.Run()
 	if err != nil {
@@ -250,30 +483,21 @@ func (c *Container) launchStage2() error {
 	c.initdClient = client
 	c.mutex.Unlock()
 
-	// iterate the command arguments and fill in any potential environment variable references
-	envmap := c.environment.Map()
-	envfunc := func(env string) string { return envmap[env] }
-	cmdargs := make([]string, len(c.image.App.Exec))
-	copy(cmdargs, c.image.App.Exec)
-	for i, s := range cmdargs {
-		cmdargs[i] = os.Expand(s, envfunc)
+	// make the metadata service reachable before the apps start
+	if err := c.serveMetadata(); err != nil {
+		return err
 	}
 
-	// validate the working directory
-	workingDirectory := c.image.App.WorkingDirectory
-	if workingDirectory == "" {
-		workingDirectory = "/"
+	// run the apps' pre-start event handlers, then launch the apps
+	for _, a := range c.apps {
+		if err := c.runAppHooks(client, a, PreStartHook); err != nil {
+			return err
+		}
 	}
-
-	c.log.Tracef("Launching application [%q:%q]: %#v", c.image.App.User, c.image.App.Group, cmdargs)
-	c.log.Tracef("Application environment: %#v", c.environment.Strings())
-	err = client.Start(
-		"app", cmdargs, workingDirectory, c.environment.Strings(),
-		"/app.stdout", "/app.stderr",
-		c.image.App.User, c.image.App.Group,
-		time.Second*5)
-	if err != nil {
-		return err
+	for _, a := range c.apps {
+		if err := c.startApp(client, a); err != nil {
+			return err
+		}
 	}
 
 	// Start a goroutine to handle transitioning to the exited state when all
@@ -284,6 +508,19 @@ func (c *Container) launchStage2() error {
 	return nil
 }
 
+// startApp requests the initd to launch the app's process. It is used both for
+// the initial launch of the container and when an app is restarted.
+func (c *Container) startApp(initdClient client.Client, a *app) error {
+	cmdargs := a.command(a.image.App.Exec)
+	c.log.Tracef("Launching app %q [%q:%q]: %#v", a.name, a.image.App.User, a.image.App.Group, cmdargs)
+	c.log.Tracef("App environment: %#v", a.environment.Strings())
+	return initdClient.Start(
+		a.name, cmdargs, a.workingDirectory(), a.environment.Strings(),
+		c.appLogPath(a, "stdout"), c.appLogPath(a, "stderr"),
+		a.image.App.User, a.image.App.Group, c.appInitdRoot(a),
+		time.Second*5)
+}
+
 // waitLoop continously runs a combination of 'WAIT' and 'STATUS' on the initd
 // client in order to get notifications of process termination.
 func (c *Container) waitLoop() {
@@ -305,12 +542,29 @@ func (c *Container) waitLoop() {
 	// If any processes exited abnormally, container is marked as
 	// failed. If there are any processes still running in the container,
 	// the loop is re-entered and gets blocked on Wait() again.
+	//
+	// Apps are restarted after their backoff by a timer rather than within the
+	// loop, so that the other apps are still tracked in the meantime. Each app
+	// has at most one restart pending, so the channel never blocks the timers.
+	restarted := make(chan *app, len(c.apps))
+	pending := make(map[*app]bool)
 	for {
 		if c.isShuttingDown() || initdClient.Stopped() {
 			c.log.Info("Container is shutting down, exiting wait loop")
 			return
 		}
 
+		// Collect the restarts which have happened since the last pass.
+	drain:
+		for {
+			select {
+			case a := <-restarted:
+				delete(pending, a)
+			default:
+				break drain
+			}
+		}
+
 		// TODO(oleg): do we even need to retry on failed WAIT?
 		waitMaxErrors := 3
 		waitErrors := 0
@@ -321,7 +575,7 @@ func (c *Container) waitLoop() {
 				waitErrors++
 				if waitErrors >= waitMaxErrors {
 					c.log.Errorf("Marking container as failed after %d Wait() errors", waitMaxErrors)
-					c.markExited()
+					c.markExited(fmt.Sprintf("failed to wait on processes: %v", err))
 					return
 				} else {
 					if c.isShuttingDown() {
@@ -342,26 +596,175 @@ func (c *Container) waitLoop() {
 				return
 			}
 			c.log.Error("Marking container as failed after Status() error")
-			c.markExited()
+			c.markExited(fmt.Sprintf("failed to retrieve process status: %v", err))
 			return
 		}
 
-		nProcsRunning := 0
+		// the app hooks aren't tracked as part of the container's status
+		for name := range statuses {
+			if isHookProcess(name) {
+				delete(statuses, name)
+			}
+		}
 
+		c.mutex.Lock()
+		c.appStatuses = statuses
+		c.mutex.Unlock()
+
+		nProcsRunning := 0
 		for _, status := range statuses {
-			if status == "running" {
+			if status.Running {
 				nProcsRunning++
 			}
 		}
 
+		// Check each app which is no longer running against its restart policy.
+		// The pod is only considered exited once none of its apps are running.
+		for _, a := range c.apps {
+			status, ok := statuses[a.name]
+			if !ok || status.Running || pending[a] {
+				continue
+			}
+			failed := status.Failed()
+			if failed {
+				c.log.Warnf("App %q terminated: %s", a.name, status)
+			}
+			if !c.shouldRestart(a, failed) {
+				continue
+			}
+			pending[a] = true
+			c.scheduleRestart(initdClient, a, restarted)
+		}
+
+		// Wait for a pending restart when nothing else is running, as Wait()
+		// returns immediately without any running processes.
+		if nProcsRunning == 0 && len(pending) > 0 {
+			delete(pending, <-restarted)
+			continue
+		}
+
 		if nProcsRunning == 0 {
 			c.log.Debugf("There were no running processes in the container, tearing it down, marking exited.")
-			c.markExited()
+			reason := exitReason(statuses)
+			if n := c.OOMKills(); n > 0 {
+				reason = fmt.Sprintf("%s (the OOM killer was invoked %d times)", reason, n)
+			}
+			c.markExited(reason)
 			return
 		}
 	}
 }
 
+// shouldRestart checks the app's restart policy to determine whether it should
+// be relaunched after it has exited.
+func (c *Container) shouldRestart(a *app, failed bool) bool {
+	policy := a.restartPolicy()
+	if policy == nil || c.isShuttingDown() {
+		return false
+	}
+
+	c.mutex.Lock()
+	restarts := a.restarts
+	c.mutex.Unlock()
+	if policy.MaxRetries > 0 && restarts >= policy.MaxRetries {
+		c.log.Warnf("App %q has reached its maximum of %d restarts", a.name, policy.MaxRetries)
+		return false
+	}
+
+	switch policy.Policy {
+	case schema.RestartAlways:
+		return true
+	case schema.RestartOnFailure:
+		return failed
+	default:
+		return false
+	}
+}
+
+// scheduleRestart relaunches the app within the existing rootfs and cgroup
+// once the backoff for its restart policy has passed. The app is sent on done
+// after the restart has been attempted.
+func (c *Container) scheduleRestart(initdClient client.Client, a *app, done chan<- *app) {
+	c.mutex.Lock()
+	restarts := a.restarts
+	c.mutex.Unlock()
+	delay := a.restartPolicy().Backoff(restarts)
+	c.log.Infof("App %q exited, restarting in %v (restart %d)", a.name, delay, restarts+1)
+
+	time.AfterFunc(delay, func() {
+		defer func() { done <- a }()
+		if c.isShuttingDown() {
+			c.log.Info("Container is shutting down, skipping restart")
+		} else if err := c.restartApp(initdClient, a); err != nil {
+			c.log.Errorf("Failed to restart app %q: %v", a.name, err)
+		}
+	})
+}
+
+// restartApp relaunches the app, counting the attempt against its restart
+// policy even if it fails so that an app which can't be launched isn't retried
+// indefinitely.
+func (c *Container) restartApp(initdClient client.Client, a *app) error {
+	if initdClient.Stopped() {
+		return fmt.Errorf("container is shutting down")
+	}
+
+	c.mutex.Lock()
+	a.restarts++
+	c.mutex.Unlock()
+	defer c.persist()
+	return c.startApp(initdClient, a)
+}
+
+// stoppingApps sends the stop signal to the apps within the container and
+// waits for the grace period for the initd to report that none of them are
+// still running. Any which remain will be killed when the cgroup is torn down.
+func (c *Container) stoppingApps() error {
+	initdClient := c.getInitdClient()
+	if c.cgroup == nil || initdClient == nil || initdClient.Stopped() {
+		return nil
+	}
+
+	// Check whether anything is still running before signaling.
+	statuses, err := initdClient.Status(time.Second)
+	if err != nil || !anyRunning(statuses) {
+		return nil
+	}
+
+	// The signal is sent through the initd to each app's process only. The
+	// initd itself, and any event handlers it is running, need to stay up so
+	// that the post-stop event handlers can be run once the apps exit.
+	signal := c.stopSignal()
+	gracePeriod := c.gracePeriod()
+	c.log.Debugf("Sending %v to the apps, waiting up to %v for them to exit.", signal, gracePeriod)
+	for name, status := range statuses {
+		if !status.Running || isHookProcess(name) {
+			continue
+		}
+		if err := initdClient.Signal(name, signal, time.Second); err != nil {
+			c.log.Warnf("Failed to signal app %q: %v", name, err)
+			return nil
+		}
+	}
+
+	deadline := time.Now().Add(gracePeriod)
+	for time.Now().Before(deadline) {
+		statuses, err := initdClient.Status(time.Second)
+		if err != nil {
+			c.log.Warnf("Failed to retrieve process status while stopping: %v", err)
+			return nil
+		}
+		if !anyRunning(statuses) {
+			c.log.Trace("All apps have exited.")
+			return nil
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	c.log.Warnf("Apps did not exit within %v, killing them.", gracePeriod)
+	return nil
+}
+
 // stoppingCgroups handles terminating all of the processes belonging to the
 // current container's cgroup and then deleting the cgroup itself.
 func (c *Container) stoppingCgroups() error {
@@ -428,6 +831,19 @@ func (c *Container) stoppingDirectories() error {
 		return err
 	}
 
+	// Release the app filesystems from the storage driver.
+	for _, a := range c.apps {
+		if err := c.storage.Release(c.storageID(a), c.appPath(a)); err != nil {
+			return err
+		}
+	}
+
+	// Remove any storage which was only used by this container. Failing to do
+	// so doesn't prevent the container from being removed.
+	if err := c.storage.Collect(); err != nil {
+		c.log.Warnf("failed to collect unused storage: %v", err)
+	}
+
 	// Remove the directory that was created for this container, unless it is
 	// specified to keep it.
 	if err := os.RemoveAll(c.directory); err != nil {
@@ -436,6 +852,15 @@ func (c *Container) stoppingDirectories() error {
 		}
 	}
 
+	// Remove the container's empty volumes, they only live as long as the pod.
+	if c.manager.volumeDirectory != "" {
+		if err := os.RemoveAll(c.volumesPath()); err != nil {
+			if !os.IsNotExist(err) {
+				return err
+			}
+		}
+	}
+
 	c.log.Trace("Done tearing down container directories.")
 	return nil
 }
@@ -0,0 +1,140 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package container
+
+import (
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/apcera/kurma/stage3/client"
+	"github.com/apcera/util/envmap"
+	"github.com/appc/spec/schema"
+
+	. "github.com/apcera/util/testtool"
+)
+
+// restartInitd is an initd client whose started processes exit with a
+// failure shortly after they're launched.
+type restartInitd struct {
+	client.Client
+	mutex    sync.Mutex
+	statuses map[string]*client.ProcessStatus
+	starts   map[string]int
+	exited   chan bool
+}
+
+func newRestartInitd() *restartInitd {
+	return &restartInitd{
+		statuses: make(map[string]*client.ProcessStatus),
+		starts:   make(map[string]int),
+		exited:   make(chan bool, 10),
+	}
+}
+
+func (r *restartInitd) setStatus(name string, status *client.ProcessStatus) {
+	r.mutex.Lock()
+	r.statuses[name] = status
+	r.mutex.Unlock()
+	if !status.Running {
+		r.exited <- true
+	}
+}
+
+func (r *restartInitd) startCount(name string) int {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.starts[name]
+}
+
+func (r *restartInitd) Start(
+	name string, command []string, workingDirectory string, env []string,
+	stdout string, stderr, user, group, root string, timeout time.Duration,
+) error {
+	r.mutex.Lock()
+	r.starts[name]++
+	r.statuses[name] = &client.ProcessStatus{Running: true}
+	r.mutex.Unlock()
+	time.AfterFunc(10*time.Millisecond, func() {
+		r.setStatus(name, &client.ProcessStatus{Exited: true, ExitCode: 1})
+	})
+	return nil
+}
+
+func (r *restartInitd) Wait(timeout time.Duration) error {
+	r.mutex.Lock()
+	running := anyRunning(r.statuses)
+	r.mutex.Unlock()
+	if running {
+		<-r.exited
+	}
+	return nil
+}
+
+func (r *restartInitd) Status(timeout time.Duration) (map[string]*client.ProcessStatus, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	statuses := make(map[string]*client.ProcessStatus, len(r.statuses))
+	for name, status := range r.statuses {
+		statuses[name] = status
+	}
+	return statuses, nil
+}
+
+func (r *restartInitd) Stopped() bool {
+	return false
+}
+
+func TestContainer_RestartDoesNotBlockWaitLoop(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	m := newStateManager(t)
+	c := newStateContainer(t, m)
+	var image *schema.ImageManifest
+	TestExpectSuccess(t, json.Unmarshal([]byte(
+		`{"acKind":"ImageManifest","acVersion":"0.7.1","name":"example.com/test",
+		  "app":{"exec":["/bin/false"],"user":"0","group":"0","isolators":[
+		    {"name":"kurma/restart-policy","value":{"policy":"always","max_retries":2,"backoff":"200ms"}}]}}`),
+		&image))
+	restarted := &app{name: "restarted", image: image, environment: envmap.NewEnvMap()}
+	other := &app{name: "other", image: c.apps[0].image, environment: envmap.NewEnvMap()}
+	c.apps = []*app{restarted, other}
+
+	// The restarted app has exited and is waiting out its backoff, while the
+	// other app is still running.
+	initd := newRestartInitd()
+	initd.statuses["restarted"] = &client.ProcessStatus{Exited: true, ExitCode: 1}
+	initd.statuses["other"] = &client.ProcessStatus{Running: true}
+	c.initdClient = initd
+	go c.waitLoop()
+
+	// The other app exiting is seen during the restarted app's backoff.
+	time.Sleep(50 * time.Millisecond)
+	initd.setStatus("other", &client.ProcessStatus{Exited: true})
+	Timeout(t, 100*time.Millisecond, 5*time.Millisecond, func() bool {
+		c.mutex.Lock()
+		defer c.mutex.Unlock()
+		status := c.appStatuses["other"]
+		return status != nil && status.Exited
+	})
+	TestEqual(t, initd.startCount("restarted"), 0)
+
+	// The app is restarted until it reaches its maximum, and then the pod
+	// exits.
+	done := make(chan bool)
+	go func() {
+		c.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		Fatalf(t, "Timed out waiting for the pod to exit")
+	}
+	TestEqual(t, initd.startCount("restarted"), 2)
+	TestEqual(t, initd.startCount("other"), 0)
+	TestEqual(t, c.RestartCount(), 2)
+	TestEqual(t, c.State(), EXITED)
+}
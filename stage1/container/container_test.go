@@ -0,0 +1,34 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package container
+
+import (
+	"testing"
+
+	. "github.com/apcera/util/testtool"
+)
+
+func TestContainer_ExecErrors(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	m := newStateManager(t)
+	c := newStateContainer(t, m)
+	TestExpectSuccess(t, c.startingEnvironment())
+
+	tests := []*ExecOptions{
+		// The app must be within the pod.
+		{App: "missing", Command: []string{"/bin/true"}},
+		// A command must be given.
+		{App: "test"},
+		// The container isn't running, as it has no cgroup.
+		{App: "test", Command: []string{"/bin/true"}},
+		{Command: []string{"/bin/true"}},
+	}
+	for i, opts := range tests {
+		if p, err := c.Exec(opts); err == nil {
+			p.Kill()
+			Fatalf(t, "Test %d: expected an error for %#v", i, opts)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package container
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/apcera/util/tarhelper"
+	"github.com/appc/spec/schema"
+	"github.com/appc/spec/schema/types"
+)
+
+// maxDependencyDepth is the maximum depth of the image dependency tree, which
+// guards against dependency cycles.
+const maxDependencyDepth = 32
+
+// renderDependencies extracts the dependencies of the image into the target
+// directory in the order they're listed. Each dependency has its own
+// dependencies rendered beneath it first, and its path whitelist applied after
+// it is extracted.
+func (c *Container) renderDependencies(image *schema.ImageManifest, target string, depth int) error {
+	if len(image.Dependencies) == 0 {
+		return nil
+	}
+	if depth >= maxDependencyDepth {
+		return fmt.Errorf("image dependencies are nested more than %d deep", maxDependencyDepth)
+	}
+
+	for _, dep := range image.Dependencies {
+		hash, manifest, err := c.resolveDependency(dep)
+		if err != nil {
+			return fmt.Errorf("failed to resolve dependency %q: %v", dep.App, err)
+		}
+		c.log.Debugf("Rendering dependency %s (%s)", dep.App, hash)
+
+		if err := c.renderDependencies(manifest, target, depth+1); err != nil {
+			return err
+		}
+
+		f, err := c.manager.ImageManager.OpenImage(hash)
+		if err != nil {
+			return err
+		}
+		err = extractImage(f, target)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("failed to extract dependency %q: %v", dep.App, err)
+		}
+
+		if err := applyPathWhitelist(filepath.Join(target, "rootfs"), manifest.PathWhitelist); err != nil {
+			return fmt.Errorf("failed to apply the path whitelist of dependency %q: %v", dep.App, err)
+		}
+	}
+	return nil
+}
+
+// resolveDependency locates the image for the dependency within the image
+// store, retrieving it if it isn't already stored. It returns the image ID and
+// manifest of the image.
+func (c *Container) resolveDependency(dep types.Dependency) (string, *schema.ImageManifest, error) {
+	images := c.manager.ImageManager
+	if images == nil {
+		return "", nil, fmt.Errorf("no image store is available to resolve dependencies")
+	}
+
+	// check for the image within the store
+	if dep.ImageID != nil {
+		if manifest := images.GetImage(dep.ImageID.String()); manifest != nil {
+			return dep.ImageID.String(), manifest, nil
+		}
+	} else if hash, manifest := images.FindImage(dep.App.String(), dep.Labels); hash != "" {
+		return hash, manifest, nil
+	}
+
+	// retrieve the image and add it to the store
+	c.log.Debugf("Retrieving dependency %s", dependencyString(dep))
+	hash, manifest, err := images.RetrieveImage(dependencyString(dep))
+	if err != nil {
+		return "", nil, err
+	}
+	if dep.ImageID != nil && dep.ImageID.String() != hash {
+		return "", nil, fmt.Errorf("retrieved image %s does not match the image ID %s", hash, dep.ImageID)
+	}
+	if manifest.Name != dep.App {
+		return "", nil, fmt.Errorf("retrieved image is named %q", manifest.Name)
+	}
+	return hash, manifest, nil
+}
+
+// dependencyString returns the dependency in the form used for image discovery,
+// such as "example.com/app,version=1.0.0".
+func dependencyString(dep types.Dependency) string {
+	parts := []string{dep.App.String()}
+	for _, l := range dep.Labels {
+		parts = append(parts, fmt.Sprintf("%s=%s", l.Name, l.Value))
+	}
+	return strings.Join(parts, ",")
+}
+
+// extractImage extracts the ACI from the reader into the target directory.
+func extractImage(r io.Reader, target string) error {
+	tarfile := tarhelper.NewUntar(r, target)
+	tarfile.PreserveOwners = true
+	tarfile.PreservePermissions = true
+	tarfile.Compression = tarhelper.DETECT
+	tarfile.AbsoluteRoot = target
+	return tarfile.Extract()
+}
+
+// applyPathWhitelist removes any paths within the root filesystem which are not
+// listed within the whitelist. The directories leading to a whitelisted path are
+// preserved. An empty whitelist leaves the filesystem untouched.
+func applyPathWhitelist(rootfs string, whitelist []string) error {
+	if len(whitelist) == 0 {
+		return nil
+	}
+
+	keep := make(map[string]bool)
+	for _, p := range whitelist {
+		for p = filepath.Clean("/" + p); p != "/"; p = filepath.Dir(p) {
+			keep[p] = true
+		}
+	}
+
+	var remove []string
+	err := filepath.Walk(rootfs, func(p string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(rootfs, p)
+		if err != nil {
+			return err
+		}
+		if rel == "." || keep["/"+rel] {
+			return nil
+		}
+		remove = append(remove, p)
+		if fi.IsDir() {
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, p := range remove {
+		if err := os.RemoveAll(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,206 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package container
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha512"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/apcera/kurma/stage1/image"
+	"github.com/apcera/logray"
+	"github.com/appc/spec/schema"
+
+	. "github.com/apcera/util/testtool"
+)
+
+// buildImage returns an ACI with the manifest and the files within its root
+// filesystem.
+func buildImage(t *testing.T, manifest string, files map[string]string) []byte {
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gw)
+	write := func(name, contents string) {
+		TestExpectSuccess(t, tw.WriteHeader(&tar.Header{
+			Name: name, Mode: 0644, Size: int64(len(contents)), ModTime: time.Now(),
+		}))
+		_, err := tw.Write([]byte(contents))
+		TestExpectSuccess(t, err)
+	}
+	write("manifest", manifest)
+	TestExpectSuccess(t, tw.WriteHeader(&tar.Header{
+		Name: "rootfs/", Mode: 0755, Typeflag: tar.TypeDir, ModTime: time.Now(),
+	}))
+	for name, contents := range files {
+		write(filepath.Join("rootfs", name), contents)
+	}
+	TestExpectSuccess(t, tw.Close())
+	TestExpectSuccess(t, gw.Close())
+	return buf.Bytes()
+}
+
+// specImageID returns the image ID of the ACI as the appc spec defines it, the
+// sha512 of the uncompressed tar.
+func specImageID(t *testing.T, aci []byte) string {
+	gr, err := gzip.NewReader(bytes.NewReader(aci))
+	TestExpectSuccess(t, err)
+	h := sha512.New()
+	_, err = io.Copy(h, gr)
+	TestExpectSuccess(t, err)
+	return fmt.Sprintf("sha512-%x", h.Sum(nil))
+}
+
+// storeImage adds the image to the store, returning its ID and manifest. The
+// ID is checked against the one defined by the appc spec.
+func storeImage(t *testing.T, images *image.Manager, manifest string, files map[string]string) (string, *schema.ImageManifest) {
+	aci := buildImage(t, manifest, files)
+	hash, m, err := images.CreateImage(bytes.NewReader(aci), nil)
+	TestExpectSuccess(t, err)
+	TestEqual(t, hash, specImageID(t, aci))
+	return hash, m
+}
+
+// newDependencyContainer returns a container whose manager has an image store
+// which never retrieves remote images.
+func newDependencyContainer(t *testing.T) *Container {
+	images, err := image.NewManager(&image.Options{
+		Directory: TempDir(t),
+		Retriever: &image.Retriever{Policy: &image.Policy{DeniedNames: []string{"example.com"}}},
+	})
+	TestExpectSuccess(t, err)
+	return &Container{
+		manager: &Manager{ImageManager: images},
+		log:     logray.New(),
+	}
+}
+
+// listFiles returns the paths of the files within the directory.
+func listFiles(t *testing.T, dir string) []string {
+	var files []string
+	err := filepath.Walk(dir, func(p string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if p != dir {
+			rel, _ := filepath.Rel(dir, p)
+			files = append(files, rel)
+		}
+		return nil
+	})
+	TestExpectSuccess(t, err)
+	sort.Strings(files)
+	return files
+}
+
+func TestContainer_RenderDependencies(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	c := newDependencyContainer(t)
+	images := c.manager.ImageManager
+
+	// c is referenced by its image ID as computed by the appc spec, while b is
+	// found by its name and labels.
+	imageC := buildImage(t,
+		`{"acKind":"ImageManifest","acVersion":"0.7.1","name":"example.com/c"}`,
+		map[string]string{"c.txt": "c", "shared.txt": "from c"})
+	_, _, err := images.CreateImage(bytes.NewReader(imageC), nil)
+	TestExpectSuccess(t, err)
+	storeImage(t, images,
+		`{"acKind":"ImageManifest","acVersion":"0.7.1","name":"example.com/b",
+		  "labels":[{"name":"version","value":"1.0.0"}],
+		  "dependencies":[{"app":"example.com/c","imageID":"`+specImageID(t, imageC)+`"}],
+		  "pathWhitelist":["/b.txt","/c.txt","/shared.txt"]}`,
+		map[string]string{"b.txt": "b", "shared.txt": "from b", "extra.txt": "extra"})
+
+	var manifest *schema.ImageManifest
+	TestExpectSuccess(t, json.Unmarshal([]byte(
+		`{"acKind":"ImageManifest","acVersion":"0.7.1","name":"example.com/a",
+		  "dependencies":[{"app":"example.com/b","labels":[{"name":"version","value":"1.0.0"}]}]}`),
+		&manifest))
+
+	// Dependencies are rendered beneath the images depending on them, and
+	// their path whitelist is applied after they're extracted.
+	target := TempDir(t)
+	TestExpectSuccess(t, c.renderDependencies(manifest, target, 0))
+	rootfs := filepath.Join(target, "rootfs")
+	TestEqual(t, listFiles(t, rootfs), []string{"b.txt", "c.txt", "shared.txt"})
+	b, err := ioutil.ReadFile(filepath.Join(rootfs, "shared.txt"))
+	TestExpectSuccess(t, err)
+	TestEqual(t, string(b), "from b")
+}
+
+func TestContainer_RenderDependenciesFailures(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	c := newDependencyContainer(t)
+	storeImage(t, c.manager.ImageManager,
+		`{"acKind":"ImageManifest","acVersion":"0.7.1","name":"example.com/cycle",
+		  "dependencies":[{"app":"example.com/cycle"}]}`, nil)
+
+	tests := []string{
+		// An image which depends on itself exceeds the maximum depth.
+		`[{"app":"example.com/cycle"}]`,
+		// Images with other labels or image IDs don't satisfy the dependency,
+		// and retrieving them isn't permitted.
+		`[{"app":"example.com/cycle","labels":[{"name":"version","value":"2.0.0"}]}]`,
+		`[{"app":"example.com/cycle","imageID":"sha512-0123"}]`,
+		`[{"app":"example.com/missing"}]`,
+	}
+	for _, deps := range tests {
+		var manifest *schema.ImageManifest
+		TestExpectSuccess(t, json.Unmarshal([]byte(
+			`{"acKind":"ImageManifest","acVersion":"0.7.1","name":"example.com/a","dependencies":`+deps+`}`),
+			&manifest))
+		if err := c.renderDependencies(manifest, TempDir(t), 0); err == nil {
+			Fatalf(t, "Expected an error rendering the dependencies %s", deps)
+		}
+	}
+}
+
+func TestApplyPathWhitelist(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	files := []string{"bin/sh", "bin/ls", "etc/passwd", "etc/group", "lib/libc.so", "tmp/file"}
+	setup := func() string {
+		rootfs := TempDir(t)
+		for _, f := range files {
+			p := filepath.Join(rootfs, f)
+			TestExpectSuccess(t, os.MkdirAll(filepath.Dir(p), os.FileMode(0755)))
+			TestExpectSuccess(t, ioutil.WriteFile(p, nil, os.FileMode(0644)))
+		}
+		return rootfs
+	}
+
+	tests := []struct {
+		whitelist []string
+		want      []string
+	}{
+		// An empty whitelist leaves everything in place.
+		{nil, []string{"bin", "bin/ls", "bin/sh", "etc", "etc/group", "etc/passwd", "lib", "lib/libc.so", "tmp", "tmp/file"}},
+		// The directories leading to whitelisted paths are kept, with the
+		// paths given either absolute or relative, and cleaned.
+		{[]string{"/bin/sh", "etc/passwd", "/lib/../lib/libc.so"}, []string{"bin", "bin/sh", "etc", "etc/passwd", "lib", "lib/libc.so"}},
+		// A whitelisted directory is kept, but not its contents.
+		{[]string{"/tmp/"}, []string{"tmp"}},
+		// Paths which don't exist are ignored.
+		{[]string{"/usr/bin/env", "/bin/ls"}, []string{"bin", "bin/ls"}},
+	}
+	for _, test := range tests {
+		rootfs := setup()
+		TestExpectSuccess(t, applyPathWhitelist(rootfs, test.whitelist))
+		TestEqual(t, listFiles(t, rootfs), test.want)
+	}
+}
@@ -0,0 +1,79 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package container
+
+import (
+	"time"
+)
+
+// eventBufferSize is the number of events which can be queued for a subscriber
+// before further events are dropped.
+const eventBufferSize = 100
+
+// Event describes a container transitioning from one state to another. Events
+// with OOM set instead report the OOM killer being invoked within the
+// container, and carry its current state.
+type Event struct {
+	UUID   string
+	State  ContainerState
+	Time   time.Time
+	Reason string
+	OOM    bool
+}
+
+// Subscribe returns a channel which will receive an Event for every container
+// state transition on the host. The channel should be released with
+// Unsubscribe once the caller is no longer reading from it.
+func (manager *Manager) Subscribe() chan *Event {
+	ch := make(chan *Event, eventBufferSize)
+	manager.subscribersLock.Lock()
+	manager.subscribers[ch] = true
+	manager.subscribersLock.Unlock()
+	return ch
+}
+
+// Unsubscribe removes a channel previously returned by Subscribe and closes it.
+func (manager *Manager) Unsubscribe(ch chan *Event) {
+	manager.subscribersLock.Lock()
+	defer manager.subscribersLock.Unlock()
+	if manager.subscribers[ch] {
+		delete(manager.subscribers, ch)
+		close(ch)
+	}
+}
+
+// publish sends the event to all current subscribers. Subscribers which aren't
+// keeping up with the events will have the event dropped rather than blocking
+// the container's state transition.
+func (manager *Manager) publish(event *Event) {
+	manager.subscribersLock.Lock()
+	defer manager.subscribersLock.Unlock()
+	for ch := range manager.subscribers {
+		select {
+		case ch <- event:
+		default:
+			manager.Log.Warnf("Dropping event for container %s, subscriber is full", event.UUID)
+		}
+	}
+}
+
+// setState transitions the container to the provided state and notifies any
+// subscribers of the transition.
+func (c *Container) setState(state ContainerState, reason string) {
+	c.mutex.Lock()
+	c.state = state
+	c.mutex.Unlock()
+	c.publishState(state, reason)
+}
+
+// publishState notifies any subscribers that the container has transitioned to
+// the provided state.
+func (c *Container) publishState(state ContainerState, reason string) {
+	c.log.Debugf("Container transitioned to %s: %s", state, reason)
+	c.manager.publish(&Event{
+		UUID:   c.uuid,
+		State:  state,
+		Time:   time.Now(),
+		Reason: reason,
+	})
+}
@@ -0,0 +1,239 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package container
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+	"time"
+
+	kschema "github.com/apcera/kurma/schema"
+	"github.com/apcera/kurma/stage3/client"
+)
+
+const (
+	// PreStartHook hooks are run before the apps within the container are
+	// started.
+	PreStartHook = "pre-start"
+
+	// PostStartHook hooks are run once the apps within the container have been
+	// started.
+	PostStartHook = "post-start"
+
+	// PostStopHook hooks are run once the apps within the container have
+	// exited.
+	PostStopHook = "post-stop"
+
+	// defaultHookTimeout is how long a hook may run if no timeout is given for
+	// it.
+	defaultHookTimeout = 30 * time.Second
+
+	// hookProcessPrefix is the prefix of the names of the initd processes used
+	// to run app hooks, which distinguishes them from the apps themselves.
+	hookProcessPrefix = "hook:"
+)
+
+// Hook is a command which is run on the host at a point in the lifecycle of
+// every container.
+type Hook struct {
+	// Exec is the command to run and its arguments.
+	Exec []string
+
+	// Timeout is how long the hook may run before it is killed and considered
+	// to have failed. The default timeout is used if it is 0.
+	Timeout time.Duration
+
+	// AbortOnFailure causes the failure of a pre-start or post-start hook to
+	// fail the container's startup. The failure of any other hook is only
+	// logged.
+	AbortOnFailure bool
+}
+
+// Hooks are the host hooks to run for each container, grouped by the point in
+// the container's lifecycle they're run at.
+type Hooks struct {
+	PreStart  []*Hook
+	PostStart []*Hook
+	PostStop  []*Hook
+}
+
+// startingPreStartHooks runs the host's pre-start hooks once the container's
+// filesystem and cgroup are set up, before stage2 is launched.
+func (c *Container) startingPreStartHooks() error {
+	return c.runHostHooks(PreStartHook, c.manager.hooks.PreStart)
+}
+
+// startingPostStartHooks runs the host's post-start hooks once the apps within
+// the container have been started.
+func (c *Container) startingPostStartHooks() error {
+	return c.runHostHooks(PostStartHook, c.manager.hooks.PostStart)
+}
+
+// stoppingAppHooks runs the post-stop event handlers of each of the apps
+// within the container once they have exited. The initd is still running at
+// this point, so the handlers are run inside the container.
+func (c *Container) stoppingAppHooks() error {
+	initdClient := c.getInitdClient()
+	if c.cgroup == nil || initdClient == nil || initdClient.Stopped() {
+		return nil
+	}
+
+	for _, a := range c.apps {
+		if err := c.runAppHooks(initdClient, a, PostStopHook); err != nil {
+			c.log.Warnf("%v", err)
+		}
+	}
+	return nil
+}
+
+// stoppingPostStopHooks runs the host's post-stop hooks once the processes
+// within the container have been terminated.
+func (c *Container) stoppingPostStopHooks() error {
+	return c.runHostHooks(PostStopHook, c.manager.hooks.PostStop)
+}
+
+// hookEnvironment returns the environment variables that describe the
+// container and event to a hook.
+func (c *Container) hookEnvironment(event string) map[string]string {
+	return map[string]string{
+		"KURMA_HOOK":          event,
+		"KURMA_POD_UUID":      c.uuid,
+		"KURMA_POD_DIRECTORY": c.directory,
+	}
+}
+
+// runHostHooks runs the given host hooks in order. An error is returned for the
+// first hook which fails and is set to abort the container's startup, and the
+// remaining hooks are not run.
+func (c *Container) runHostHooks(event string, hooks []*Hook) error {
+	for _, h := range hooks {
+		err := c.runHostHook(event, h)
+		if err == nil {
+			continue
+		}
+		if h.AbortOnFailure && event != PostStopHook {
+			return fmt.Errorf("%s hook %q failed: %v", event, strings.Join(h.Exec, " "), err)
+		}
+		c.log.Warnf("%s hook %q failed: %v", event, strings.Join(h.Exec, " "), err)
+	}
+	return nil
+}
+
+// runHostHook runs the hook on the host and waits for it to exit, killing it if
+// it exceeds its timeout.
+func (c *Container) runHostHook(event string, h *Hook) error {
+	if len(h.Exec) == 0 {
+		return fmt.Errorf("no command was given")
+	}
+	timeout := h.Timeout
+	if timeout <= 0 {
+		timeout = defaultHookTimeout
+	}
+
+	var output bytes.Buffer
+	cmd := exec.Command(h.Exec[0], h.Exec[1:]...)
+	cmd.Env = os.Environ()
+	for k, v := range c.hookEnvironment(event) {
+		cmd.Env = append(cmd.Env, k+"="+v)
+	}
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+
+	// run the hook in its own process group, so that anything it started is
+	// killed along with it on timeout
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+
+	c.log.Debugf("Running %s hook %q", event, strings.Join(h.Exec, " "))
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			c.log.Debugf("Output of failed %s hook: %s", event, output.String())
+		}
+		return err
+	case <-time.After(timeout):
+		syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+		<-done
+		return fmt.Errorf("timed out after %v", timeout)
+	}
+}
+
+// runAppHooks runs the app's event handlers for the event within the container,
+// in the order they're listed in the image. Each handler is run as the app's
+// user within the app's root filesystem, and must exit successfully within the
+// hook timeout.
+func (c *Container) runAppHooks(initdClient client.Client, a *app, event string) error {
+	timeout := defaultHookTimeout
+	if v, ok := a.image.Annotations.Get(kschema.HookTimeoutAnnotation); ok {
+		if d, err := time.ParseDuration(v); err == nil {
+			timeout = d
+		}
+	}
+
+	env := a.environment.NewChild()
+	for k, v := range c.hookEnvironment(event) {
+		env.Set(k, v)
+	}
+
+	for i, h := range a.image.App.EventHandlers {
+		if h.Name != event {
+			continue
+		}
+
+		name := fmt.Sprintf("%s%s:%s:%d", hookProcessPrefix, a.name, event, i)
+		c.log.Debugf("Running %s event handler for app %q", event, a.name)
+		err := initdClient.Start(
+			name, a.command(h.Exec), a.workingDirectory(), env.Strings(),
+			c.appLogPath(a, "stdout"), c.appLogPath(a, "stderr"),
+			a.image.App.User, a.image.App.Group, c.appInitdRoot(a),
+			time.Second*5)
+		if err != nil {
+			return fmt.Errorf("failed to start %s event handler for app %q: %v", event, a.name, err)
+		}
+		if err := waitForProcess(initdClient, name, timeout); err != nil {
+			return fmt.Errorf("%s event handler for app %q failed: %v", event, a.name, err)
+		}
+	}
+	return nil
+}
+
+// waitForProcess polls the initd until the named process has exited, returning
+// an error if it failed or is still running once the timeout has elapsed. A
+// process which times out is killed so that it doesn't keep running within the
+// container.
+func waitForProcess(initdClient client.Client, name string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		statuses, err := initdClient.Status(time.Second)
+		if err != nil {
+			return err
+		}
+		if status, ok := statuses[name]; ok && !status.Running {
+			if status.Failed() {
+				return fmt.Errorf("process %s", status)
+			}
+			return nil
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	if err := initdClient.Signal(name, syscall.SIGKILL, time.Second); err != nil {
+		return fmt.Errorf("timed out after %v, and failed to kill it: %v", timeout, err)
+	}
+	return fmt.Errorf("timed out after %v", timeout)
+}
+
+// isHookProcess returns whether the named initd process is an app hook rather
+// than an app.
+func isHookProcess(name string) bool {
+	return strings.HasPrefix(name, hookProcessPrefix)
+}
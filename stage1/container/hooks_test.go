@@ -0,0 +1,166 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package container
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/apcera/kurma/stage3/client"
+	"github.com/apcera/logray"
+
+	. "github.com/apcera/util/testtool"
+)
+
+// newHookContainer returns a container which is able to run host hooks.
+func newHookContainer(t *testing.T) *Container {
+	return &Container{
+		manager:   &Manager{},
+		log:       logray.New(),
+		uuid:      "01234567-89ab-cdef-0123-456789abcdef",
+		directory: TempDir(t),
+	}
+}
+
+// shellHook returns a hook running the script with the shell.
+func shellHook(script string, abort bool) *Hook {
+	return &Hook{Exec: []string{"/bin/sh", "-c", script}, AbortOnFailure: abort}
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func TestContainer_RunHostHooksEnvironment(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	c := newHookContainer(t)
+	output := filepath.Join(TempDir(t), "env")
+	hook := shellHook(`echo "$KURMA_HOOK $KURMA_POD_UUID $KURMA_POD_DIRECTORY" > `+output, true)
+	TestExpectSuccess(t, c.runHostHooks(PreStartHook, []*Hook{hook}))
+
+	b, err := ioutil.ReadFile(output)
+	TestExpectSuccess(t, err)
+	TestEqual(t, strings.TrimSpace(string(b)), PreStartHook+" "+c.uuid+" "+c.directory)
+}
+
+func TestContainer_RunHostHooksFailures(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	c := newHookContainer(t)
+	dir := TempDir(t)
+
+	tests := []struct {
+		event   string
+		abort   bool
+		wantErr bool
+	}{
+		// Failures only stop the startup when the hook is set to abort it.
+		{PreStartHook, true, true},
+		{PostStartHook, true, true},
+		{PreStartHook, false, false},
+		// Post-stop hooks can't abort anything, so failures are only logged.
+		{PostStopHook, true, false},
+	}
+	for i, test := range tests {
+		marker := filepath.Join(dir, test.event+"-"+strings.Repeat("x", i))
+		hooks := []*Hook{shellHook("exit 1", test.abort), shellHook("touch "+marker, false)}
+		err := c.runHostHooks(test.event, hooks)
+		if test.wantErr {
+			if err == nil {
+				Fatalf(t, "Test %d: expected the %s hook failure to be returned", i, test.event)
+			}
+			// The hooks after the failure aren't run.
+			TestEqual(t, exists(marker), false)
+		} else {
+			TestExpectSuccess(t, err)
+			TestEqual(t, exists(marker), true)
+		}
+	}
+
+	// A hook without a command fails.
+	if err := c.runHostHooks(PreStartHook, []*Hook{{AbortOnFailure: true}}); err == nil {
+		Fatalf(t, "Expected an error for a hook without a command")
+	}
+}
+
+func TestContainer_RunHostHookTimeout(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	c := newHookContainer(t)
+	marker := filepath.Join(TempDir(t), "finished")
+	hook := shellHook("sleep 1; touch "+marker, true)
+	hook.Timeout = 100 * time.Millisecond
+
+	start := time.Now()
+	if err := c.runHostHooks(PreStartHook, []*Hook{hook}); err == nil {
+		Fatalf(t, "Expected an error for a hook exceeding its timeout")
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		Fatalf(t, "Expected the hook to be killed at its timeout, took %v", elapsed)
+	}
+
+	// The hook was killed rather than left to finish.
+	time.Sleep(1500 * time.Millisecond)
+	TestEqual(t, exists(marker), false)
+}
+
+// hookInitd is an initd client whose processes never exit, recording the
+// signals sent to them.
+type hookInitd struct {
+	client.Client
+	running map[string]bool
+}
+
+func (h *hookInitd) Status(timeout time.Duration) (map[string]*client.ProcessStatus, error) {
+	statuses := make(map[string]*client.ProcessStatus)
+	for name, running := range h.running {
+		statuses[name] = &client.ProcessStatus{Running: running}
+	}
+	return statuses, nil
+}
+
+func (h *hookInitd) Signal(name string, signal syscall.Signal, timeout time.Duration) error {
+	if signal == syscall.SIGKILL {
+		h.running[name] = false
+	}
+	return nil
+}
+
+func TestWaitForProcessKillsOnTimeout(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	name := hookProcessPrefix + "app:pre-start:0"
+	initd := &hookInitd{running: map[string]bool{name: true}}
+	if err := waitForProcess(initd, name, 200*time.Millisecond); err == nil {
+		Fatalf(t, "Expected an error for a handler exceeding its timeout")
+	}
+	TestEqual(t, initd.running[name], false)
+}
+
+func TestIsHookProcess(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	TestEqual(t, isHookProcess(hookProcessPrefix+"app:pre-start:0"), true)
+	TestEqual(t, isHookProcess("app"), false)
+
+	// Only the apps are considered when checking whether a pod is running.
+	statuses := map[string]*client.ProcessStatus{
+		"app":                                 &client.ProcessStatus{Exited: true},
+		hookProcessPrefix + "app:post-stop:0": &client.ProcessStatus{Running: true},
+	}
+	TestEqual(t, anyRunning(statuses), false)
+	statuses["app"] = &client.ProcessStatus{Running: true}
+	TestEqual(t, anyRunning(statuses), true)
+}
@@ -5,9 +5,12 @@ package container
 import (
 	"fmt"
 	"io"
+	"reflect"
 	"sync"
+	"time"
 
 	kschema "github.com/apcera/kurma/schema"
+	"github.com/apcera/kurma/stage1/image"
 	"github.com/apcera/kurma/util/cgroups"
 	"github.com/apcera/logray"
 	"github.com/apcera/util/uuid"
@@ -20,7 +23,29 @@ import (
 type Options struct {
 	ParentCgroupName   string
 	ContainerDirectory string
+	VolumeDirectory    string
 	RequiredNamespaces []string
+
+	// UserNamespaceOffset is the first host uid and gid which is mapped into
+	// container user namespaces, and UserNamespaceRangeSize is the number of
+	// uids and gids given to each container. User namespaces are disabled when
+	// the range size is 0.
+	UserNamespaceOffset    int
+	UserNamespaceRangeSize int
+
+	// StorageDriver is the name of the driver used to create the root
+	// filesystems of apps, and StorageDirectory is where the driver keeps its
+	// data. The directory driver is used if none is given.
+	StorageDriver    string
+	StorageDirectory string
+
+	// MetadataPort is the port the appc metadata service listens on within the
+	// loopback interface. The service is disabled when it is 0.
+	MetadataPort int
+
+	// Hooks are the commands run on the host at points in the lifecycle of
+	// each container.
+	Hooks Hooks
 }
 
 // Manager handles the management of the containers running and available on the
@@ -28,23 +53,59 @@ type Options struct {
 type Manager struct {
 	Log *logray.Logger
 
+	// ImageManager is the image store used to resolve the dependencies of the
+	// images containers are launched from.
+	ImageManager *image.Manager
+
 	containers     map[string]*Container
 	containersLock sync.RWMutex
 
+	subscribers     map[chan *Event]bool
+	subscribersLock sync.Mutex
+
 	cgroup             *cgroups.Cgroup
 	directory          string
+	volumeDirectory    string
 	requiredNamespaces []string
+	storage            StorageDriver
+	storageDirectory   string
+	storageDrivers     map[string]StorageDriver
+	storageLock        sync.RWMutex
+
+	userNamespaceOffset    int
+	userNamespaceRangeSize int
+	userNamespaces         map[int]bool
+	userNamespacesLock     sync.Mutex
+
+	metadataPort int
+	metadataKey  []byte
+
+	hooks Hooks
 }
 
 // NewManager creates a new Manager with the provided options. It will ensure
 // the manager is setup and ready to create containers with the provided
-// configuration.
+// configuration. Containers left by a previous instance of the Manager are not
+// tracked until Recover is called.
 func NewManager(opts *Options) (*Manager, error) {
 	// validate cgroups is properly setup on the host
 	if err := cgroups.CheckCgroups(); err != nil {
 		return nil, fmt.Errorf("failed to check cgroups: %v", err)
 	}
 
+	// validate the user namespace range, root on the host must never be mapped
+	if opts.UserNamespaceRangeSize < 0 {
+		return nil, fmt.Errorf("the user namespace range size must not be negative")
+	}
+	if opts.UserNamespaceRangeSize > 0 && opts.UserNamespaceOffset <= 0 {
+		return nil, fmt.Errorf("the user namespace offset must be positive")
+	}
+
+	storage, err := newStorageDriver(opts.StorageDriver, opts.StorageDirectory)
+	if err != nil {
+		return nil, fmt.Errorf("failed to set up the storage driver: %v", err)
+	}
+
 	// create the parent cgroup for all child containers to be in
 	cg, err := cgroups.New(opts.ParentCgroupName)
 	if err != nil {
@@ -54,13 +115,77 @@ func NewManager(opts *Options) (*Manager, error) {
 	m := &Manager{
 		Log:                logray.New(),
 		containers:         make(map[string]*Container),
+		subscribers:        make(map[chan *Event]bool),
 		directory:          opts.ContainerDirectory,
+		volumeDirectory:    opts.VolumeDirectory,
 		cgroup:             cg,
 		requiredNamespaces: opts.RequiredNamespaces,
+		storage:            storage,
+		storageDirectory:   opts.StorageDirectory,
+		storageDrivers:     map[string]StorageDriver{storage.Name(): storage},
+
+		userNamespaceOffset:    opts.UserNamespaceOffset,
+		userNamespaceRangeSize: opts.UserNamespaceRangeSize,
+		userNamespaces:         make(map[int]bool),
+
+		metadataPort: opts.MetadataPort,
+
+		hooks: opts.Hooks,
+	}
+
+	// start the metadata service, if enabled
+	if m.metadataPort > 0 {
+		if err := m.startMetadataService(); err != nil {
+			return nil, err
+		}
 	}
 	return m, nil
 }
 
+// SetStorageDriver replaces the storage driver used to create the root
+// filesystems of new containers. Existing containers continue to use the driver
+// they were created with.
+func (manager *Manager) SetStorageDriver(name, directory string) error {
+	storage, err := newStorageDriver(name, directory)
+	if err != nil {
+		return err
+	}
+	manager.storageLock.Lock()
+	manager.storage = storage
+	manager.storageDirectory = directory
+	manager.storageDrivers[storage.Name()] = storage
+	manager.storageLock.Unlock()
+	return nil
+}
+
+// storageDriver returns the storage driver to use for new containers.
+func (manager *Manager) storageDriver() StorageDriver {
+	manager.storageLock.RLock()
+	defer manager.storageLock.RUnlock()
+	return manager.storage
+}
+
+// recoverStorageDriver returns the storage driver with the given name, which a
+// recovered container was created with. Drivers other than the ones already in
+// use are set up on the current storage directory.
+func (manager *Manager) recoverStorageDriver(name string) (StorageDriver, error) {
+	if name == "" {
+		name = DirectoryStorageDriver
+	}
+
+	manager.storageLock.Lock()
+	defer manager.storageLock.Unlock()
+	if storage, exists := manager.storageDrivers[name]; exists {
+		return storage, nil
+	}
+	storage, err := newStorageDriver(name, manager.storageDirectory)
+	if err != nil {
+		return nil, err
+	}
+	manager.storageDrivers[name] = storage
+	return storage, nil
+}
+
 // Validate will ensure that the image manifest provided is valid to be run on
 // the system. It will return nil if it is valid, or will return an error if
 // something is invalid.
@@ -83,6 +208,10 @@ func (manager *Manager) Validate(imageManifest *schema.ImageManifest) error {
 				"user":  niso.User,
 				"uts":   niso.UTS,
 			}
+			if niso.User() && manager.userNamespaceRangeSize == 0 {
+				return fmt.Errorf("the manifest %s isolator requires a user namespace, which is not configured on this host",
+					kschema.LinuxNamespacesName)
+			}
 			for _, ns := range manager.requiredNamespaces {
 				f, exists := checks[ns]
 				if !exists {
@@ -96,6 +225,33 @@ func (manager *Manager) Validate(imageManifest *schema.ImageManifest) error {
 		}
 	}
 
+	// Validate the resource limits, if given
+	if limit, ok := cpuLimit(imageManifest); ok && limit <= 0 {
+		return fmt.Errorf("the manifest %s isolator must specify a positive limit", types.ResourceCPUName)
+	}
+	if limit, ok := memoryLimit(imageManifest); ok && limit <= 0 {
+		return fmt.Errorf("the manifest %s isolator must specify a positive limit", types.ResourceMemoryName)
+	}
+
+	// Validate the capabilities isolators, if given
+	if _, err := droppedCapabilities(imageManifest); err != nil {
+		return fmt.Errorf("the manifest capabilities isolators are invalid: %v", err)
+	}
+
+	// Validate the stop annotations, if given
+	if v, ok := imageManifest.Annotations.Get(kschema.StopSignalAnnotation); ok {
+		if _, err := parseSignal(v); err != nil {
+			return fmt.Errorf("the manifest %s annotation is invalid: %v", kschema.StopSignalAnnotation, err)
+		}
+	}
+	if v, ok := imageManifest.Annotations.Get(kschema.StopGracePeriodAnnotation); ok {
+		if d, err := time.ParseDuration(v); err != nil {
+			return fmt.Errorf("the manifest %s annotation is invalid: %v", kschema.StopGracePeriodAnnotation, err)
+		} else if d < 0 {
+			return fmt.Errorf("the manifest %s annotation must not be negative", kschema.StopGracePeriodAnnotation)
+		}
+	}
+
 	return nil
 }
 
@@ -104,46 +260,127 @@ func (manager *Manager) Validate(imageManifest *schema.ImageManifest) error {
 func (manager *Manager) Create(
 	name string, imageManifest *schema.ImageManifest, image io.ReadCloser,
 ) (*Container, error) {
-	// revalidate the image
-	if err := manager.Validate(imageManifest); err != nil {
-		return nil, err
+	return manager.CreatePod([]*PodApp{
+		&PodApp{Name: name, ImageManifest: imageManifest, Image: image},
+	}, nil)
+}
+
+// CreatePod begins launching a container running each of the provided apps
+// within a single pod. The apps share the pod's namespaces and cgroup, and
+// each have their own root filesystem. The volumes are made available to the
+// apps at their mount points.
+func (manager *Manager) CreatePod(apps []*PodApp, volumes []types.Volume) (*Container, error) {
+	if len(apps) == 0 {
+		return nil, fmt.Errorf("the pod must contain at least one app")
 	}
 
-	// handle a blank name
-	if name == "" {
-		name = imageManifest.Name.String()
+	// ensure the volume names are unique
+	volumeNames := make(map[types.ACName]bool)
+	for _, v := range volumes {
+		if volumeNames[v.Name] {
+			return nil, fmt.Errorf("the pod contains multiple volumes named %q", v.Name)
+		}
+		volumeNames[v.Name] = true
 	}
 
 	// populate the container
 	container := &Container{
-		manager:          manager,
-		log:              manager.Log.Clone(),
-		uuid:             uuid.Variant4().String(),
-		waitch:           make(chan bool),
-		initialImageFile: image,
-		image:            imageManifest,
+		manager: manager,
+		log:     manager.Log.Clone(),
+		uuid:    uuid.Variant4().String(),
+		waitch:  make(chan bool),
+		storage: manager.storageDriver(),
 		pod: &schema.PodManifest{
 			ACKind:    schema.PodManifestKind,
 			ACVersion: schema.AppContainerVersion,
-			Apps: schema.AppList([]schema.RuntimeApp{
-				schema.RuntimeApp{
-					Name: types.ACName(name),
-					App:  imageManifest.App,
-					Image: schema.RuntimeImage{
-						Name:   &imageManifest.Name,
-						Labels: imageManifest.Labels,
-					},
-				},
-			}),
+			Volumes:   volumes,
 		},
 	}
+
+	names := make(map[string]bool)
+	for _, a := range apps {
+		// revalidate the image
+		if err := manager.Validate(a.ImageManifest); err != nil {
+			return nil, err
+		}
+
+		// handle a blank name, and ensure each name is unique within the pod
+		name := a.Name
+		if name == "" {
+			name = a.ImageManifest.Name.String()
+		}
+		acname, err := types.NewACName(name)
+		if err != nil {
+			return nil, fmt.Errorf("invalid app name %q: %v", name, err)
+		}
+		if names[name] {
+			return nil, fmt.Errorf("the pod contains multiple apps named %q", name)
+		}
+		names[name] = true
+
+		// each of the app's mount points must have a volume to be mounted
+		for _, mp := range a.ImageManifest.App.MountPoints {
+			if !volumeNames[mp.Name] {
+				return nil, fmt.Errorf("no volume was provided for mount point %q of app %q", mp.Name, name)
+			}
+		}
+
+		// The capabilities are dropped across the whole pod, so the apps must
+		// agree on them.
+		if len(container.apps) > 0 {
+			primary, _ := droppedCapabilities(container.apps[0].image)
+			caps, _ := droppedCapabilities(a.ImageManifest)
+			if !reflect.DeepEqual(primary, caps) {
+				return nil, fmt.Errorf("the apps within a pod must specify the same capabilities")
+			}
+		}
+
+		// Host privileged apps rely on having the pod's root filesystem, which
+		// isn't the case when there are multiple apps.
+		if len(apps) > 1 && isHostPrivileged(a.ImageManifest) {
+			return nil, fmt.Errorf("host privileged apps cannot be run in a pod with other apps")
+		}
+
+		container.apps = append(container.apps, &app{
+			name:             name,
+			image:            a.ImageManifest,
+			initialImageFile: a.Image,
+		})
+		container.pod.Apps = append(container.pod.Apps, schema.RuntimeApp{
+			Name: *acname,
+			App:  a.ImageManifest.App,
+			Image: schema.RuntimeImage{
+				Name:   &a.ImageManifest.Name,
+				Labels: a.ImageManifest.Labels,
+			},
+		})
+	}
 	container.log.SetField("container", container.uuid)
+
+	// generate the token the pod's apps use to access the metadata service
+	if manager.metadataPort > 0 {
+		token, err := newMetadataToken()
+		if err != nil {
+			return nil, err
+		}
+		container.metadataToken = token
+	}
+
+	// reserve a range of uids and gids if the pod will have a user namespace
+	if manager.wantsUserNamespace(container.apps[0].image) {
+		offset, err := manager.allocateUserNamespace()
+		if err != nil {
+			return nil, err
+		}
+		container.userNamespaceOffset = offset
+	}
 	container.log.Debugf("Launching container %s", container.uuid)
 
 	// add it to the manager's map
 	manager.containersLock.Lock()
 	manager.containers[container.uuid] = container
 	manager.containersLock.Unlock()
+	container.publishState(NEW, "container created")
 
 	// begin the startup sequence
 	container.start()
@@ -158,6 +395,10 @@ func (manager *Manager) remove(container *Container) {
 	delete(manager.containers, container.uuid)
 	container.mutex.Unlock()
 	manager.containersLock.Unlock()
+
+	if container.usesUserNamespace() {
+		manager.releaseUserNamespace(container.userNamespaceOffset)
+	}
 }
 
 // Containers returns a slice of the current containers on the host.
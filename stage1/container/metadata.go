@@ -0,0 +1,363 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package container
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"syscall"
+
+	kschema "github.com/apcera/kurma/schema"
+	"github.com/appc/spec/schema/types"
+	"github.com/vishvananda/netlink"
+)
+
+const (
+	// metadataPrefix is the path prefix of all of the metadata service
+	// endpoints defined by the App Container Specification.
+	metadataPrefix = "acMetadata/v1/"
+
+	// metadataKeyFilename is the name of the file within the container
+	// directory that holds the key pod identities are signed with.
+	metadataKeyFilename = "metadata.key"
+)
+
+// startMetadataService begins serving the appc metadata service on the loopback
+// interface. Pods sharing the host's network namespace use it directly, while
+// pods with their own network namespace are given a listener within it.
+func (manager *Manager) startMetadataService() error {
+	manager.metadataKey = make([]byte, sha512.Size)
+	if _, err := rand.Read(manager.metadataKey); err != nil {
+		return err
+	}
+
+	l, err := net.Listen("tcp", manager.metadataAddress())
+	if err != nil {
+		return fmt.Errorf("failed to listen for the metadata service: %v", err)
+	}
+	go http.Serve(l, http.HandlerFunc(manager.serveMetadata))
+	return nil
+}
+
+// loadMetadataKey replaces the metadata service's key with the one saved in the
+// container directory, so the identities of recovered pods remain valid. If no
+// key was saved, the current key is saved for later runs.
+func (manager *Manager) loadMetadataKey() error {
+	fn := filepath.Join(manager.directory, metadataKeyFilename)
+	b, err := ioutil.ReadFile(fn)
+	if err == nil && len(b) == sha512.Size {
+		manager.metadataKey = b
+		return nil
+	} else if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	if err := os.MkdirAll(manager.directory, os.FileMode(0755)); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(fn, manager.metadataKey, os.FileMode(0600))
+}
+
+// metadataAddress returns the address the metadata service listens on, both on
+// the host and within pods with their own network namespace.
+func (manager *Manager) metadataAddress() string {
+	return fmt.Sprintf("127.0.0.1:%d", manager.metadataPort)
+}
+
+// newMetadataToken generates the token which identifies a pod to the metadata
+// service.
+func newMetadataToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// usesMetadata returns whether the container's apps have access to the metadata
+// service.
+func (c *Container) usesMetadata() bool {
+	return c.metadataToken != "" && c.manager.metadataPort > 0
+}
+
+// metadataURL returns the base URL of the metadata service for the container,
+// which is given to the apps as AC_METADATA_URL.
+func (c *Container) metadataURL() string {
+	return fmt.Sprintf("http://%s/%s", c.manager.metadataAddress(), c.metadataToken)
+}
+
+// usesNetworkNamespace returns whether the container has its own network
+// namespace.
+func (c *Container) usesNetworkNamespace() bool {
+	image := c.primaryApp().image
+	if iso := image.App.Isolators.GetByName(kschema.LinuxNamespacesName); iso != nil {
+		if niso, ok := iso.Value().(*kschema.LinuxNamespaces); ok {
+			return niso.Net()
+		}
+	}
+	return false
+}
+
+// serveMetadata makes the metadata service reachable from within the
+// container's network namespace, if it has its own. The loopback interface is
+// brought up within the namespace and the service listens on it.
+func (c *Container) serveMetadata() error {
+	if !c.usesMetadata() || !c.usesNetworkNamespace() {
+		return nil
+	}
+
+	tasks, err := c.cgroup.Tasks()
+	if err != nil {
+		return err
+	}
+	if len(tasks) == 0 {
+		return fmt.Errorf("no processes are running inside the container")
+	}
+
+	l, err := listenInNamespace(tasks[0], c.manager.metadataAddress())
+	if err != nil {
+		return fmt.Errorf("failed to listen for the metadata service: %v", err)
+	}
+	c.mutex.Lock()
+	c.metadataListener = l
+	c.mutex.Unlock()
+	go http.Serve(l, http.HandlerFunc(c.manager.serveMetadata))
+	return nil
+}
+
+// listenInNamespace creates a listener on the address within the network
+// namespace of the given process. The listening socket remains in that
+// namespace after the thread switches back to the host's namespace.
+func listenInNamespace(pid int, address string) (net.Listener, error) {
+	runtime.LockOSThread()
+
+	host, err := os.Open(fmt.Sprintf("/proc/self/task/%d/ns/net", syscall.Gettid()))
+	if err != nil {
+		runtime.UnlockOSThread()
+		return nil, err
+	}
+	defer host.Close()
+	target, err := os.Open(fmt.Sprintf("/proc/%d/ns/net", pid))
+	if err != nil {
+		runtime.UnlockOSThread()
+		return nil, err
+	}
+	defer target.Close()
+
+	if err := setns(target); err != nil {
+		runtime.UnlockOSThread()
+		return nil, err
+	}
+
+	l, err := func() (net.Listener, error) {
+		lo, err := netlink.LinkByName("lo")
+		if err != nil {
+			return nil, err
+		}
+		if err := netlink.LinkSetUp(lo); err != nil {
+			return nil, err
+		}
+		return net.Listen("tcp", address)
+	}()
+
+	// If the thread can't be returned to the host's namespace, leave it locked
+	// so that it is never reused.
+	if err := setns(host); err != nil {
+		if l != nil {
+			l.Close()
+		}
+		return nil, fmt.Errorf("failed to restore network namespace: %v", err)
+	}
+	runtime.UnlockOSThread()
+	return l, err
+}
+
+// setns moves the current thread into the network namespace of the file.
+func setns(f *os.File) error {
+	_, _, errno := syscall.RawSyscall(sysSetns, f.Fd(), syscall.CLONE_NEWNET, 0)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// stoppingMetadata closes the container's metadata service listener, if it has
+// one.
+func (c *Container) stoppingMetadata() error {
+	c.mutex.Lock()
+	l := c.metadataListener
+	c.metadataListener = nil
+	c.mutex.Unlock()
+	if l != nil {
+		l.Close()
+	}
+	return nil
+}
+
+// metadataContainer returns the container identified by the metadata token.
+func (manager *Manager) metadataContainer(token string) *Container {
+	if token == "" {
+		return nil
+	}
+	for _, c := range manager.Containers() {
+		if hmac.Equal([]byte(c.metadataToken), []byte(token)) {
+			return c
+		}
+	}
+	return nil
+}
+
+// metadataSignature returns the HMAC signature of the content on behalf of the
+// pod with the given UUID.
+func (manager *Manager) metadataSignature(uuid, content string) []byte {
+	mac := hmac.New(sha512.New, manager.metadataKey)
+	mac.Write([]byte(uuid))
+	mac.Write([]byte(content))
+	return mac.Sum(nil)
+}
+
+// serveMetadata handles requests to the metadata service. The path begins with
+// the token of the requesting pod, followed by the endpoint.
+func (manager *Manager) serveMetadata(w http.ResponseWriter, req *http.Request) {
+	if req.Header.Get("Metadata-Flavor") != "AppContainer" {
+		http.Error(w, "the Metadata-Flavor header must be AppContainer", http.StatusBadRequest)
+		return
+	}
+
+	parts := strings.SplitN(strings.TrimPrefix(req.URL.Path, "/"), "/", 2)
+	if len(parts) != 2 || !strings.HasPrefix(parts[1], metadataPrefix) {
+		http.NotFound(w, req)
+		return
+	}
+	c := manager.metadataContainer(parts[0])
+	if c == nil {
+		http.Error(w, "unknown pod", http.StatusUnauthorized)
+		return
+	}
+	path := strings.Split(strings.TrimPrefix(parts[1], metadataPrefix), "/")
+
+	switch {
+	case len(path) == 2 && path[0] == "pod" && path[1] == "uuid":
+		writeText(w, c.uuid)
+
+	case len(path) == 2 && path[0] == "pod" && path[1] == "manifest":
+		writeJSON(w, c.Manifest())
+
+	case len(path) == 3 && path[0] == "pod" && path[1] == "annotations":
+		writeAnnotations(w, req, c.Manifest().Annotations, path[2])
+
+	case len(path) == 3 && path[0] == "pod" && path[1] == "hmac" && path[2] == "sign":
+		if req.Method != "POST" {
+			http.Error(w, "sign requires a POST", http.StatusMethodNotAllowed)
+			return
+		}
+		sig := manager.metadataSignature(c.uuid, req.FormValue("content"))
+		writeText(w, base64.StdEncoding.EncodeToString(sig))
+
+	case len(path) == 3 && path[0] == "pod" && path[1] == "hmac" && path[2] == "verify":
+		if req.Method != "POST" {
+			http.Error(w, "verify requires a POST", http.StatusMethodNotAllowed)
+			return
+		}
+		sig, err := base64.StdEncoding.DecodeString(req.FormValue("signature"))
+		expected := manager.metadataSignature(req.FormValue("uid"), req.FormValue("content"))
+		if err != nil || !hmac.Equal(sig, expected) {
+			http.Error(w, "invalid signature", http.StatusForbidden)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+
+	case len(path) >= 3 && path[0] == "apps":
+		manager.serveAppMetadata(w, req, c, path[1], path[2:])
+
+	default:
+		http.NotFound(w, req)
+	}
+}
+
+// serveAppMetadata handles the metadata endpoints for an individual app within
+// the pod.
+func (manager *Manager) serveAppMetadata(w http.ResponseWriter, req *http.Request, c *Container, name string, path []string) {
+	var a *app
+	var index int
+	for i, ca := range c.apps {
+		if ca.name == name {
+			a, index = ca, i
+		}
+	}
+	if a == nil {
+		http.NotFound(w, req)
+		return
+	}
+	runtimeApp := c.Manifest().Apps[index]
+
+	switch {
+	case len(path) == 2 && path[0] == "annotations":
+		// the pod's annotations for the app take precedence over the image's
+		annotations := append(types.Annotations(nil), a.image.Annotations...)
+		for _, an := range runtimeApp.Annotations {
+			annotations.Set(an.Name, an.Value)
+		}
+		writeAnnotations(w, req, annotations, path[1])
+
+	case len(path) == 2 && path[0] == "image" && path[1] == "manifest":
+		writeJSON(w, a.image)
+
+	case len(path) == 2 && path[0] == "image" && path[1] == "id":
+		writeText(w, runtimeApp.Image.ID.String())
+
+	default:
+		http.NotFound(w, req)
+	}
+}
+
+// writeAnnotations writes the value of the named annotation, or the names of
+// all of the annotations when the name is blank.
+func writeAnnotations(w http.ResponseWriter, req *http.Request, annotations types.Annotations, name string) {
+	if name == "" {
+		names := make([]string, len(annotations))
+		for i, an := range annotations {
+			names[i] = an.Name.String()
+		}
+		sort.Strings(names)
+		for _, n := range names {
+			fmt.Fprintln(w, n)
+		}
+		return
+	}
+
+	value, ok := annotations.Get(name)
+	if !ok {
+		http.NotFound(w, req)
+		return
+	}
+	writeText(w, value)
+}
+
+func writeText(w http.ResponseWriter, s string) {
+	w.Header().Set("Content-Type", "text/plain; charset=us-ascii")
+	fmt.Fprint(w, s)
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(b)
+}
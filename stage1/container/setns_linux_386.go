@@ -0,0 +1,6 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package container
+
+// sysSetns is the setns syscall number on linux/386.
+const sysSetns = 346
@@ -0,0 +1,263 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package container
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	client3 "github.com/apcera/kurma/stage3/client"
+	"github.com/appc/spec/schema"
+)
+
+// stateFilename is the name of the file within the container's directory that
+// its state record is written to.
+const stateFilename = "state"
+
+// containerRecord is the durable representation of a Container that is written
+// within its directory. It contains the information needed for the Manager to
+// recover the Container after the daemon has been restarted.
+type containerRecord struct {
+	UUID       string                            `json:"uuid"`
+	Pod        *schema.PodManifest               `json:"pod"`
+	Images     []*schema.ImageManifest           `json:"images"`
+	State      ContainerState                    `json:"state"`
+	Error      string                            `json:"error,omitempty"`
+	Restarts   map[string]int                    `json:"restarts,omitempty"`
+	Statuses   map[string]*client3.ProcessStatus `json:"statuses,omitempty"`
+	OOMKills   int                               `json:"oom_kills,omitempty"`
+	SocketPath string                            `json:"socket_path"`
+	CgroupName string                            `json:"cgroup_name"`
+
+	// StorageDriver is the name of the driver the apps' root filesystems were
+	// prepared by.
+	StorageDriver string `json:"storage_driver,omitempty"`
+
+	UserNamespaceOffset int    `json:"user_namespace_offset,omitempty"`
+	MetadataToken       string `json:"metadata_token,omitempty"`
+}
+
+// saveState writes the current state of the container to its state file. The
+// file is written to a temporary location first and then renamed over the
+// existing file so that a crash mid-write never leaves a partial record.
+func (c *Container) saveState() error {
+	c.mutex.Lock()
+	if c.directory == "" {
+		c.mutex.Unlock()
+		return nil
+	}
+	record := &containerRecord{
+		UUID:       c.uuid,
+		Pod:        c.pod,
+		State:      c.state,
+		Error:      c.startupError,
+		Restarts:   make(map[string]int),
+		Statuses:   c.appStatuses,
+		OOMKills:   c.oomKills,
+		SocketPath: c.socketPath(),
+		CgroupName: c.ShortName(),
+
+		UserNamespaceOffset: c.userNamespaceOffset,
+		MetadataToken:       c.metadataToken,
+	}
+	if c.storage != nil {
+		record.StorageDriver = c.storage.Name()
+	}
+	for _, a := range c.apps {
+		record.Images = append(record.Images, a.image)
+		if a.restarts > 0 {
+			record.Restarts[a.name] = a.restarts
+		}
+	}
+	c.mutex.Unlock()
+
+	b, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	// the directory is gone once the container has been torn down, leaving
+	// nothing to record
+	tmpfile := c.statePath() + ".tmp"
+	if err := ioutil.WriteFile(tmpfile, b, os.FileMode(0600)); err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	return os.Rename(tmpfile, c.statePath())
+}
+
+// persist saves the container's state and logs any failure. It is used on
+// state transitions where a failure to save shouldn't halt the transition.
+func (c *Container) persist() {
+	if err := c.saveState(); err != nil {
+		c.log.Errorf("failed to save container state: %v", err)
+	}
+}
+
+// Recover walks the container directory and recreates the Container objects
+// for any containers which have a state record from a previous run of the
+// daemon. It should be called once the container directory and the storage
+// driver's data are available, and before any new containers are created.
+func (manager *Manager) Recover() error {
+	// the pods' identities are signed with the metadata key, so it is kept with
+	// the containers
+	if manager.metadataPort > 0 {
+		if err := manager.loadMetadataKey(); err != nil {
+			return fmt.Errorf("failed to load the metadata key: %v", err)
+		}
+	}
+
+	fis, err := ioutil.ReadDir(manager.directory)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	for _, fi := range fis {
+		if !fi.IsDir() {
+			continue
+		}
+
+		// other data may be kept alongside the containers, such as the images,
+		// so only directories with a state record are containers
+		directory := filepath.Join(manager.directory, fi.Name())
+		if _, err := os.Stat(filepath.Join(directory, stateFilename)); os.IsNotExist(err) {
+			continue
+		}
+
+		container, err := manager.recoverContainer(directory)
+		if err != nil {
+			manager.Log.Warnf("Unable to recover container at %q: %v", directory, err)
+			continue
+		}
+
+		manager.containersLock.Lock()
+		manager.containers[container.uuid] = container
+		manager.containersLock.Unlock()
+		if container.usesUserNamespace() {
+			manager.reserveUserNamespace(container.userNamespaceOffset)
+		}
+		manager.Log.Infof("Recovered container %s", container.uuid)
+	}
+	return nil
+}
+
+// recoverContainer loads the state record from the provided directory and
+// rebuilds the Container from it, reconnecting it with its cgroup and stage3
+// process.
+func (manager *Manager) recoverContainer(directory string) (*Container, error) {
+	b, err := ioutil.ReadFile(filepath.Join(directory, stateFilename))
+	if err != nil {
+		return nil, err
+	}
+	var record *containerRecord
+	if err := json.Unmarshal(b, &record); err != nil {
+		return nil, fmt.Errorf("failed to parse state record: %v", err)
+	}
+	if record.UUID == "" || record.Pod == nil || len(record.Images) == 0 ||
+		len(record.Images) != len(record.Pod.Apps) {
+		return nil, fmt.Errorf("state record is incomplete")
+	}
+
+	// the apps' filesystems must be released by the driver that prepared them,
+	// which may not be the driver now used for new containers
+	storage, err := manager.recoverStorageDriver(record.StorageDriver)
+	if err != nil {
+		return nil, fmt.Errorf("failed to set up the %q storage driver: %v", record.StorageDriver, err)
+	}
+
+	container := &Container{
+		manager:     manager,
+		log:         manager.Log.Clone(),
+		uuid:        record.UUID,
+		waitch:      make(chan bool),
+		pod:         record.Pod,
+		directory:   directory,
+		appStatuses: record.Statuses,
+		oomKills:    record.OOMKills,
+		storage:     storage,
+
+		userNamespaceOffset: record.UserNamespaceOffset,
+		metadataToken:       record.MetadataToken,
+	}
+	for i, image := range record.Images {
+		if image == nil || image.App == nil {
+			return nil, fmt.Errorf("state record is incomplete")
+		}
+		name := record.Pod.Apps[i].Name.String()
+		container.apps = append(container.apps, &app{
+			name:     name,
+			image:    image,
+			restarts: record.Restarts[name],
+		})
+	}
+	container.log.SetField("container", container.uuid)
+
+	// rebuild the environment, it is derived solely from the image manifests
+	if err := container.startingEnvironment(); err != nil {
+		return nil, err
+	}
+
+	// containers that had failed or already exited only need to be tracked so
+	// they can be inspected and destroyed
+	if record.State == FAILED {
+		container.state = FAILED
+		container.startupError = record.Error
+		close(container.waitch)
+		return container, nil
+	}
+
+	// a container whose startup was interrupted can't be resumed, so it is
+	// failed and whatever it had set up is released
+	if record.State == STARTING {
+		if manager.cgroup != nil {
+			container.cgroup = manager.cgroup.Recover(record.CgroupName)
+		}
+		container.log.Warn("Startup was interrupted, marking failed.")
+		container.markFailed(fmt.Errorf("startup was interrupted by a restart of the daemon"))
+		return container, nil
+	}
+	if record.State != RUNNING {
+		container.markExited("container had exited prior to recovery")
+		return container, nil
+	}
+
+	container.state = RUNNING
+
+	// reattach the cgroup, if it is gone then so are the processes
+	cgroup := manager.cgroup.Recover(record.CgroupName)
+	if destroyed, err := cgroup.Destroyed(); err != nil {
+		return nil, err
+	} else if destroyed {
+		container.log.Warn("Cgroup no longer exists, marking exited.")
+		container.markExited("cgroup no longer exists after recovery")
+		return container, nil
+	}
+	container.cgroup = cgroup
+	container.watchOOM()
+
+	// reconnect to the stage3 process and ensure it is responding
+	initdClient := client3.New(record.SocketPath)
+	if _, err := initdClient.Status(time.Second); err != nil {
+		container.log.Warnf("Unable to reconnect to stage3, marking exited: %v", err)
+		container.markExited(fmt.Sprintf("unable to reconnect to stage3: %v", err))
+		return container, nil
+	}
+	container.initdClient = initdClient
+
+	// the listener within the pod's network namespace didn't survive the restart
+	if err := container.serveMetadata(); err != nil {
+		container.log.Warnf("Unable to serve the metadata service: %v", err)
+	}
+
+	go container.waitLoop()
+	return container, nil
+}
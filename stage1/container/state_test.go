@@ -0,0 +1,108 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package container
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/apcera/logray"
+	"github.com/appc/spec/schema"
+
+	. "github.com/apcera/util/testtool"
+)
+
+// newStateManager returns a manager whose containers are kept in a temporary
+// directory, without a cgroup.
+func newStateManager(t *testing.T) *Manager {
+	return &Manager{
+		Log:              logray.New(),
+		containers:       make(map[string]*Container),
+		subscribers:      make(map[chan *Event]bool),
+		directory:        TempDir(t),
+		storageDirectory: TempDir(t),
+		storageDrivers:   make(map[string]StorageDriver),
+		userNamespaces:   make(map[int]bool),
+	}
+}
+
+// newStateContainer returns a container with a single app which has had its
+// directory created.
+func newStateContainer(t *testing.T, m *Manager) *Container {
+	var pod *schema.PodManifest
+	TestExpectSuccess(t, json.Unmarshal([]byte(
+		`{"acKind":"PodManifest","acVersion":"0.7.1","apps":[{"name":"test","image":{"id":"sha512-0123"}}]}`),
+		&pod))
+	var image *schema.ImageManifest
+	TestExpectSuccess(t, json.Unmarshal([]byte(
+		`{"acKind":"ImageManifest","acVersion":"0.7.1","name":"example.com/test",
+		  "app":{"exec":["/bin/true"],"user":"0","group":"0"}}`),
+		&image))
+
+	storage, err := m.recoverStorageDriver("")
+	TestExpectSuccess(t, err)
+	c := &Container{
+		manager: m,
+		log:     m.Log.Clone(),
+		uuid:    "01234567-89ab-cdef-0123-456789abcdef",
+		waitch:  make(chan bool),
+		pod:     pod,
+		apps:    []*app{{name: "test", image: image}},
+		storage: storage,
+	}
+	TestExpectSuccess(t, c.startingBaseDirectories())
+	return c
+}
+
+func TestContainer_FailedStateIsRecovered(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	m := newStateManager(t)
+	c := newStateContainer(t, m)
+	c.markFailed(fmt.Errorf("failed to start"))
+	TestEqual(t, c.State(), FAILED)
+
+	// The cleanup removes everything but the state record.
+	TestEqual(t, listFiles(t, m.directory), []string{c.ShortName(), filepath.Join(c.ShortName(), stateFilename)})
+
+	TestExpectSuccess(t, m.Recover())
+	recovered := m.Containers()
+	TestEqual(t, len(recovered), 1)
+	TestEqual(t, recovered[0].UUID(), c.UUID())
+	TestEqual(t, recovered[0].State(), FAILED)
+	TestEqual(t, recovered[0].StartupError(), "failed to start")
+
+	// Recovered failed containers are done, so waiting doesn't block.
+	recovered[0].Wait()
+}
+
+func TestContainer_InterruptedStartupIsFailed(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	// The container is recorded as starting as soon as its directory exists.
+	m := newStateManager(t)
+	c := newStateContainer(t, m)
+	TestExpectSuccess(t, os.RemoveAll(c.directory))
+	c.setState(STARTING, "container is being created")
+	TestExpectSuccess(t, c.startingBaseDirectories())
+	TestEqual(t, listFiles(t, c.directory), []string{stateFilename})
+
+	// Other data kept in the container directory isn't treated as a container.
+	TestExpectSuccess(t, os.Mkdir(filepath.Join(m.directory, "images"), 0755))
+
+	TestExpectSuccess(t, m.Recover())
+	recovered := m.Containers()
+	TestEqual(t, len(recovered), 1)
+	TestEqual(t, recovered[0].UUID(), c.UUID())
+	TestEqual(t, recovered[0].State(), FAILED)
+	TestEqual(t, recovered[0].StartupError(), "startup was interrupted by a restart of the daemon")
+
+	// The cleanup removes everything but the state record.
+	TestEqual(t, listFiles(t, filepath.Join(m.directory, c.ShortName())), []string{stateFilename})
+	recovered[0].Wait()
+}
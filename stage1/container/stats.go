@@ -0,0 +1,82 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package container
+
+import (
+	"fmt"
+	"time"
+)
+
+// unlimitedMemory is the threshold above which a cgroup's memory limit is
+// treated as not being set. The kernel's default is the largest page aligned
+// int64, rather than a fixed value.
+const unlimitedMemory = int64(1 << 62)
+
+// Stats is a sample of the resources used by the processes within a container.
+type Stats struct {
+	// Time is when the sample was taken.
+	Time time.Time
+
+	// CPUTime is the total CPU time consumed by the container's processes.
+	CPUTime time.Duration
+
+	// MemoryUsed is the number of bytes of memory currently in use, and
+	// MemoryLimit the number it is limited to. The limit is 0 when the
+	// container's memory is not limited.
+	MemoryUsed  int64
+	MemoryLimit int64
+
+	// DiskUsed is the number of bytes of disk used by the root filesystems of
+	// the container's apps. Storage drivers which share the image between apps
+	// only count the changes the container made to it.
+	DiskUsed int64
+
+	// Tasks is the number of processes within the container.
+	Tasks int
+}
+
+// Stats samples the resource usage of the container. It returns an error if
+// the container doesn't have a cgroup, such as when it hasn't started yet or
+// has already been torn down.
+func (c *Container) Stats() (*Stats, error) {
+	c.mutex.Lock()
+	cgroup := c.cgroup
+	c.mutex.Unlock()
+	if cgroup == nil {
+		return nil, fmt.Errorf("container %s is not running", c.ShortName())
+	}
+
+	stats := &Stats{Time: time.Now()}
+
+	cpu, err := cgroup.CPUUsed()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CPU usage: %v", err)
+	}
+	stats.CPUTime = time.Duration(cpu)
+
+	if stats.MemoryUsed, err = cgroup.MemoryUsed(); err != nil {
+		return nil, fmt.Errorf("failed to read memory usage: %v", err)
+	}
+	if stats.MemoryLimit, err = cgroup.MemoryLimit(); err != nil {
+		return nil, fmt.Errorf("failed to read memory limit: %v", err)
+	}
+	if stats.MemoryLimit >= unlimitedMemory {
+		stats.MemoryLimit = 0
+	}
+
+	for _, a := range c.apps {
+		used, err := c.storage.Usage(c.storageID(a), c.appPath(a))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read disk usage: %v", err)
+		}
+		stats.DiskUsed += used
+	}
+
+	tasks, err := cgroup.Tasks()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tasks: %v", err)
+	}
+	stats.Tasks = len(tasks)
+
+	return stats, nil
+}
@@ -0,0 +1,294 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package container
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+const (
+	// DirectoryStorageDriver extracts a full copy of the image for each app.
+	DirectoryStorageDriver = "directory"
+
+	// OverlayStorageDriver mounts an overlay filesystem for each app, with the
+	// rendered image as a shared read only lower layer.
+	OverlayStorageDriver = "overlay"
+
+	// BtrfsStorageDriver creates a btrfs snapshot of the rendered image for
+	// each app.
+	BtrfsStorageDriver = "btrfs"
+)
+
+// StorageDriver handles preparing and releasing the root filesystems of the
+// apps within a container.
+type StorageDriver interface {
+	// Name returns the name the driver is configured by. It is recorded with
+	// each container so the container's filesystems are released by the same
+	// driver after a restart.
+	Name() string
+
+	// Prepare creates the root filesystem for an app at "rootfs" within the
+	// app's path. The id uniquely identifies the app on the host. The layer is
+	// the identifier of the rendered image, which drivers may use to share the
+	// rendered image between apps. The render function writes the image,
+	// including its "rootfs", into the directory it is given.
+	Prepare(id, path, layer string, render func(dir string) error) error
+
+	// Release tears down the root filesystem created for an app. It is called
+	// once the container's filesystems have been unmounted, and must tolerate
+	// being called for apps which were never prepared.
+	Release(id, path string) error
+
+	// Usage returns the number of bytes of disk used by the app's root
+	// filesystem, excluding any data shared with other apps where the driver
+	// is able to.
+	Usage(id, path string) (int64, error)
+
+	// Collect removes any data the driver holds which is no longer used by an
+	// app. It is called after a container's apps have been released.
+	Collect() error
+}
+
+// newStorageDriver returns the storage driver with the given name. The cached
+// drivers store their rendered images and per-app data within the directory.
+func newStorageDriver(name, directory string) (StorageDriver, error) {
+	switch name {
+	case "", DirectoryStorageDriver:
+		return &directoryDriver{}, nil
+	case OverlayStorageDriver:
+		return newOverlayDriver(directory)
+	case BtrfsStorageDriver:
+		return newBtrfsDriver(directory)
+	default:
+		return nil, fmt.Errorf("unrecognized storage driver %q", name)
+	}
+}
+
+// directoryDriver is a StorageDriver which renders the image directly into the
+// app's path. The filesystem is removed along with the container's directory.
+type directoryDriver struct{}
+
+func (d *directoryDriver) Name() string {
+	return DirectoryStorageDriver
+}
+
+func (d *directoryDriver) Prepare(id, path, layer string, render func(dir string) error) error {
+	return render(path)
+}
+
+func (d *directoryDriver) Release(id, path string) error {
+	return nil
+}
+
+func (d *directoryDriver) Usage(id, path string) (int64, error) {
+	return diskUsage(filepath.Join(path, "rootfs"))
+}
+
+func (d *directoryDriver) Collect() error {
+	return nil
+}
+
+// diskUsage returns the number of bytes of disk allocated to the files within
+// the directory. Filesystems mounted within it aren't included, and files with
+// multiple links are only counted once.
+func diskUsage(dir string) (int64, error) {
+	fi, err := os.Lstat(dir)
+	if err != nil {
+		return 0, err
+	}
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, fmt.Errorf("unable to determine the device of %q", dir)
+	}
+	dev := st.Dev
+
+	var used int64
+	seen := make(map[uint64]bool)
+	err = filepath.Walk(dir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			// files may be removed by the container while walking
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		st, ok := fi.Sys().(*syscall.Stat_t)
+		if !ok {
+			return nil
+		}
+		if st.Dev != dev {
+			if fi.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if st.Nlink > 1 && !fi.IsDir() {
+			if seen[st.Ino] {
+				return nil
+			}
+			seen[st.Ino] = true
+		}
+		used += st.Blocks * 512
+		return nil
+	})
+	return used, err
+}
+
+// layerReferenceFilename is the name of the file within an app's directory in
+// the layer cache that holds the name of the layer the app uses.
+const layerReferenceFilename = "layer"
+
+// layerCache tracks the rendered images used by the cached storage drivers.
+// Each layer is rendered once and is then kept for use by later apps, until no
+// app references it.
+type layerCache struct {
+	directory string
+	locks     map[string]*sync.Mutex
+	locksLock sync.Mutex
+}
+
+func newLayerCache(directory string) (*layerCache, error) {
+	if directory == "" {
+		return nil, fmt.Errorf("a storage directory must be specified")
+	}
+	for _, dir := range []string{"layers", "apps"} {
+		if err := os.MkdirAll(filepath.Join(directory, dir), os.FileMode(0755)); err != nil {
+			return nil, err
+		}
+	}
+	return &layerCache{
+		directory: directory,
+		locks:     make(map[string]*sync.Mutex),
+	}, nil
+}
+
+// lock returns the lock used to serialize rendering the layer.
+func (lc *layerCache) lock(layer string) *sync.Mutex {
+	lc.locksLock.Lock()
+	defer lc.locksLock.Unlock()
+	l, exists := lc.locks[layer]
+	if !exists {
+		l = &sync.Mutex{}
+		lc.locks[layer] = l
+	}
+	return l
+}
+
+// get returns the path of the rendered layer, rendering it if it doesn't exist
+// yet. The layer is rendered into a temporary directory and moved into place
+// once complete, so a partially rendered layer is never used. createRootfs is
+// called to create the layer's "rootfs" directory before it is rendered.
+func (lc *layerCache) get(layer string, render func(dir string) error, createRootfs func(path string) error) (string, error) {
+	l := lc.lock(layer)
+	l.Lock()
+	defer l.Unlock()
+
+	path := filepath.Join(lc.directory, "layers", layer)
+	if _, err := os.Stat(path); err == nil {
+		return path, nil
+	} else if !os.IsNotExist(err) {
+		return "", err
+	}
+
+	tmp := path + ".tmp"
+	if err := os.RemoveAll(tmp); err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(tmp, os.FileMode(0755)); err != nil {
+		return "", err
+	}
+	if err := createRootfs(filepath.Join(tmp, "rootfs")); err != nil {
+		return "", err
+	}
+	if err := render(tmp); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// appDirectory returns the directory the driver keeps per-app data within.
+func (lc *layerCache) appDirectory(id string) string {
+	return filepath.Join(lc.directory, "apps", id)
+}
+
+// reference records that the app with the given id uses the layer. This is
+// done before the layer is retrieved, so that the layer isn't collected while
+// the app is being prepared.
+func (lc *layerCache) reference(id, layer string) error {
+	dir := lc.appDirectory(id)
+	if err := os.MkdirAll(dir, os.FileMode(0755)); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(dir, layerReferenceFilename), []byte(layer), os.FileMode(0644))
+}
+
+// references returns the set of layers used by any app.
+func (lc *layerCache) references() (map[string]bool, error) {
+	fis, err := ioutil.ReadDir(filepath.Join(lc.directory, "apps"))
+	if err != nil {
+		return nil, err
+	}
+	layers := make(map[string]bool)
+	for _, fi := range fis {
+		b, err := ioutil.ReadFile(filepath.Join(lc.directory, "apps", fi.Name(), layerReferenceFilename))
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		layers[string(b)] = true
+	}
+	return layers, nil
+}
+
+// collect removes the layers which are no longer referenced by any app. The
+// remove function is called on each layer's "rootfs" before the layer's
+// directory is removed.
+func (lc *layerCache) collect(remove func(path string) error) error {
+	fis, err := ioutil.ReadDir(filepath.Join(lc.directory, "layers"))
+	if err != nil {
+		return err
+	}
+	for _, fi := range fis {
+		layer := fi.Name()
+		if strings.HasSuffix(layer, ".tmp") {
+			continue
+		}
+		if err := lc.collectLayer(layer, remove); err != nil {
+			return fmt.Errorf("failed to remove layer %s: %v", layer, err)
+		}
+	}
+	return nil
+}
+
+// collectLayer removes the layer if it isn't referenced. The layer's lock is
+// held so it can't be retrieved for a new app at the same time.
+func (lc *layerCache) collectLayer(layer string, remove func(path string) error) error {
+	l := lc.lock(layer)
+	l.Lock()
+	defer l.Unlock()
+
+	refs, err := lc.references()
+	if err != nil {
+		return err
+	}
+	if refs[layer] {
+		return nil
+	}
+	path := filepath.Join(lc.directory, "layers", layer)
+	if remove != nil {
+		if err := remove(filepath.Join(path, "rootfs")); err != nil {
+			return err
+		}
+	}
+	return os.RemoveAll(path)
+}
@@ -0,0 +1,145 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package container
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	// btrfsSuperMagic is the filesystem type reported by statfs for btrfs.
+	btrfsSuperMagic = 0x9123683e
+
+	// btrfsFirstFreeObjectid is the inode number of the root of a subvolume.
+	btrfsFirstFreeObjectid = 256
+
+	// The btrfs ioctls, defined in linux/btrfs.h.
+	btrfsIocSnapCreate   = 0x50009401
+	btrfsIocSubvolCreate = 0x5000940e
+	btrfsIocSnapDestroy  = 0x5000940f
+)
+
+// btrfsVolArgs matches struct btrfs_ioctl_vol_args from linux/btrfs.h.
+type btrfsVolArgs struct {
+	fd   int64
+	name [4088]byte
+}
+
+// btrfsDriver is a StorageDriver which creates a btrfs snapshot of the rendered
+// image for each app. The rendered images are kept as subvolumes, so the
+// storage directory and the container directory must be on the same btrfs
+// filesystem.
+type btrfsDriver struct {
+	layers *layerCache
+}
+
+func newBtrfsDriver(directory string) (*btrfsDriver, error) {
+	layers, err := newLayerCache(directory)
+	if err != nil {
+		return nil, err
+	}
+
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(directory, &st); err != nil {
+		return nil, err
+	}
+	if st.Type != btrfsSuperMagic {
+		return nil, fmt.Errorf("the storage directory %q is not on a btrfs filesystem", directory)
+	}
+	return &btrfsDriver{layers: layers}, nil
+}
+
+func (d *btrfsDriver) Name() string {
+	return BtrfsStorageDriver
+}
+
+func (d *btrfsDriver) Prepare(id, path, layer string, render func(dir string) error) error {
+	if err := d.layers.reference(id, layer); err != nil {
+		return err
+	}
+	lower, err := d.layers.get(layer, render, func(path string) error {
+		return btrfsIoctl(filepath.Dir(path), btrfsIocSubvolCreate, filepath.Base(path), 0)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to render layer %s: %v", layer, err)
+	}
+
+	src, err := os.Open(filepath.Join(lower, "rootfs"))
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	if err := btrfsIoctl(path, btrfsIocSnapCreate, "rootfs", int64(src.Fd())); err != nil {
+		return fmt.Errorf("failed to snapshot layer %s: %v", layer, err)
+	}
+	return nil
+}
+
+func (d *btrfsDriver) Release(id, path string) error {
+	if err := destroySnapshot(path); err != nil {
+		return err
+	}
+	return os.RemoveAll(d.layers.appDirectory(id))
+}
+
+// Usage returns the size of the files within the app's snapshot. The data it
+// shares with the rendered image can't be told apart without btrfs quotas, so
+// it is included.
+func (d *btrfsDriver) Usage(id, path string) (int64, error) {
+	return diskUsage(filepath.Join(path, "rootfs"))
+}
+
+func (d *btrfsDriver) Collect() error {
+	return d.layers.collect(func(path string) error {
+		return destroySnapshot(filepath.Dir(path))
+	})
+}
+
+// destroySnapshot removes the "rootfs" subvolume within the directory, if
+// there is one.
+func destroySnapshot(path string) error {
+	rootfs := filepath.Join(path, "rootfs")
+	fi, err := os.Lstat(rootfs)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	// only remove the subvolume, the directory driver may have been used when
+	// the app was created
+	if st, ok := fi.Sys().(*syscall.Stat_t); !ok || st.Ino != btrfsFirstFreeObjectid {
+		return nil
+	}
+	if err := btrfsIoctl(path, btrfsIocSnapDestroy, "rootfs", 0); err != nil {
+		return fmt.Errorf("failed to remove snapshot %q: %v", rootfs, err)
+	}
+	return nil
+}
+
+// btrfsIoctl issues a btrfs volume ioctl against the directory, passing the
+// name of the subvolume within it and the file descriptor argument.
+func btrfsIoctl(dir string, op uintptr, name string, fd int64) error {
+	f, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	args := &btrfsVolArgs{fd: fd}
+	if len(name) >= len(args.name) {
+		return fmt.Errorf("subvolume name %q is too long", name)
+	}
+	copy(args.name[:], name)
+
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), op, uintptr(unsafe.Pointer(args)))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
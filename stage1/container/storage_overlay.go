@@ -0,0 +1,98 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package container
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+// overlayDriver is a StorageDriver which mounts an overlay filesystem for each
+// app. The rendered image is the read only lower layer shared by all apps using
+// the image, while each app gets its own writable upper layer.
+type overlayDriver struct {
+	layers *layerCache
+}
+
+func newOverlayDriver(directory string) (*overlayDriver, error) {
+	b, err := ioutil.ReadFile("/proc/filesystems")
+	if err != nil {
+		return nil, err
+	}
+	if !strings.Contains(string(b), "\toverlay\n") {
+		return nil, fmt.Errorf("the kernel does not support overlay filesystems")
+	}
+
+	layers, err := newLayerCache(directory)
+	if err != nil {
+		return nil, err
+	}
+	return &overlayDriver{layers: layers}, nil
+}
+
+func (d *overlayDriver) Name() string {
+	return OverlayStorageDriver
+}
+
+func (d *overlayDriver) Prepare(id, path, layer string, render func(dir string) error) error {
+	if err := d.layers.reference(id, layer); err != nil {
+		return err
+	}
+	lower, err := d.layers.get(layer, render, func(path string) error {
+		return os.Mkdir(path, os.FileMode(0755))
+	})
+	if err != nil {
+		return fmt.Errorf("failed to render layer %s: %v", layer, err)
+	}
+	lower = filepath.Join(lower, "rootfs")
+
+	dir := d.layers.appDirectory(id)
+	upper := filepath.Join(dir, "upper")
+	work := filepath.Join(dir, "work")
+	if err := mkdirs([]string{dir, upper, work}, os.FileMode(0755), true); err != nil {
+		return err
+	}
+
+	// The root of the overlay takes its ownership and mode from the upper
+	// layer, so match it to the image's root.
+	fi, err := os.Stat(lower)
+	if err != nil {
+		return err
+	}
+	if st, ok := fi.Sys().(*syscall.Stat_t); ok {
+		if err := os.Chown(upper, int(st.Uid), int(st.Gid)); err != nil {
+			return err
+		}
+	}
+	if err := os.Chmod(upper, fi.Mode().Perm()); err != nil {
+		return err
+	}
+
+	rootfs := filepath.Join(path, "rootfs")
+	if err := os.MkdirAll(rootfs, os.FileMode(0755)); err != nil {
+		return err
+	}
+	data := fmt.Sprintf("lowerdir=%s,upperdir=%s,workdir=%s", lower, upper, work)
+	if err := syscall.Mount("overlay", rootfs, "overlay", 0, data); err != nil {
+		return fmt.Errorf("failed to mount overlay filesystem: %v", err)
+	}
+	return nil
+}
+
+func (d *overlayDriver) Release(id, path string) error {
+	return os.RemoveAll(d.layers.appDirectory(id))
+}
+
+// Usage returns the size of the app's upper layer, which holds only the changes
+// made to the shared image.
+func (d *overlayDriver) Usage(id, path string) (int64, error) {
+	return diskUsage(filepath.Join(d.layers.appDirectory(id), "upper"))
+}
+
+func (d *overlayDriver) Collect() error {
+	return d.layers.collect(nil)
+}
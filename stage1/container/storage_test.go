@@ -0,0 +1,56 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package container
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/apcera/util/testtool"
+)
+
+func TestLayerCache_Collect(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	lc, err := newLayerCache(TempDir(t))
+	TestExpectSuccess(t, err)
+
+	render := func(dir string) error { return nil }
+	mkdir := func(path string) error { return os.Mkdir(path, os.FileMode(0755)) }
+
+	// Two apps share the first layer, while a third uses another.
+	for _, ref := range [][2]string{{"a", "layer1"}, {"b", "layer1"}, {"c", "layer2"}} {
+		TestExpectSuccess(t, lc.reference(ref[0], ref[1]))
+		_, err := lc.get(ref[1], render, mkdir)
+		TestExpectSuccess(t, err)
+	}
+
+	exists := func(layer string) bool {
+		_, err := os.Stat(filepath.Join(lc.directory, "layers", layer))
+		return err == nil
+	}
+
+	// Nothing is collected while every layer is referenced.
+	TestExpectSuccess(t, lc.collect(nil))
+	TestEqual(t, exists("layer1"), true)
+	TestEqual(t, exists("layer2"), true)
+
+	// Releasing one of the apps sharing a layer keeps it.
+	TestExpectSuccess(t, os.RemoveAll(lc.appDirectory("a")))
+	TestExpectSuccess(t, lc.collect(nil))
+	TestEqual(t, exists("layer1"), true)
+
+	// Releasing the last app using a layer removes it, calling the remove
+	// function on its rootfs first.
+	TestExpectSuccess(t, os.RemoveAll(lc.appDirectory("c")))
+	var removed []string
+	TestExpectSuccess(t, lc.collect(func(path string) error {
+		removed = append(removed, path)
+		return nil
+	}))
+	TestEqual(t, exists("layer1"), true)
+	TestEqual(t, exists("layer2"), false)
+	TestEqual(t, removed, []string{filepath.Join(lc.directory, "layers", "layer2", "rootfs")})
+}
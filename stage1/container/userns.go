@@ -0,0 +1,134 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package container
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+
+	kschema "github.com/apcera/kurma/schema"
+	"github.com/appc/spec/schema"
+)
+
+// maxNamespaceId is the largest uid or gid which can be mapped into a user
+// namespace.
+const maxNamespaceId int64 = 1<<32 - 2
+
+// lastNamespaceId returns the largest id which may be allocated to a user
+// namespace range. Ids are held as ints, so on 32-bit platforms this is below
+// maxNamespaceId.
+func lastNamespaceId() int64 {
+	if maxInt := int64(^uint(0) >> 1); maxInt < maxNamespaceId {
+		return maxInt
+	}
+	return maxNamespaceId
+}
+
+// allocateUserNamespace reserves a range of host uids and gids for a container's
+// user namespace that doesn't overlap with the range of any other container. It
+// returns the first id of the range.
+func (manager *Manager) allocateUserNamespace() (int, error) {
+	if manager.userNamespaceRangeSize == 0 {
+		return 0, fmt.Errorf("user namespaces are not configured on this host")
+	}
+
+	manager.userNamespacesLock.Lock()
+	defer manager.userNamespacesLock.Unlock()
+
+	size := int64(manager.userNamespaceRangeSize)
+	last := lastNamespaceId()
+	for offset := int64(manager.userNamespaceOffset); offset+size-1 <= last; offset += size {
+		if !manager.userNamespaces[int(offset)] {
+			manager.userNamespaces[int(offset)] = true
+			return int(offset), nil
+		}
+	}
+	return 0, fmt.Errorf("no user namespace ranges are available")
+}
+
+// reserveUserNamespace marks the range starting at offset as in use. It is used
+// when recovering containers.
+func (manager *Manager) reserveUserNamespace(offset int) {
+	manager.userNamespacesLock.Lock()
+	manager.userNamespaces[offset] = true
+	manager.userNamespacesLock.Unlock()
+}
+
+// releaseUserNamespace returns the range starting at offset so that it can be
+// used by another container.
+func (manager *Manager) releaseUserNamespace(offset int) {
+	manager.userNamespacesLock.Lock()
+	delete(manager.userNamespaces, offset)
+	manager.userNamespacesLock.Unlock()
+}
+
+// wantsUserNamespace returns whether a pod whose primary app uses the image
+// should be given a user namespace. Unless the image's namespaces isolator says
+// otherwise, pods use one whenever user namespaces are configured.
+func (manager *Manager) wantsUserNamespace(image *schema.ImageManifest) bool {
+	if isHostPrivileged(image) {
+		return false
+	}
+	if iso := image.App.Isolators.GetByName(kschema.LinuxNamespacesName); iso != nil {
+		if niso, ok := iso.Value().(*kschema.LinuxNamespaces); ok {
+			return niso.User()
+		}
+	}
+	return manager.userNamespaceRangeSize > 0
+}
+
+// usesUserNamespace returns whether the container's apps run within their own
+// user namespace.
+func (c *Container) usesUserNamespace() bool {
+	return c.userNamespaceOffset > 0
+}
+
+// userNamespaceMap returns the uid and gid map used for the container's user
+// namespace, mapping root within the container to the start of its range.
+func (c *Container) userNamespaceMap() string {
+	return fmt.Sprintf("0 %d %d\n", c.userNamespaceOffset, c.manager.userNamespaceRangeSize)
+}
+
+// chownToNamespace changes the ownership of the path to the given uid and gid
+// within the container's user namespace. It does nothing when the container
+// doesn't have its own user namespace.
+func (c *Container) chownToNamespace(path string, uid, gid int) error {
+	if !c.usesUserNamespace() {
+		return nil
+	}
+	return os.Lchown(path, uid+c.userNamespaceOffset, gid+c.userNamespaceOffset)
+}
+
+// shiftOwnership walks the path and shifts the ownership of everything within
+// it into the container's user namespace range. This is applied to the image
+// filesystems after extraction so that they are owned by the same users within
+// the container as within the image.
+func (c *Container) shiftOwnership(path string) error {
+	if !c.usesUserNamespace() {
+		return nil
+	}
+	return filepath.Walk(path, func(p string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		st, ok := fi.Sys().(*syscall.Stat_t)
+		if !ok {
+			return fmt.Errorf("unable to determine the owner of %q", p)
+		}
+		uid, gid := int(st.Uid), int(st.Gid)
+		if uid >= c.manager.userNamespaceRangeSize || gid >= c.manager.userNamespaceRangeSize {
+			return fmt.Errorf("the owner of %q is outside of the user namespace range", p)
+		}
+		if err := c.chownToNamespace(p, uid, gid); err != nil {
+			return err
+		}
+
+		// chown clears the setuid and setgid bits, so restore the mode.
+		if fi.Mode()&os.ModeSymlink == 0 && fi.Mode()&(os.ModeSetuid|os.ModeSetgid) != 0 {
+			return os.Chmod(p, fi.Mode())
+		}
+		return nil
+	})
+}
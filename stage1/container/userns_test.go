@@ -0,0 +1,88 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package container
+
+import (
+	"testing"
+
+	. "github.com/apcera/util/testtool"
+)
+
+func newUserNamespaceManager(offset, size int) *Manager {
+	return &Manager{
+		userNamespaceOffset:    offset,
+		userNamespaceRangeSize: size,
+		userNamespaces:         make(map[int]bool),
+	}
+}
+
+func TestManager_AllocateUserNamespace(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	m := newUserNamespaceManager(100000, 65536)
+
+	// Ranges are handed out in order from the offset.
+	for _, want := range []int{100000, 165536, 231072} {
+		offset, err := m.allocateUserNamespace()
+		TestExpectSuccess(t, err)
+		TestEqual(t, offset, want)
+	}
+
+	// A released range is reused before any later ones.
+	m.releaseUserNamespace(165536)
+	offset, err := m.allocateUserNamespace()
+	TestExpectSuccess(t, err)
+	TestEqual(t, offset, 165536)
+
+	// Reserved ranges, such as those of recovered containers, are skipped.
+	m.reserveUserNamespace(296608)
+	offset, err = m.allocateUserNamespace()
+	TestExpectSuccess(t, err)
+	TestEqual(t, offset, 362144)
+}
+
+func TestManager_AllocateUserNamespaceExhausted(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	// Only two ranges fit below the largest id which can be mapped.
+	size := 1000
+	last := int(lastNamespaceId())
+	m := newUserNamespaceManager(last-2*size+1, size)
+	for i := 0; i < 2; i++ {
+		_, err := m.allocateUserNamespace()
+		TestExpectSuccess(t, err)
+	}
+	if _, err := m.allocateUserNamespace(); err == nil {
+		Fatalf(t, "Expected an error once all the ranges are allocated")
+	}
+
+	// Releasing a range makes it available again.
+	m.releaseUserNamespace(last - size + 1)
+	offset, err := m.allocateUserNamespace()
+	TestExpectSuccess(t, err)
+	TestEqual(t, offset, last-size+1)
+}
+
+func TestManager_AllocateUserNamespaceUnconfigured(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	m := newUserNamespaceManager(0, 0)
+	if _, err := m.allocateUserNamespace(); err == nil {
+		Fatalf(t, "Expected an error when user namespaces are not configured")
+	}
+}
+
+func TestContainer_UserNamespaceMap(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	c := &Container{manager: newUserNamespaceManager(100000, 65536)}
+	TestEqual(t, c.usesUserNamespace(), false)
+
+	c.userNamespaceOffset = 165536
+	TestEqual(t, c.usesUserNamespace(), true)
+	TestEqual(t, c.userNamespaceMap(), "0 165536 65536\n")
+}
@@ -4,11 +4,15 @@ package container
 
 import (
 	"fmt"
+	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 	"syscall"
 
+	"github.com/apcera/kurma/stage3/client"
 	"github.com/apcera/util/proc"
 )
 
@@ -28,10 +32,161 @@ func (c *Container) stage3Path() string {
 	return filepath.Join(c.directory, "rootfs")
 }
 
+// storageID returns the identifier the app's root filesystem is known by to the
+// storage driver.
+func (c *Container) storageID(a *app) string {
+	return fmt.Sprintf("%s-%s", c.uuid, a.key())
+}
+
+// volumesPath returns the directory that the container's empty volumes are
+// created within.
+func (c *Container) volumesPath() string {
+	return filepath.Join(c.manager.volumeDirectory, c.ShortName())
+}
+
 func (c *Container) socketPath() string {
 	return filepath.Join(c.directory, "socket")
 }
 
+func (c *Container) statePath() string {
+	return filepath.Join(c.directory, stateFilename)
+}
+
+// exitReason generates a summary of how the processes within a container
+// terminated, for use when the container is marked as exited.
+func exitReason(statuses map[string]*client.ProcessStatus) string {
+	parts := make([]string, 0, len(statuses))
+	for name, status := range statuses {
+		parts = append(parts, fmt.Sprintf("%s %s", name, status))
+	}
+	sort.Strings(parts)
+	return fmt.Sprintf("all processes terminated: %s", strings.Join(parts, ", "))
+}
+
+// anyRunning returns whether any of the apps in the status map are still
+// running. Processes running app hooks are not included.
+func anyRunning(statuses map[string]*client.ProcessStatus) bool {
+	for name, status := range statuses {
+		if status.Running && !isHookProcess(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// signalNames maps the names of the signals which may be used to stop an app to
+// their values.
+var signalNames = map[string]syscall.Signal{
+	"SIGHUP":  syscall.SIGHUP,
+	"SIGINT":  syscall.SIGINT,
+	"SIGQUIT": syscall.SIGQUIT,
+	"SIGKILL": syscall.SIGKILL,
+	"SIGUSR1": syscall.SIGUSR1,
+	"SIGUSR2": syscall.SIGUSR2,
+	"SIGTERM": syscall.SIGTERM,
+}
+
+// parseSignal converts a signal name, with or without the "SIG" prefix, or a
+// signal number into the signal.
+func parseSignal(name string) (syscall.Signal, error) {
+	if n, err := strconv.Atoi(name); err == nil {
+		if n <= 0 || n > 64 {
+			return 0, fmt.Errorf("invalid signal number %d", n)
+		}
+		return syscall.Signal(n), nil
+	}
+	name = strings.ToUpper(name)
+	if !strings.HasPrefix(name, "SIG") {
+		name = "SIG" + name
+	}
+	if sig, ok := signalNames[name]; ok {
+		return sig, nil
+	}
+	return 0, fmt.Errorf("unrecognized signal %q", name)
+}
+
+// capabilityNames maps the names of the Linux capabilities to their values, as
+// used in the capabilities isolators.
+var capabilityNames = map[string]int{
+	"CAP_CHOWN":            0,
+	"CAP_DAC_OVERRIDE":     1,
+	"CAP_DAC_READ_SEARCH":  2,
+	"CAP_FOWNER":           3,
+	"CAP_FSETID":           4,
+	"CAP_KILL":             5,
+	"CAP_SETGID":           6,
+	"CAP_SETUID":           7,
+	"CAP_SETPCAP":          8,
+	"CAP_LINUX_IMMUTABLE":  9,
+	"CAP_NET_BIND_SERVICE": 10,
+	"CAP_NET_BROADCAST":    11,
+	"CAP_NET_ADMIN":        12,
+	"CAP_NET_RAW":          13,
+	"CAP_IPC_LOCK":         14,
+	"CAP_IPC_OWNER":        15,
+	"CAP_SYS_MODULE":       16,
+	"CAP_SYS_RAWIO":        17,
+	"CAP_SYS_CHROOT":       18,
+	"CAP_SYS_PTRACE":       19,
+	"CAP_SYS_PACCT":        20,
+	"CAP_SYS_ADMIN":        21,
+	"CAP_SYS_BOOT":         22,
+	"CAP_SYS_NICE":         23,
+	"CAP_SYS_RESOURCE":     24,
+	"CAP_SYS_TIME":         25,
+	"CAP_SYS_TTY_CONFIG":   26,
+	"CAP_MKNOD":            27,
+	"CAP_LEASE":            28,
+	"CAP_AUDIT_WRITE":      29,
+	"CAP_AUDIT_CONTROL":    30,
+	"CAP_SETFCAP":          31,
+	"CAP_MAC_OVERRIDE":     32,
+	"CAP_MAC_ADMIN":        33,
+	"CAP_SYSLOG":           34,
+	"CAP_WAKE_ALARM":       35,
+	"CAP_BLOCK_SUSPEND":    36,
+	"CAP_AUDIT_READ":       37,
+}
+
+// capLastCapPath is the file the kernel reports its highest supported
+// capability in.
+var capLastCapPath = "/proc/sys/kernel/cap_last_cap"
+
+// lastCapability returns the highest capability supported by the running
+// kernel. Older kernels don't know of every capability in capabilityNames, and
+// fail to drop those which they don't know of. If the kernel doesn't report it,
+// every known capability is assumed to be supported.
+func lastCapability() int {
+	last := 0
+	for _, c := range capabilityNames {
+		if c > last {
+			last = c
+		}
+	}
+	b, err := ioutil.ReadFile(capLastCapPath)
+	if err != nil {
+		return last
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(string(b)))
+	if err != nil || n > last {
+		return last
+	}
+	return n
+}
+
+// parseCapability converts a capability name, with or without the "CAP_"
+// prefix, into its value.
+func parseCapability(name string) (int, error) {
+	name = strings.ToUpper(name)
+	if !strings.HasPrefix(name, "CAP_") {
+		name = "CAP_" + name
+	}
+	if c, ok := capabilityNames[name]; ok {
+		return c, nil
+	}
+	return 0, fmt.Errorf("unrecognized capability %q", name)
+}
+
 func mkdirs(dirs []string, mode os.FileMode, existOk bool) error {
 	for i := range dirs {
 		// Make sure that this directory doesn't currently exist if existOk
@@ -101,12 +256,12 @@ func unmountDirectories(path string) error {
 }
 
 // ensureContainerPathExists ensures that the specified path within the
-// container exists. It will create any missing directories and walk the
-// filesystem to ensure any portions that are symlinks are resolved. It returns
-// the full host path to the directory.
-func (c *Container) ensureContainerPathExists(name string) (string, error) {
+// container's root filesystem at root exists. It will create any missing
+// directories and walk the filesystem to ensure any portions that are symlinks
+// are resolved. It returns the full host path to the directory.
+func (c *Container) ensureContainerPathExists(root, name string) (string, error) {
 	parts := strings.Split(name, string(os.PathSeparator))
-	resolvedPath := c.stage3Path()
+	resolvedPath := root
 	containerPath := ""
 
 	for _, p := range parts {
@@ -116,7 +271,7 @@ func (c *Container) ensureContainerPathExists(name string) (string, error) {
 		containerPath = filepath.Join(containerPath, p)
 
 		// resolve this segment
-		newResolvedPath, err := c.resolveSymlinkDir(containerPath)
+		newResolvedPath, err := c.resolveSymlinkDir(root, containerPath)
 		if err != nil {
 			if os.IsNotExist(err) {
 				// create it if it doesn't exist
@@ -124,6 +279,9 @@ func (c *Container) ensureContainerPathExists(name string) (string, error) {
 				if err := os.Mkdir(resolvedPath, os.FileMode(0755)); err != nil {
 					return "", err
 				}
+				if err := c.chownToNamespace(resolvedPath, 0, 0); err != nil {
+					return "", err
+				}
 				continue
 			}
 			return "", err
@@ -136,14 +294,20 @@ func (c *Container) ensureContainerPathExists(name string) (string, error) {
 	return resolvedPath, nil
 }
 
-// Resolves a given directory name relative to the container into a directory
-// name relative to the instance manager. This will attempt to follow symlinks
-// as best as possible, ensuring that the destination stays inside of the
-// container directory.
-func (c *Container) resolveSymlinkDir(name string) (string, error) {
+// Resolves a given directory name relative to the container's root filesystem
+// at root into a directory name relative to the instance manager. This will
+// attempt to follow symlinks as best as possible, ensuring that the destination
+// stays inside of the root filesystem.
+func (c *Container) resolveSymlinkDir(root, name string) (string, error) {
+	return resolveContainerPath(root, name, true)
+}
+
+// resolveContainerPath resolves the name within the root filesystem at root in
+// the same way as resolveSymlinkDir. When dirOnly is false, the name may also
+// resolve to a file through a symlink.
+func resolveContainerPath(root, name string, dirOnly bool) (string, error) {
 	// This is used to compare paths to ensure that they are exactly contained
-	// completely within s.RootDirectory()
-	root := c.stage3Path()
+	// completely within root
 	checkList := func(fn string) (string, bool) {
 		fnPath := filepath.Join(root, fn)
 		if len(fnPath) < len(root) {
@@ -174,7 +338,7 @@ func (c *Container) resolveSymlinkDir(name string) (string, error) {
 					return "", err
 				}
 				continue
-			} else if !fi.IsDir() {
+			} else if dirOnly && !fi.IsDir() {
 				return "", fmt.Errorf("Resolved path is not a directory: %s", newName)
 			} else {
 				return newName, nil
@@ -225,3 +389,67 @@ func (c *Container) resolveSymlinkDir(name string) (string, error) {
 
 	return filepath.Join(root, containerPath), nil
 }
+
+// userShell returns the login shell of the user, given by name or uid, from the
+// /etc/passwd file within the root filesystem. The fallback is returned when
+// the user has no shell listed.
+func userShell(rootfs, user, fallback string) string {
+	if user == "" {
+		user = "0"
+	}
+	path, err := resolveContainerPath(rootfs, "/etc/passwd", false)
+	if err != nil {
+		return fallback
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fallback
+	}
+
+	// lines are in the form of name:password:uid:gid:gecos:home:shell
+	for _, line := range strings.Split(string(b), "\n") {
+		fields := strings.Split(line, ":")
+		if len(fields) != 7 || (fields[0] != user && fields[2] != user) {
+			continue
+		}
+		if fields[6] == "" {
+			return fallback
+		}
+		return fields[6]
+	}
+	return fallback
+}
+
+// findExecutable checks that the command exists within the root filesystem and
+// is executable. Commands without a path are searched for within the given
+// PATH.
+func findExecutable(rootfs, command, path string) error {
+	isExecutable := func(p string) bool {
+		resolved, err := resolveContainerPath(rootfs, p, false)
+		if err != nil {
+			return false
+		}
+		fi, err := os.Stat(resolved)
+		return err == nil && fi.Mode().IsRegular() && fi.Mode()&0111 != 0
+	}
+
+	switch {
+	case filepath.IsAbs(command):
+		if !isExecutable(command) {
+			return fmt.Errorf("%q does not exist or is not executable within the container", command)
+		}
+		return nil
+
+	case strings.Contains(command, "/"):
+		// relative to the working directory, left for the stage2 to resolve
+		return nil
+
+	default:
+		for _, dir := range filepath.SplitList(path) {
+			if filepath.IsAbs(dir) && isExecutable(filepath.Join(dir, command)) {
+				return nil
+			}
+		}
+		return fmt.Errorf("%q was not found in the container's PATH", command)
+	}
+}
@@ -0,0 +1,79 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package container
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	. "github.com/apcera/util/testtool"
+)
+
+func TestParseSignal(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	tests := []struct {
+		name string
+		want syscall.Signal
+	}{
+		{"SIGTERM", syscall.SIGTERM},
+		{"TERM", syscall.SIGTERM},
+		{"sigint", syscall.SIGINT},
+		{"quit", syscall.SIGQUIT},
+		{"SIGUSR2", syscall.SIGUSR2},
+		{"9", syscall.SIGKILL},
+		{"64", syscall.Signal(64)},
+	}
+	for _, test := range tests {
+		sig, err := parseSignal(test.name)
+		TestExpectSuccess(t, err)
+		TestEqual(t, sig, test.want)
+	}
+
+	for _, name := range []string{"", "0", "-1", "65", "SIGFOO", "SIGSEGV", "1.5"} {
+		if _, err := parseSignal(name); err == nil {
+			Fatalf(t, "Expected an error parsing the signal %q", name)
+		}
+	}
+}
+
+func TestFindExecutableAndUserShell(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	// Build a root filesystem where /bin is an absolute symlink, which must
+	// resolve within the root filesystem rather than the host's.
+	rootfs := TempDir(t)
+	for _, dir := range []string{"usr/bin", "etc"} {
+		TestExpectSuccess(t, os.MkdirAll(filepath.Join(rootfs, dir), os.FileMode(0755)))
+	}
+	TestExpectSuccess(t, os.Symlink("/usr/bin", filepath.Join(rootfs, "bin")))
+	TestExpectSuccess(t, ioutil.WriteFile(filepath.Join(rootfs, "usr/bin/tool"), nil, os.FileMode(0755)))
+	TestExpectSuccess(t, ioutil.WriteFile(filepath.Join(rootfs, "usr/bin/data"), nil, os.FileMode(0644)))
+	TestExpectSuccess(t, os.Symlink("/usr/bin/tool", filepath.Join(rootfs, "usr/bin/link")))
+	TestExpectSuccess(t, ioutil.WriteFile(filepath.Join(rootfs, "etc/passwd"), []byte(
+		"root:x:0:0:root:/root:/bin/bash\nnobody:x:99:99::/:\n"), os.FileMode(0644)))
+
+	TestExpectSuccess(t, findExecutable(rootfs, "/bin/tool", ""))
+	TestExpectSuccess(t, findExecutable(rootfs, "/bin/link", ""))
+	TestExpectSuccess(t, findExecutable(rootfs, "tool", "/sbin:/bin"))
+	TestExpectSuccess(t, findExecutable(rootfs, "./anything", ""))
+	for _, command := range []string{"/bin/data", "/bin/missing", "/usr/bin"} {
+		if err := findExecutable(rootfs, command, ""); err == nil {
+			Fatalf(t, "Expected %q not to be executable", command)
+		}
+	}
+	if err := findExecutable(rootfs, "tool", "/sbin:relative"); err == nil {
+		Fatalf(t, "Expected the command not to be found outside of the PATH")
+	}
+
+	TestEqual(t, userShell(rootfs, "", "/bin/sh"), "/bin/bash")
+	TestEqual(t, userShell(rootfs, "root", "/bin/sh"), "/bin/bash")
+	TestEqual(t, userShell(rootfs, "99", "/bin/sh"), "/bin/sh")
+	TestEqual(t, userShell(rootfs, "missing", "/bin/sh"), "/bin/sh")
+	TestEqual(t, userShell(TempDir(t), "root", "/bin/sh"), "/bin/sh")
+}
@@ -0,0 +1,150 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package image
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/appc/spec/schema"
+	"golang.org/x/crypto/openpgp"
+)
+
+const (
+	// rootKeysDirectory is the directory within the trust store holding keys
+	// which are trusted for all images.
+	rootKeysDirectory = "root.d"
+
+	// prefixKeysDirectory is the directory within the trust store holding keys
+	// which are trusted for a prefix of image names. The path of a key relative
+	// to it is the prefix, such as "prefix.d/example.com/app/<fingerprint>".
+	prefixKeysDirectory = "prefix.d"
+)
+
+// Keystore holds the public keys which are trusted to sign images. Following
+// the appc trust model, each key is trusted for images whose names are within
+// a given prefix, where a blank prefix trusts the key for all images.
+type Keystore struct {
+	keys     map[string]openpgp.EntityList
+	keysLock sync.RWMutex
+}
+
+// NewKeystore creates a new Keystore and loads the keys from the trust store
+// directory, if one is given.
+func NewKeystore(directory string) (*Keystore, error) {
+	ks := &Keystore{
+		keys: make(map[string]openpgp.EntityList),
+	}
+	if directory == "" {
+		return ks, nil
+	}
+
+	if err := ks.loadDirectory(filepath.Join(directory, rootKeysDirectory), false); err != nil {
+		return nil, err
+	}
+	if err := ks.loadDirectory(filepath.Join(directory, prefixKeysDirectory), true); err != nil {
+		return nil, err
+	}
+	return ks, nil
+}
+
+// loadDirectory adds each of the armored keys within the directory. When
+// prefixed is true, the keys are trusted for the prefix given by their path
+// within the directory. Otherwise, they are trusted for all images.
+func (ks *Keystore) loadDirectory(directory string, prefixed bool) error {
+	return filepath.Walk(directory, func(p string, fi os.FileInfo, err error) error {
+		if os.IsNotExist(err) && p == directory {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if fi.IsDir() {
+			return nil
+		}
+
+		prefix := ""
+		if prefixed {
+			rel, err := filepath.Rel(directory, filepath.Dir(p))
+			if err != nil {
+				return err
+			}
+			if rel == "." {
+				return fmt.Errorf("key %q must be within a prefix directory", p)
+			}
+			prefix = filepath.ToSlash(rel)
+		}
+
+		f, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		if err := ks.AddKey(prefix, f); err != nil {
+			return fmt.Errorf("failed to load key %q: %v", p, err)
+		}
+		return nil
+	})
+}
+
+// AddKey trusts the armored public keys from the reader to sign images within
+// the given prefix. A blank prefix trusts the keys for all images.
+func (ks *Keystore) AddKey(prefix string, r io.Reader) error {
+	entities, err := openpgp.ReadArmoredKeyRing(r)
+	if err != nil {
+		return err
+	}
+	prefix = strings.Trim(prefix, "/")
+
+	ks.keysLock.Lock()
+	defer ks.keysLock.Unlock()
+	ks.keys[prefix] = append(ks.keys[prefix], entities...)
+	return nil
+}
+
+// keyring returns the keys trusted for images with the given name.
+func (ks *Keystore) keyring(name string) openpgp.EntityList {
+	ks.keysLock.RLock()
+	defer ks.keysLock.RUnlock()
+
+	var keyring openpgp.EntityList
+	for prefix, entities := range ks.keys {
+		if prefix == "" || name == prefix || strings.HasPrefix(name, prefix+"/") {
+			keyring = append(keyring, entities...)
+		}
+	}
+	return keyring
+}
+
+// VerifyImage checks the armored detached signature of the ACI against the keys
+// trusted for the name within its image manifest. It returns the manifest of
+// the image if the signature is valid. The reader is rewound when done.
+func (ks *Keystore) VerifyImage(r io.ReadSeeker, signature []byte) (*schema.ImageManifest, error) {
+	if len(signature) == 0 {
+		return nil, fmt.Errorf("the image is not signed")
+	}
+
+	manifest, err := findManifest(r)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := r.Seek(0, 0); err != nil {
+		return nil, err
+	}
+
+	keyring := ks.keyring(manifest.Name.String())
+	if len(keyring) == 0 {
+		return nil, fmt.Errorf("no keys are trusted for images named %q", manifest.Name)
+	}
+	if _, err := openpgp.CheckArmoredDetachedSignature(keyring, r, bytes.NewReader(signature)); err != nil {
+		return nil, fmt.Errorf("invalid signature for image %q: %v", manifest.Name, err)
+	}
+	if _, err := r.Seek(0, 0); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
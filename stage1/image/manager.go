@@ -0,0 +1,387 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package image
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/apcera/logray"
+	"github.com/apcera/util/hashutil"
+	"github.com/apcera/util/tarhelper"
+	"github.com/appc/spec/schema"
+	"github.com/appc/spec/schema/types"
+)
+
+const (
+	// imageFilename is the name of the ACI file within an image's directory.
+	imageFilename = "image.aci"
+
+	// manifestFilename is the name of the file within an image's directory that
+	// holds its image manifest.
+	manifestFilename = "manifest"
+
+	// signatureFilename is the name of the file within an image's directory
+	// that holds the image's detached signature, if it was signed.
+	signatureFilename = "image.aci.asc"
+)
+
+// Options contains settings that are used by the Image Manager.
+type Options struct {
+	Directory string
+
+	// Keystore holds the keys trusted to sign images. When it is set, only
+	// images with a valid signature may be stored.
+	Keystore *Keystore
+
+	// Retriever is used to retrieve remote images. If it is not set, images
+	// may be retrieved from anywhere over secure connections.
+	Retriever *Retriever
+}
+
+// Manager handles the images stored on the host. Images are stored by their
+// image ID, the sha512 of the uncompressed ACI, so each image is only ever
+// stored once.
+type Manager struct {
+	Log *logray.Logger
+
+	directory  string
+	keystore   *Keystore
+	retriever  *Retriever
+	images     map[string]*schema.ImageManifest
+	imagesLock sync.RWMutex
+}
+
+// NewManager creates a new Manager with the provided options. Any images which
+// were previously stored in its directory will be loaded.
+func NewManager(opts *Options) (*Manager, error) {
+	if opts.Directory == "" {
+		return nil, fmt.Errorf("an image directory must be specified")
+	}
+	if err := os.MkdirAll(opts.Directory, os.FileMode(0755)); err != nil {
+		return nil, err
+	}
+
+	retriever := opts.Retriever
+	if retriever == nil {
+		retriever = &Retriever{}
+	}
+
+	m := &Manager{
+		Log:       logray.New(),
+		directory: opts.Directory,
+		keystore:  opts.Keystore,
+		retriever: retriever,
+		images:    make(map[string]*schema.ImageManifest),
+	}
+
+	if err := m.loadImages(); err != nil {
+		return nil, fmt.Errorf("failed to load existing images: %v", err)
+	}
+	return m, nil
+}
+
+// loadImages walks the image directory and indexes the manifests of any images
+// stored within it.
+func (manager *Manager) loadImages() error {
+	fis, err := ioutil.ReadDir(manager.directory)
+	if err != nil {
+		return err
+	}
+
+	for _, fi := range fis {
+		if !fi.IsDir() || !strings.HasPrefix(fi.Name(), "sha512-") {
+			continue
+		}
+		hash := fi.Name()
+
+		b, err := ioutil.ReadFile(filepath.Join(manager.directory, hash, manifestFilename))
+		if err != nil {
+			manager.Log.Warnf("Unable to load image %s: %v", hash, err)
+			continue
+		}
+		var manifest *schema.ImageManifest
+		if err := json.Unmarshal(b, &manifest); err != nil {
+			manager.Log.Warnf("Unable to parse the manifest for image %s: %v", hash, err)
+			continue
+		}
+
+		// images may have been stored before signatures were required
+		if manager.keystore != nil {
+			if err := manager.verifyStoredImage(hash); err != nil {
+				manager.Log.Warnf("Skipping image %s: %v", hash, err)
+				continue
+			}
+		}
+		manager.images[hash] = manifest
+	}
+	return nil
+}
+
+// verifyStoredImage checks the stored signature of the image with the given
+// image ID.
+func (manager *Manager) verifyStoredImage(hash string) error {
+	signature, err := ioutil.ReadFile(filepath.Join(manager.imagePath(hash), signatureFilename))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	f, err := os.Open(filepath.Join(manager.imagePath(hash), imageFilename))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = manager.keystore.VerifyImage(f, signature)
+	return err
+}
+
+// VerifiesSignatures returns whether images must be signed to be stored.
+func (manager *Manager) VerifiesSignatures() bool {
+	return manager.keystore != nil
+}
+
+// CreateImage stores the ACI from the reader along with its armored detached
+// signature, which may be nil if signatures are not required. It returns the
+// image ID and the image's manifest. If the image is already stored, the
+// existing copy is kept.
+func (manager *Manager) CreateImage(r io.Reader, signature []byte) (string, *schema.ImageManifest, error) {
+	// write the image to a temporary file while calculating its hash
+	f, err := ioutil.TempFile(manager.directory, "upload")
+	if err != nil {
+		return "", nil, err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	if _, err := io.Copy(f, r); err != nil {
+		return "", nil, fmt.Errorf("failed to receive image: %v", err)
+	}
+	if _, err := f.Seek(0, 0); err != nil {
+		return "", nil, err
+	}
+	hash, err := ImageID(f)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to read image: %v", err)
+	}
+
+	// verify the signature, even if the image is already stored
+	if manager.keystore != nil {
+		if _, err := f.Seek(0, 0); err != nil {
+			return "", nil, err
+		}
+		if _, err := manager.keystore.VerifyImage(f, signature); err != nil {
+			return "", nil, err
+		}
+	}
+
+	// skip storing the image if it is already present
+	if manifest := manager.GetImage(hash); manifest != nil {
+		manager.Log.Debugf("Image %s is already stored", hash)
+		return hash, manifest, nil
+	}
+
+	// locate the manifest within the image
+	if _, err := f.Seek(0, 0); err != nil {
+		return "", nil, err
+	}
+	manifest, err := findManifest(f)
+	if err != nil {
+		return "", nil, err
+	}
+	b, err := json.Marshal(manifest)
+	if err != nil {
+		return "", nil, err
+	}
+
+	// move the image into its own directory along with its manifest
+	imagePath := manager.imagePath(hash)
+	if err := os.MkdirAll(imagePath, os.FileMode(0755)); err != nil {
+		return "", nil, err
+	}
+	if err := ioutil.WriteFile(filepath.Join(imagePath, manifestFilename), b, os.FileMode(0644)); err != nil {
+		os.RemoveAll(imagePath)
+		return "", nil, err
+	}
+	if len(signature) > 0 {
+		if err := ioutil.WriteFile(filepath.Join(imagePath, signatureFilename), signature, os.FileMode(0644)); err != nil {
+			os.RemoveAll(imagePath)
+			return "", nil, err
+		}
+	}
+	if err := os.Rename(f.Name(), filepath.Join(imagePath, imageFilename)); err != nil {
+		os.RemoveAll(imagePath)
+		return "", nil, err
+	}
+
+	manager.imagesLock.Lock()
+	manager.images[hash] = manifest
+	manager.imagesLock.Unlock()
+	manager.Log.Infof("Stored image %s as %s", manifest.Name, hash)
+	return hash, manifest, nil
+}
+
+// RetrieveImage retrieves the image at the given image URI, along with its
+// signature when signatures are required, and stores it. It returns the image
+// ID and the image's manifest.
+func (manager *Manager) RetrieveImage(imageUri string) (string, *schema.ImageManifest, error) {
+	f, err := manager.retriever.RetrieveImage(imageUri)
+	if err != nil {
+		return "", nil, err
+	}
+	defer f.Close()
+
+	var signature []byte
+	if manager.keystore != nil {
+		signature, err = manager.retriever.RetrieveSignature(imageUri)
+		if err != nil {
+			return "", nil, err
+		}
+	}
+	return manager.CreateImage(f, signature)
+}
+
+// GetImage returns the manifest for the image with the given image ID, or nil
+// if the image is not stored.
+func (manager *Manager) GetImage(hash string) *schema.ImageManifest {
+	manager.imagesLock.RLock()
+	defer manager.imagesLock.RUnlock()
+	return manager.images[hash]
+}
+
+// FindImage locates a stored image by its name and labels. Each of the given
+// labels must match the image's labels, while the image may have additional
+// labels. It returns the image ID and manifest of the matching image, or a
+// blank ID if no image matches.
+func (manager *Manager) FindImage(name string, labels types.Labels) (string, *schema.ImageManifest) {
+	manager.imagesLock.RLock()
+	defer manager.imagesLock.RUnlock()
+
+	// check the images in order of their hash so the result is consistent
+	hashes := make([]string, 0, len(manager.images))
+	for hash := range manager.images {
+		hashes = append(hashes, hash)
+	}
+	sort.Strings(hashes)
+
+	for _, hash := range hashes {
+		manifest := manager.images[hash]
+		if manifest.Name.String() != name {
+			continue
+		}
+		if matchLabels(manifest.Labels, labels) {
+			return hash, manifest
+		}
+	}
+	return "", nil
+}
+
+// ListImages returns the manifests of all of the stored images, keyed by their
+// image ID.
+func (manager *Manager) ListImages() map[string]*schema.ImageManifest {
+	manager.imagesLock.RLock()
+	defer manager.imagesLock.RUnlock()
+	images := make(map[string]*schema.ImageManifest, len(manager.images))
+	for hash, manifest := range manager.images {
+		images[hash] = manifest
+	}
+	return images
+}
+
+// OpenImage opens the ACI file of the image with the given image ID.
+func (manager *Manager) OpenImage(hash string) (*os.File, error) {
+	if manager.GetImage(hash) == nil {
+		return nil, fmt.Errorf("image %s is not stored", hash)
+	}
+	return os.Open(filepath.Join(manager.imagePath(hash), imageFilename))
+}
+
+// DeleteImage removes the image with the given image ID from the store.
+func (manager *Manager) DeleteImage(hash string) error {
+	manager.imagesLock.Lock()
+	defer manager.imagesLock.Unlock()
+	if _, exists := manager.images[hash]; !exists {
+		return fmt.Errorf("image %s is not stored", hash)
+	}
+	if err := os.RemoveAll(manager.imagePath(hash)); err != nil {
+		return err
+	}
+	delete(manager.images, hash)
+	return nil
+}
+
+// imagePath returns the directory the image with the given image ID is stored
+// within.
+func (manager *Manager) imagePath(hash string) string {
+	return filepath.Join(manager.directory, hash)
+}
+
+// matchLabels returns whether each of the wanted labels is present with the
+// same value in labels.
+func matchLabels(labels, wanted types.Labels) bool {
+	for _, w := range wanted {
+		value, ok := labels.Get(w.Name.String())
+		if !ok || value != w.Value {
+			return false
+		}
+	}
+	return true
+}
+
+// ImageID returns the image ID of the ACI read from r. As defined by the appc
+// spec, this is the sha512 of the uncompressed tar, so the same image has the
+// same ID however it is compressed.
+func ImageID(r io.Reader) (string, error) {
+	br := bufio.NewReader(r)
+	var ur io.Reader = br
+	for _, d := range []tarhelper.Decompressor{&tarhelper.GzipDecompressor{}, &tarhelper.Bzip2Decompressor{}} {
+		if !d.Detect(br) {
+			continue
+		}
+		var err error
+		if ur, err = d.NewReader(br); err != nil {
+			return "", err
+		}
+		break
+	}
+
+	sr := hashutil.NewSha512(ur)
+	if _, err := io.Copy(ioutil.Discard, sr); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("sha512-%s", sr.Sha512()), nil
+}
+
+// findManifest locates and parses the image manifest within the ACI.
+func findManifest(r io.Reader) (*schema.ImageManifest, error) {
+	arch, err := tarhelper.DetectArchiveCompression(r)
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		header, err := arch.Next()
+		if err == io.EOF {
+			return nil, fmt.Errorf("failed to locate manifest file")
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if filepath.Clean(header.Name) != "manifest" {
+			continue
+		}
+
+		var manifest *schema.ImageManifest
+		if err := json.NewDecoder(arch).Decode(&manifest); err != nil {
+			return nil, err
+		}
+		return manifest, nil
+	}
+}
@@ -0,0 +1,185 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package image
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha512"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/armor"
+
+	. "github.com/apcera/util/testtool"
+)
+
+const testManifest = `{"acKind":"ImageManifest","acVersion":"0.7.1","name":"example.com/test"}`
+
+// buildACI returns an image with the test manifest and an empty root
+// filesystem.
+func buildACI(t *testing.T) []byte {
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gw)
+	TestExpectSuccess(t, tw.WriteHeader(&tar.Header{
+		Name: "manifest", Mode: 0644, Size: int64(len(testManifest)), ModTime: time.Now(),
+	}))
+	_, err := tw.Write([]byte(testManifest))
+	TestExpectSuccess(t, err)
+	TestExpectSuccess(t, tw.WriteHeader(&tar.Header{
+		Name: "rootfs/", Mode: 0755, Typeflag: tar.TypeDir, ModTime: time.Now(),
+	}))
+	TestExpectSuccess(t, tw.Close())
+	TestExpectSuccess(t, gw.Close())
+	return buf.Bytes()
+}
+
+// newSigner returns a new key along with a keystore which trusts it.
+func newSigner(t *testing.T) (*openpgp.Entity, *Keystore) {
+	entity, err := openpgp.NewEntity("test", "", "test@example.com", nil)
+	TestExpectSuccess(t, err)
+	ks, err := NewKeystore("")
+	TestExpectSuccess(t, err)
+	TestExpectSuccess(t, ks.AddKey("example.com", bytes.NewReader(armoredPublicKey(t, entity))))
+	return entity, ks
+}
+
+func armoredPublicKey(t *testing.T, entity *openpgp.Entity) []byte {
+	var buf bytes.Buffer
+	w, err := armor.Encode(&buf, openpgp.PublicKeyType, nil)
+	TestExpectSuccess(t, err)
+	TestExpectSuccess(t, entity.Serialize(w))
+	TestExpectSuccess(t, w.Close())
+	return buf.Bytes()
+}
+
+func sign(t *testing.T, entity *openpgp.Entity, aci []byte) []byte {
+	var buf bytes.Buffer
+	TestExpectSuccess(t, openpgp.ArmoredDetachSign(&buf, entity, bytes.NewReader(aci), nil))
+	return buf.Bytes()
+}
+
+func TestManager_CreateImageVerifiesSignature(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	entity, ks := newSigner(t)
+	other, _ := newSigner(t)
+	m, err := NewManager(&Options{Directory: TempDir(t), Keystore: ks})
+	TestExpectSuccess(t, err)
+	aci := buildACI(t)
+
+	// Unsigned images, and those signed by an untrusted key, are rejected.
+	for _, signature := range [][]byte{nil, []byte("garbage"), sign(t, other, aci)} {
+		if _, _, err := m.CreateImage(bytes.NewReader(aci), signature); err == nil {
+			Fatalf(t, "Expected an image with signature %q to be rejected", signature)
+		}
+	}
+	TestEqual(t, len(m.ListImages()), 0)
+
+	hash, _, err := m.CreateImage(bytes.NewReader(aci), sign(t, entity, aci))
+	TestExpectSuccess(t, err)
+	if m.GetImage(hash) == nil {
+		Fatalf(t, "Expected the signed image to be stored")
+	}
+}
+
+func TestManager_RetrieveImageVerifiesSignature(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	entity, ks := newSigner(t)
+	m, err := NewManager(&Options{Directory: TempDir(t), Keystore: ks})
+	TestExpectSuccess(t, err)
+	aci := buildACI(t)
+
+	path := filepath.Join(TempDir(t), "test.aci")
+	TestExpectSuccess(t, ioutil.WriteFile(path, aci, os.FileMode(0644)))
+	uri := "file://" + path
+
+	// The signature is required to be next to the image.
+	if _, _, err := m.RetrieveImage(uri); err == nil {
+		Fatalf(t, "Expected an image without a signature to be rejected")
+	}
+	TestExpectSuccess(t, ioutil.WriteFile(path+".asc", []byte("garbage"), os.FileMode(0644)))
+	if _, _, err := m.RetrieveImage(uri); err == nil {
+		Fatalf(t, "Expected an image with an invalid signature to be rejected")
+	}
+	TestEqual(t, len(m.ListImages()), 0)
+
+	TestExpectSuccess(t, ioutil.WriteFile(path+".asc", sign(t, entity, aci), os.FileMode(0644)))
+	hash, _, err := m.RetrieveImage(uri)
+	TestExpectSuccess(t, err)
+	if m.GetImage(hash) == nil {
+		Fatalf(t, "Expected the signed image to be stored")
+	}
+}
+
+func TestManager_LoadImagesVerifiesSignature(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	// Store an image while signatures aren't required.
+	dir := TempDir(t)
+	m, err := NewManager(&Options{Directory: dir})
+	TestExpectSuccess(t, err)
+	hash, _, err := m.CreateImage(bytes.NewReader(buildACI(t)), nil)
+	TestExpectSuccess(t, err)
+
+	// Once signatures are required, the unsigned image isn't loaded.
+	_, ks := newSigner(t)
+	m, err = NewManager(&Options{Directory: dir, Keystore: ks})
+	TestExpectSuccess(t, err)
+	if m.GetImage(hash) != nil {
+		Fatalf(t, "Expected the unsigned image to be skipped")
+	}
+}
+
+func TestRetriever_Insecure(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	r := &Retriever{}
+	if _, err := r.resolve("http://example.com/test.aci", false); err == nil {
+		Fatalf(t, "Expected an HTTP URL to be rejected")
+	}
+	_, err := r.resolve("https://example.com/test.aci", false)
+	TestExpectSuccess(t, err)
+
+	r.Insecure = true
+	_, err = r.resolve("http://example.com/test.aci", false)
+	TestExpectSuccess(t, err)
+}
+
+func TestImageID(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	// The image ID is the sha512 of the uncompressed tar, so it is the same
+	// whether or not the image is compressed.
+	aci := buildACI(t)
+	gr, err := gzip.NewReader(bytes.NewReader(aci))
+	TestExpectSuccess(t, err)
+	tarball, err := ioutil.ReadAll(gr)
+	TestExpectSuccess(t, err)
+	want := fmt.Sprintf("sha512-%x", sha512.Sum512(tarball))
+
+	for _, b := range [][]byte{aci, tarball} {
+		hash, err := ImageID(bytes.NewReader(b))
+		TestExpectSuccess(t, err)
+		TestEqual(t, hash, want)
+	}
+
+	m, err := NewManager(&Options{Directory: TempDir(t)})
+	TestExpectSuccess(t, err)
+	hash, _, err := m.CreateImage(bytes.NewReader(aci), nil)
+	TestExpectSuccess(t, err)
+	TestEqual(t, hash, want)
+}
@@ -0,0 +1,81 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package image
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Policy restricts where images may be retrieved from. URL patterns match the
+// whole URL, with "*" matching any sequence of characters. Name prefixes match
+// images referenced by their appc discovery name, either exactly or as a parent
+// path. Denials take precedence, and when any allowances are given, an image
+// must match one of them.
+type Policy struct {
+	AllowedURLs  []string
+	DeniedURLs   []string
+	AllowedNames []string
+	DeniedNames  []string
+}
+
+// CheckURL returns an error if the policy does not permit retrieving images
+// from the URL.
+func (p *Policy) CheckURL(uri string) error {
+	if p == nil {
+		return nil
+	}
+	for _, pattern := range p.DeniedURLs {
+		if matchPattern(pattern, uri) {
+			return fmt.Errorf("image URL %q is denied by the pattern %q", uri, pattern)
+		}
+	}
+	if len(p.AllowedURLs) == 0 {
+		return nil
+	}
+	for _, pattern := range p.AllowedURLs {
+		if matchPattern(pattern, uri) {
+			return nil
+		}
+	}
+	return fmt.Errorf("image URL %q does not match any of the allowed patterns", uri)
+}
+
+// CheckName returns an error if the policy does not permit retrieving images
+// with the discovery name.
+func (p *Policy) CheckName(name string) error {
+	if p == nil {
+		return nil
+	}
+	for _, prefix := range p.DeniedNames {
+		if matchPrefix(prefix, name) {
+			return fmt.Errorf("image name %q is denied by the prefix %q", name, prefix)
+		}
+	}
+	if len(p.AllowedNames) == 0 {
+		return nil
+	}
+	for _, prefix := range p.AllowedNames {
+		if matchPrefix(prefix, name) {
+			return nil
+		}
+	}
+	return fmt.Errorf("image name %q does not match any of the allowed prefixes", name)
+}
+
+// matchPattern returns whether the string matches the pattern, where "*"
+// matches any sequence of characters.
+func matchPattern(pattern, s string) bool {
+	parts := strings.Split(pattern, "*")
+	for i := range parts {
+		parts[i] = regexp.QuoteMeta(parts[i])
+	}
+	return regexp.MustCompile("^" + strings.Join(parts, ".*") + "$").MatchString(s)
+}
+
+// matchPrefix returns whether the name is the prefix or is within it.
+func matchPrefix(prefix, name string) bool {
+	prefix = strings.TrimSuffix(prefix, "/")
+	return prefix == "" || name == prefix || strings.HasPrefix(name, prefix+"/")
+}
@@ -0,0 +1,105 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package image
+
+import (
+	"testing"
+
+	. "github.com/apcera/util/testtool"
+)
+
+func TestMatchPattern(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	tests := []struct {
+		pattern string
+		s       string
+		want    bool
+	}{
+		{"https://example.com/image.aci", "https://example.com/image.aci", true},
+		{"https://example.com/image.aci", "https://example.com/image.aci.asc", false},
+		{"https://example.com/*", "https://example.com/images/image.aci", true},
+		{"https://example.com/*", "https://example.com.evil.com/image.aci", false},
+		{"https://*.example.com/*", "https://images.example.com/image.aci", true},
+		{"https://*.example.com/*", "https://example.com/image.aci", false},
+		{"*", "file:///image.aci", true},
+		// Everything besides "*" is matched literally.
+		{"https://example.com/image.aci", "https://exampleXcom/image.aci", false},
+		{"https://example.com/?.aci", "https://example.com/a.aci", false},
+		{"https://example.com/?.aci", "https://example.com/?.aci", true},
+	}
+	for _, test := range tests {
+		if got := matchPattern(test.pattern, test.s); got != test.want {
+			Fatalf(t, "matchPattern(%q, %q) = %v, expected %v", test.pattern, test.s, got, test.want)
+		}
+	}
+}
+
+func TestPolicy_CheckURL(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	tests := []struct {
+		policy *Policy
+		uri    string
+		want   bool
+	}{
+		// Everything is permitted without a policy, or with an empty one.
+		{nil, "https://example.com/image.aci", true},
+		{&Policy{}, "https://example.com/image.aci", true},
+		// Once allowances are given, a URL must match one of them.
+		{&Policy{AllowedURLs: []string{"https://example.com/*"}}, "https://example.com/image.aci", true},
+		{&Policy{AllowedURLs: []string{"https://example.com/*"}}, "https://other.com/image.aci", false},
+		// Denials take precedence.
+		{&Policy{DeniedURLs: []string{"https://example.com/private/*"}}, "https://example.com/private/image.aci", false},
+		{&Policy{DeniedURLs: []string{"https://example.com/private/*"}}, "https://example.com/image.aci", true},
+		{&Policy{
+			AllowedURLs: []string{"https://example.com/*"},
+			DeniedURLs:  []string{"https://example.com/private/*"},
+		}, "https://example.com/private/image.aci", false},
+	}
+	for i, test := range tests {
+		err := test.policy.CheckURL(test.uri)
+		if (err == nil) != test.want {
+			Fatalf(t, "Test %d: CheckURL(%q) returned %v, expected permitted to be %v", i, test.uri, err, test.want)
+		}
+	}
+}
+
+func TestPolicy_CheckName(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	tests := []struct {
+		policy *Policy
+		name   string
+		want   bool
+	}{
+		{nil, "example.com/app", true},
+		{&Policy{}, "example.com/app", true},
+		// Prefixes match the name exactly or as a parent path.
+		{&Policy{AllowedNames: []string{"example.com"}}, "example.com", true},
+		{&Policy{AllowedNames: []string{"example.com"}}, "example.com/app", true},
+		{&Policy{AllowedNames: []string{"example.com/"}}, "example.com/app", true},
+		{&Policy{AllowedNames: []string{"example.com"}}, "example.com.evil.com/app", false},
+		{&Policy{AllowedNames: []string{"example.com/app"}}, "example.com/application", false},
+		// An empty prefix matches every name.
+		{&Policy{DeniedNames: []string{""}}, "example.com/app", false},
+		// Denials take precedence.
+		{&Policy{
+			AllowedNames: []string{"example.com"},
+			DeniedNames:  []string{"example.com/private"},
+		}, "example.com/private/app", false},
+		{&Policy{
+			AllowedNames: []string{"example.com"},
+			DeniedNames:  []string{"example.com/private"},
+		}, "example.com/public/app", true},
+	}
+	for i, test := range tests {
+		err := test.policy.CheckName(test.name)
+		if (err == nil) != test.want {
+			Fatalf(t, "Test %d: CheckName(%q) returned %v, expected permitted to be %v", i, test.name, err, test.want)
+		}
+	}
+}
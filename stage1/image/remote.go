@@ -0,0 +1,215 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package image
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+
+	"github.com/apcera/util/aciremote"
+	"github.com/appc/spec/discovery"
+)
+
+// Retriever retrieves remote images and their signatures, enforcing its policy
+// on each of the image names and URLs used. Insecure permits plain HTTP and
+// invalid certificates when retrieving images and performing discovery.
+type Retriever struct {
+	Policy   *Policy
+	Insecure bool
+}
+
+// RetrieveImage retrieves the image at the given image URI. Images referenced
+// by name are located through the App Container Image Discovery specification.
+func (r *Retriever) RetrieveImage(imageUri string) (aciremote.ReaderCloserSeeker, error) {
+	urls, err := r.resolve(imageUri, false)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, u := range urls {
+		var f aciremote.ReaderCloserSeeker
+		f, err = r.retrieveImageURL(u)
+		if err != nil {
+			continue
+		}
+		return f, nil
+	}
+	return nil, fmt.Errorf("failed to retrieve image %q: %v", imageUri, err)
+}
+
+// RetrieveSignature retrieves the detached signature for the image at the given
+// image URI. The signature is expected to be next to the image with an ".asc"
+// extension, or located through discovery when the image is referenced by
+// name.
+func (r *Retriever) RetrieveSignature(imageUri string) ([]byte, error) {
+	urls, err := r.resolve(imageUri, true)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, u := range urls {
+		var b []byte
+		b, err = r.retrieveURL(u)
+		if err != nil {
+			continue
+		}
+		return b, nil
+	}
+	return nil, fmt.Errorf("failed to retrieve signature for %q: %v", imageUri, err)
+}
+
+// resolve returns the URLs to try for the image URI, or for its signature,
+// after checking them against the policy.
+func (r *Retriever) resolve(imageUri string, signature bool) ([]string, error) {
+	u, err := url.Parse(imageUri)
+	if err != nil {
+		return nil, err
+	}
+
+	if u.Scheme != "" {
+		if signature {
+			imageUri += ".asc"
+		}
+		if err := r.checkURL(imageUri); err != nil {
+			return nil, err
+		}
+		return []string{imageUri}, nil
+	}
+
+	app, err := discovery.NewAppFromString(imageUri)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.Policy.CheckName(app.Name.String()); err != nil {
+		return nil, err
+	}
+
+	endpoints, _, err := discovery.DiscoverEndpoints(*app, r.Insecure)
+	if err != nil {
+		return nil, err
+	}
+
+	var urls []string
+	var policyErr error
+	for _, ep := range endpoints.ACIEndpoints {
+		uri := ep.ACI
+		if signature {
+			uri = ep.ASC
+		}
+		if err := r.checkURL(uri); err != nil {
+			policyErr = err
+			continue
+		}
+		urls = append(urls, uri)
+	}
+	if len(urls) == 0 {
+		if policyErr != nil {
+			return nil, fmt.Errorf("no discovered endpoints for %q are permitted: %v", imageUri, policyErr)
+		}
+		return nil, fmt.Errorf("failed to discover an image for %q", imageUri)
+	}
+	return urls, nil
+}
+
+// checkURL returns an error if the URL may not be used, either due to the
+// policy or because it is insecure.
+func (r *Retriever) checkURL(uri string) error {
+	if u, err := url.Parse(uri); err == nil && u.Scheme == "http" && !r.Insecure {
+		return fmt.Errorf("image URL %q is insecure", uri)
+	}
+	return r.Policy.CheckURL(uri)
+}
+
+// client returns the HTTP client used to retrieve images and signatures. Each
+// redirect is checked the same as the initial URL, so that a permitted URL
+// can't redirect to one which isn't.
+func (r *Retriever) client() *http.Client {
+	transport := &http.Transport{Proxy: http.ProxyFromEnvironment}
+	if r.Insecure {
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	}
+	return &http.Client{
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 10 {
+				return fmt.Errorf("stopped after 10 redirects")
+			}
+			return r.checkURL(req.URL.String())
+		},
+	}
+}
+
+// get requests the HTTP URL, returning an error unless it succeeds.
+func (r *Retriever) get(uri string) (*http.Response, error) {
+	resp, err := r.client().Get(uri)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("HTTP %d on retrieving %q", resp.StatusCode, uri)
+	}
+	return resp, nil
+}
+
+// retrieveImageURL returns the image at the given file or HTTP URL. Images
+// retrieved over HTTP are spooled to a temporary file so they can be rewound.
+func (r *Retriever) retrieveImageURL(uri string) (aciremote.ReaderCloserSeeker, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return aciremote.RetrieveImage(uri, r.Insecure)
+	}
+
+	resp, err := r.get(uri)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	f, err := ioutil.TempFile("", "remote-aci")
+	if err != nil {
+		return nil, err
+	}
+	os.Remove(f.Name())
+	if _, err := io.Copy(f, resp.Body); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if _, err := f.Seek(0, 0); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
+// retrieveURL returns the contents of the given file or HTTP URL.
+func (r *Retriever) retrieveURL(uri string) ([]byte, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	switch u.Scheme {
+	case "file":
+		return ioutil.ReadFile(u.Path)
+
+	case "http", "https":
+		resp, err := r.get(uri)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+		return ioutil.ReadAll(resp.Body)
+
+	default:
+		return nil, fmt.Errorf("%q scheme not supported", u.Scheme)
+	}
+}
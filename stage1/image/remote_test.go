@@ -0,0 +1,68 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package image
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/apcera/util/testtool"
+)
+
+func TestRetriever_RedirectPolicy(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	// The denied server holds the image, and the allowed server redirects to
+	// it, or to another path of its own.
+	denied := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte("denied"))
+	}))
+	defer denied.Close()
+	allowed := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/external.aci", "/external.aci.asc":
+			http.Redirect(w, req, denied.URL+req.URL.Path, http.StatusFound)
+		case "/internal.aci", "/internal.aci.asc":
+			http.Redirect(w, req, "/image"+req.URL.Path[len("/internal"):], http.StatusFound)
+		default:
+			w.Write([]byte("allowed"))
+		}
+	}))
+	defer allowed.Close()
+
+	r := &Retriever{
+		Policy:   &Policy{AllowedURLs: []string{allowed.URL + "/*"}},
+		Insecure: true,
+	}
+
+	// Redirects to a URL which isn't permitted are refused.
+	if _, err := r.RetrieveImage(allowed.URL + "/external.aci"); err == nil {
+		Fatalf(t, "Expected the redirect of the image to a denied URL to be refused")
+	}
+	if _, err := r.RetrieveSignature(allowed.URL + "/external.aci"); err == nil {
+		Fatalf(t, "Expected the redirect of the signature to a denied URL to be refused")
+	}
+
+	// Redirects to a permitted URL are followed.
+	f, err := r.RetrieveImage(allowed.URL + "/internal.aci")
+	TestExpectSuccess(t, err)
+	b, err := ioutil.ReadAll(f)
+	f.Close()
+	TestExpectSuccess(t, err)
+	TestEqual(t, string(b), "allowed")
+	b, err = r.RetrieveSignature(allowed.URL + "/internal.aci")
+	TestExpectSuccess(t, err)
+	TestEqual(t, string(b), "allowed")
+
+	// Redirects are also checked for being secure.
+	r.Policy = nil
+	r.Insecure = false
+	req, err := http.NewRequest("GET", denied.URL+"/image.aci", nil)
+	TestExpectSuccess(t, err)
+	if err := r.client().CheckRedirect(req, []*http.Request{req}); err == nil {
+		Fatalf(t, "Expected the redirect to an HTTP URL to be refused")
+	}
+}
@@ -0,0 +1,314 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package server
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/apcera/kurma/stage1/container"
+)
+
+var (
+	// rpcDurationBuckets are the upper bounds, in seconds, of the histogram
+	// buckets for RPC latencies.
+	rpcDurationBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+	// startDurationBuckets are the upper bounds, in seconds, of the histogram
+	// buckets for the time taken to start a container.
+	startDurationBuckets = []float64{.1, .25, .5, 1, 2.5, 5, 10, 30, 60, 120}
+
+	// containerStates are the states containers are counted by, so that each
+	// is reported even when no containers are in it.
+	containerStates = []container.ContainerState{
+		container.NEW,
+		container.STARTING,
+		container.RUNNING,
+		container.STOPPING,
+		container.STOPPED,
+		container.EXITED,
+		container.FAILED,
+	}
+)
+
+// metrics collects the server's metrics and serves them in the Prometheus text
+// exposition format. The container metrics are read from the cgroups at the
+// time they're requested, while the rest are accumulated as they happen.
+type metrics struct {
+	manager *container.Manager
+
+	rpcCalls       map[rpcResult]uint64
+	rpcDurations   map[string]*histogram
+	startDurations *histogram
+	startFailures  uint64
+	uploadBytes    uint64
+	starting       map[string]time.Time
+	mutex          sync.Mutex
+}
+
+// rpcResult identifies the calls to a method which completed with the same
+// result.
+type rpcResult struct {
+	method string
+	code   string
+}
+
+func newMetrics(manager *container.Manager) *metrics {
+	return &metrics{
+		manager:        manager,
+		rpcCalls:       make(map[rpcResult]uint64),
+		rpcDurations:   make(map[string]*histogram),
+		startDurations: newHistogram(startDurationBuckets),
+		starting:       make(map[string]time.Time),
+	}
+}
+
+// listen begins serving the metrics over HTTP on the given address.
+func (m *metrics) listen(address string) error {
+	l, err := net.Listen("tcp", address)
+	if err != nil {
+		return fmt.Errorf("failed to listen for metrics: %v", err)
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", m)
+	go http.Serve(l, mux)
+	return nil
+}
+
+// observeRPC records a completed RPC call.
+func (m *metrics) observeRPC(method string, duration time.Duration, err error) {
+	code := "ok"
+	if err != nil {
+		code = "error"
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.rpcCalls[rpcResult{method, code}]++
+	h := m.rpcDurations[method]
+	if h == nil {
+		h = newHistogram(rpcDurationBuckets)
+		m.rpcDurations[method] = h
+	}
+	h.observe(duration.Seconds())
+}
+
+// addUploadBytes records the number of bytes received for an image upload.
+func (m *metrics) addUploadBytes(n int) {
+	m.mutex.Lock()
+	m.uploadBytes += uint64(n)
+	m.mutex.Unlock()
+}
+
+// trackStartups follows the container events to record how long containers
+// take to start, and how many fail to.
+func (m *metrics) trackStartups() {
+	events := m.manager.Subscribe()
+	for event := range events {
+		if event.OOM {
+			continue
+		}
+		m.mutex.Lock()
+		switch event.State {
+		case container.STARTING:
+			m.starting[event.UUID] = event.Time
+		case container.RUNNING:
+			if start, ok := m.starting[event.UUID]; ok {
+				m.startDurations.observe(event.Time.Sub(start).Seconds())
+				delete(m.starting, event.UUID)
+			}
+		case container.FAILED:
+			if _, ok := m.starting[event.UUID]; ok {
+				m.startFailures++
+				delete(m.starting, event.UUID)
+			}
+		default:
+			delete(m.starting, event.UUID)
+		}
+		m.mutex.Unlock()
+	}
+}
+
+// uploadCounter wraps the reader of an image upload to record the number of
+// bytes received.
+type uploadCounter struct {
+	io.Reader
+	metrics *metrics
+}
+
+func (u *uploadCounter) Read(p []byte) (int, error) {
+	n, err := u.Reader.Read(p)
+	u.metrics.addUploadBytes(n)
+	return n, err
+}
+
+func (m *metrics) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	m.writeContainerMetrics(w)
+	m.writeServerMetrics(w)
+}
+
+// writeContainerMetrics writes the counts of the containers in each state, and
+// the resource usage of each running container.
+func (m *metrics) writeContainerMetrics(w io.Writer) {
+	containers := m.manager.Containers()
+
+	counts := make(map[container.ContainerState]int)
+	for _, c := range containers {
+		counts[c.State()]++
+	}
+	writeHeader(w, "kurma_containers", "gauge", "Number of containers in each state.")
+	for _, state := range containerStates {
+		fmt.Fprintf(w, "kurma_containers{state=%s} %d\n", quoteLabel(state.String()), counts[state])
+	}
+
+	type sample struct {
+		labels string
+		stats  *container.Stats
+	}
+	var samples []sample
+	writeHeader(w, "kurma_container_oom_kills_total", "counter", "Number of times the OOM killer was invoked within the container.")
+	for _, c := range containers {
+		name := ""
+		if apps := c.Manifest().Apps; len(apps) > 0 {
+			name = apps[0].Name.String()
+		}
+		labels := fmt.Sprintf("uuid=%s,name=%s", quoteLabel(c.UUID()), quoteLabel(name))
+		fmt.Fprintf(w, "kurma_container_oom_kills_total{%s} %d\n", labels, c.OOMKills())
+
+		stats, err := c.Stats()
+		if err != nil {
+			continue
+		}
+		samples = append(samples, sample{labels, stats})
+	}
+
+	writeHeader(w, "kurma_container_cpu_seconds_total", "counter", "Total CPU time consumed by the container.")
+	for _, s := range samples {
+		fmt.Fprintf(w, "kurma_container_cpu_seconds_total{%s} %g\n", s.labels, s.stats.CPUTime.Seconds())
+	}
+	writeHeader(w, "kurma_container_memory_usage_bytes", "gauge", "Memory currently used by the container.")
+	for _, s := range samples {
+		fmt.Fprintf(w, "kurma_container_memory_usage_bytes{%s} %d\n", s.labels, s.stats.MemoryUsed)
+	}
+	writeHeader(w, "kurma_container_memory_limit_bytes", "gauge", "Memory limit of the container, for containers which are limited.")
+	for _, s := range samples {
+		if s.stats.MemoryLimit > 0 {
+			fmt.Fprintf(w, "kurma_container_memory_limit_bytes{%s} %d\n", s.labels, s.stats.MemoryLimit)
+		}
+	}
+	writeHeader(w, "kurma_container_tasks", "gauge", "Number of processes within the container.")
+	for _, s := range samples {
+		fmt.Fprintf(w, "kurma_container_tasks{%s} %d\n", s.labels, s.stats.Tasks)
+	}
+}
+
+// writeServerMetrics writes the metrics accumulated by the server.
+func (m *metrics) writeServerMetrics(w io.Writer) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	writeHeader(w, "kurma_container_start_duration_seconds", "histogram", "Time taken for containers to start.")
+	m.startDurations.write(w, "kurma_container_start_duration_seconds", "")
+
+	writeHeader(w, "kurma_container_start_failures_total", "counter", "Number of containers which failed to start.")
+	fmt.Fprintf(w, "kurma_container_start_failures_total %d\n", m.startFailures)
+
+	results := make([]rpcResult, 0, len(m.rpcCalls))
+	for r := range m.rpcCalls {
+		results = append(results, r)
+	}
+	sort.Sort(rpcResults(results))
+	writeHeader(w, "kurma_rpc_requests_total", "counter", "Number of RPC calls handled, by method and result.")
+	for _, r := range results {
+		fmt.Fprintf(w, "kurma_rpc_requests_total{method=%s,code=%s} %d\n",
+			quoteLabel(r.method), quoteLabel(r.code), m.rpcCalls[r])
+	}
+
+	methods := make([]string, 0, len(m.rpcDurations))
+	for method := range m.rpcDurations {
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+	writeHeader(w, "kurma_rpc_duration_seconds", "histogram", "Time taken to handle RPC calls, by method.")
+	for _, method := range methods {
+		labels := fmt.Sprintf("method=%s", quoteLabel(method))
+		m.rpcDurations[method].write(w, "kurma_rpc_duration_seconds", labels)
+	}
+
+	writeHeader(w, "kurma_image_upload_bytes_total", "counter", "Number of bytes received in image uploads.")
+	fmt.Fprintf(w, "kurma_image_upload_bytes_total %d\n", m.uploadBytes)
+}
+
+type rpcResults []rpcResult
+
+func (r rpcResults) Len() int      { return len(r) }
+func (r rpcResults) Swap(i, j int) { r[i], r[j] = r[j], r[i] }
+func (r rpcResults) Less(i, j int) bool {
+	if r[i].method != r[j].method {
+		return r[i].method < r[j].method
+	}
+	return r[i].code < r[j].code
+}
+
+// histogram counts observations within cumulative buckets, as Prometheus
+// histograms do.
+type histogram struct {
+	buckets []float64
+	counts  []uint64
+	sum     float64
+	count   uint64
+}
+
+func newHistogram(buckets []float64) *histogram {
+	return &histogram{
+		buckets: buckets,
+		counts:  make([]uint64, len(buckets)),
+	}
+}
+
+func (h *histogram) observe(v float64) {
+	for i, bound := range h.buckets {
+		if v <= bound {
+			h.counts[i]++
+		}
+	}
+	h.sum += v
+	h.count++
+}
+
+// write writes the histogram's series with the given labels, which are in the
+// form of `name="value",...` or blank.
+func (h *histogram) write(w io.Writer, name, labels string) {
+	prefix := ""
+	if labels != "" {
+		prefix = labels + ","
+	}
+	for i, bound := range h.buckets {
+		fmt.Fprintf(w, "%s_bucket{%sle=\"%g\"} %d\n", name, prefix, bound, h.counts[i])
+	}
+	fmt.Fprintf(w, "%s_bucket{%sle=\"+Inf\"} %d\n", name, prefix, h.count)
+	if labels != "" {
+		labels = "{" + labels + "}"
+	}
+	fmt.Fprintf(w, "%s_sum%s %g\n", name, labels, h.sum)
+	fmt.Fprintf(w, "%s_count%s %d\n", name, labels, h.count)
+}
+
+func writeHeader(w io.Writer, name, kind, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n", name, help)
+	fmt.Fprintf(w, "# TYPE %s %s\n", name, kind)
+}
+
+// labelEscaper escapes label values as required by the exposition format.
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func quoteLabel(v string) string {
+	return `"` + labelEscaper.Replace(v) + `"`
+}
@@ -0,0 +1,72 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package server
+
+import (
+	"bytes"
+	"testing"
+
+	. "github.com/apcera/util/testtool"
+)
+
+func TestHistogram_Write(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	h := newHistogram([]float64{.1, 1, 10})
+	for _, v := range []float64{.05, .1, .5, 2, 20} {
+		h.observe(v)
+	}
+
+	// The buckets are cumulative, with each bound inclusive.
+	var buf bytes.Buffer
+	h.write(&buf, "test_seconds", "")
+	TestEqual(t, buf.String(), ""+
+		"test_seconds_bucket{le=\"0.1\"} 2\n"+
+		"test_seconds_bucket{le=\"1\"} 3\n"+
+		"test_seconds_bucket{le=\"10\"} 4\n"+
+		"test_seconds_bucket{le=\"+Inf\"} 5\n"+
+		"test_seconds_sum 22.65\n"+
+		"test_seconds_count 5\n")
+
+	// Labels are included in each of the series.
+	buf.Reset()
+	h.write(&buf, "test_seconds", `method="List"`)
+	TestEqual(t, buf.String(), ""+
+		"test_seconds_bucket{method=\"List\",le=\"0.1\"} 2\n"+
+		"test_seconds_bucket{method=\"List\",le=\"1\"} 3\n"+
+		"test_seconds_bucket{method=\"List\",le=\"10\"} 4\n"+
+		"test_seconds_bucket{method=\"List\",le=\"+Inf\"} 5\n"+
+		"test_seconds_sum{method=\"List\"} 22.65\n"+
+		"test_seconds_count{method=\"List\"} 5\n")
+
+	// An empty histogram reports zeros.
+	buf.Reset()
+	newHistogram([]float64{1}).write(&buf, "empty", "")
+	TestEqual(t, buf.String(), ""+
+		"empty_bucket{le=\"1\"} 0\n"+
+		"empty_bucket{le=\"+Inf\"} 0\n"+
+		"empty_sum 0\n"+
+		"empty_count 0\n")
+}
+
+func TestQuoteLabel(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"", `""`},
+		{"running", `"running"`},
+		{"example.com/app", `"example.com/app"`},
+		{`say "hi"`, `"say \"hi\""`},
+		{`C:\path`, `"C:\\path"`},
+		{"two\nlines", `"two\nlines"`},
+		{`\"` + "\n", `"\\\"\n"`},
+	}
+	for _, test := range tests {
+		TestEqual(t, quoteLabel(test.value), test.want)
+	}
+}
@@ -3,52 +3,158 @@
 package server
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
+	"sync"
+	"time"
 
 	pb "github.com/apcera/kurma/stage1/client"
 	"github.com/apcera/kurma/stage1/container"
+	"github.com/apcera/kurma/stage1/image"
 	"github.com/apcera/logray"
 	"github.com/apcera/util/uuid"
 	"github.com/appc/spec/schema"
+	"github.com/appc/spec/schema/types"
 	"golang.org/x/net/context"
 )
 
 type rpcServer struct {
-	log     *logray.Logger
-	manager *container.Manager
+	log          *logray.Logger
+	manager      *container.Manager
+	imageManager *image.Manager
+	metrics      *metrics
 
-	pendingUploads map[string]*pendingContainer
+	pendingUploads     map[string]*pendingUpload
+	pendingUploadsLock sync.Mutex
 }
 
+// pendingContainer tracks a requested container which is waiting on the
+// images for its apps to be uploaded.
 type pendingContainer struct {
-	name          string
-	imageManifest *schema.ImageManifest
+	apps     []*container.PodApp
+	volumes  []types.Volume
+	uploaded int
+
+	// abandoned is set once one of the uploads has failed, so the container
+	// will never be created.
+	abandoned bool
+}
+
+// closeImages closes any of the image files opened for the pending container's
+// apps.
+func (pc *pendingContainer) closeImages() {
+	for _, app := range pc.apps {
+		if app.Image != nil {
+			app.Image.Close()
+		}
+	}
+}
+
+// pendingUpload is an image upload expected for one of the apps of a pending
+// container.
+type pendingUpload struct {
+	container *pendingContainer
+	app       *container.PodApp
+	signature []byte
 }
 
 func (s *rpcServer) Create(ctx context.Context, in *pb.CreateRequest) (*pb.CreateResponse, error) {
 	s.log.Debug("Received Create request.")
 
-	// unmarshal the image manifest, ensure its valid
-	var imageManifest *schema.ImageManifest
-	if err := json.Unmarshal(in.Manifest, &imageManifest); err != nil {
-		return nil, fmt.Errorf("invalid image manifest: %v", err)
+	// put together the pending container handler
+	pc := &pendingContainer{}
+	requests := append([]*pb.AppRequest{
+		&pb.AppRequest{Name: in.Name, Manifest: in.Manifest, ImageId: in.ImageId, Signature: in.Signature},
+	}, in.Apps...)
+	var uploads []*pendingUpload
+	for _, req := range requests {
+		// unmarshal the image manifest, ensure its valid
+		var imageManifest *schema.ImageManifest
+		if err := json.Unmarshal(req.Manifest, &imageManifest); err != nil {
+			pc.closeImages()
+			return nil, fmt.Errorf("invalid image manifest: %v", err)
+		}
+
+		// validate the manifest with the manager
+		if err := s.manager.Validate(imageManifest); err != nil {
+			pc.closeImages()
+			return nil, fmt.Errorf("image manifest is not valid: %v", err)
+		}
+
+		app := &container.PodApp{
+			Name:          req.Name,
+			ImageManifest: imageManifest,
+		}
+		pc.apps = append(pc.apps, app)
+
+		// use the stored copy of the image if it is already on the host,
+		// otherwise it needs to be uploaded. Stored images have already had
+		// their signatures verified, which covers the manifest within them.
+		if stored := s.imageManager.GetImage(req.ImageId); req.ImageId != "" && stored != nil {
+			if !sameManifest(imageManifest, stored) {
+				pc.closeImages()
+				return nil, fmt.Errorf("image manifest does not match the manifest of image %s", req.ImageId)
+			}
+			f, err := s.imageManager.OpenImage(req.ImageId)
+			if err != nil {
+				pc.closeImages()
+				return nil, err
+			}
+			s.log.Debugf("Using stored image %s", req.ImageId)
+			app.Image = f
+			pc.uploaded++
+		} else {
+			if s.imageManager.VerifiesSignatures() && len(req.Signature) == 0 {
+				pc.closeImages()
+				return nil, fmt.Errorf("a signature is required for image %q", imageManifest.Name)
+			}
+			uploads = append(uploads, &pendingUpload{container: pc, app: app, signature: req.Signature})
+		}
 	}
 
-	// validate the manifest with the manager
-	if err := s.manager.Validate(imageManifest); err != nil {
-		return nil, fmt.Errorf("image manifest is not valid: %v", err)
+	// unmarshal the volumes, if any were given
+	if len(in.Volumes) > 0 {
+		if err := json.Unmarshal(in.Volumes, &pc.volumes); err != nil {
+			pc.closeImages()
+			return nil, fmt.Errorf("invalid volumes: %v", err)
+		}
 	}
 
-	// put together the pending container handler
-	pc := &pendingContainer{
-		name:          in.Name,
-		imageManifest: imageManifest,
+	// if all the images are stored, the container can be created immediately
+	resp := &pb.CreateResponse{}
+	if len(uploads) == 0 {
+		s.log.Debug("Initializing container")
+		c, err := s.manager.CreatePod(pc.apps, pc.volumes)
+		if err != nil {
+			pc.closeImages()
+			return nil, err
+		}
+		resp.Container, err = pbContainer(c)
+		if err != nil {
+			return nil, err
+		}
+		resp.AppUploadIds = make([]string, len(in.Apps))
+		return resp, nil
 	}
-	resp := &pb.CreateResponse{
-		ImageUploadId: uuid.Variant4().String(),
+
+	// generate an upload ID for each of the app images that need uploading
+	ids := make(map[*container.PodApp]string)
+	s.pendingUploadsLock.Lock()
+	for _, upload := range uploads {
+		id := uuid.Variant4().String()
+		ids[upload.app] = id
+		s.pendingUploads[id] = upload
+	}
+	s.pendingUploadsLock.Unlock()
+
+	for i, app := range pc.apps {
+		if i == 0 {
+			resp.ImageUploadId = ids[app]
+		} else {
+			resp.AppUploadIds = append(resp.AppUploadIds, ids[app])
+		}
 	}
-	s.pendingUploads[resp.ImageUploadId] = pc
 
 	s.log.Debug("Finished Create request.")
 	return resp, nil
@@ -61,13 +167,85 @@ func (s *rpcServer) UploadImage(stream pb.Kurma_UploadImageServer) error {
 		return err
 	}
 
-	pc := s.pendingUploads[packet.StreamId]
+	s.pendingUploadsLock.Lock()
+	upload := s.pendingUploads[packet.StreamId]
 	delete(s.pendingUploads, packet.StreamId)
+	s.pendingUploadsLock.Unlock()
+	if upload == nil {
+		return fmt.Errorf("no pending container for upload %q", packet.StreamId)
+	}
+	pc := upload.container
 
+	// store the image, the container is launched from the stored copy
 	r := pb.NewByteStreamReader(stream, packet)
+	hash, manifest, err := s.imageManager.CreateImage(&uploadCounter{r, s.metrics}, upload.signature)
+	r.Close()
+	if err != nil {
+		s.abandonContainer(pc)
+		return err
+	}
+	if !sameManifest(upload.app.ImageManifest, manifest) {
+		s.abandonContainer(pc)
+		return fmt.Errorf("image manifest does not match the manifest of image %s", hash)
+	}
+	f, err := s.imageManager.OpenImage(hash)
+	if err != nil {
+		s.abandonContainer(pc)
+		return err
+	}
+
+	// the container is created once the images for all the apps in the pod
+	// have been uploaded
+	s.pendingUploadsLock.Lock()
+	if pc.abandoned {
+		s.pendingUploadsLock.Unlock()
+		f.Close()
+		return fmt.Errorf("the upload of another image for the container failed")
+	}
+	upload.app.Image = f
+	pc.uploaded++
+	ready := pc.uploaded == len(pc.apps)
+	s.pendingUploadsLock.Unlock()
+	if !ready {
+		return nil
+	}
+
 	s.log.Debug("Initializing container")
-	_, err = s.manager.Create(pc.name, pc.imageManifest, r)
-	return err
+	if _, err := s.manager.CreatePod(pc.apps, pc.volumes); err != nil {
+		pc.closeImages()
+		return err
+	}
+	return nil
+}
+
+// abandonContainer releases a pending container which can't be created because
+// one of its uploads failed. The images already opened for it are closed and
+// the uploads still expected for its other apps are removed.
+func (s *rpcServer) abandonContainer(pc *pendingContainer) {
+	s.pendingUploadsLock.Lock()
+	defer s.pendingUploadsLock.Unlock()
+	pc.abandoned = true
+	for id, upload := range s.pendingUploads {
+		if upload.container == pc {
+			delete(s.pendingUploads, id)
+		}
+	}
+	pc.closeImages()
+}
+
+// sameManifest returns whether the manifest given in a request matches the
+// manifest within the image. The container is run from the requested manifest,
+// so it must be the one covered by the image's signature.
+func sameManifest(requested, image *schema.ImageManifest) bool {
+	a, err := json.Marshal(requested)
+	if err != nil {
+		return false
+	}
+	b, err := json.Marshal(image)
+	if err != nil {
+		return false
+	}
+	return bytes.Equal(a, b)
 }
 
 func (s *rpcServer) Destroy(ctx context.Context, in *pb.ContainerRequest) (*pb.None, error) {
@@ -75,7 +253,8 @@ func (s *rpcServer) Destroy(ctx context.Context, in *pb.ContainerRequest) (*pb.N
 	if container == nil {
 		return nil, fmt.Errorf("specified container not found")
 	}
-	if err := container.Stop(); err != nil {
+	gracePeriod := time.Duration(in.GracePeriod) * time.Second
+	if err := container.Stop(gracePeriod); err != nil {
 		return nil, err
 	}
 
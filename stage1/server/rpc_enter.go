@@ -5,31 +5,33 @@ package server
 import (
 	"fmt"
 	"io"
+	"os"
+	"syscall"
+	"unsafe"
 
 	pb "github.com/apcera/kurma/stage1/client"
+	"github.com/apcera/kurma/stage1/container"
 	"github.com/kr/pty"
 )
 
 func (s *rpcServer) Enter(stream pb.Kurma_EnterServer) error {
 	s.log.Debug("Received enter request")
 
-	// Receive the first chunk so we can get the stream ID, which will be the UUID
-	// of the container. The byte portion will be blank, the client always sends a
-	// chunk first so the UUID is available immediately.
-	chunk, err := stream.Recv()
+	// Receive the first request so we can get the UUID of the container. It may
+	// also carry the initial size of the client's terminal.
+	req, err := stream.Recv()
 	if err != nil {
 		return err
 	}
 
 	// get the container
-	container := s.manager.Container(chunk.StreamId)
-	if container == nil {
+	c := s.manager.Container(req.Uuid)
+	if c == nil {
 		return fmt.Errorf("specified container not found")
 	}
 
-	// configure the io.Reader/Writer for the transport
-	w := pb.NewByteStreamWriter(stream, chunk.StreamId)
-	r := pb.NewByteStreamReader(stream, nil)
+	// configure the io.Writer for the transport
+	w := pb.NewByteStreamWriter(stream, req.Uuid)
 
 	// create a pty, which we'll use for the process entering the container and
 	// copy the data back up the transport.
@@ -41,13 +43,80 @@ func (s *rpcServer) Enter(stream pb.Kurma_EnterServer) error {
 		slave.Close()
 		master.Close()
 	}()
+	if err := handleEnterRequest(master, req); err != nil {
+		return err
+	}
 	go io.Copy(w, master)
-	go io.Copy(master, r)
+	go func() {
+		for {
+			req, err := stream.Recv()
+			if err != nil {
+				return
+			}
+			if err := handleEnterRequest(master, req); err != nil {
+				s.log.Warnf("Failed to handle enter request: %v", err)
+			}
+		}
+	}()
 
 	// enter into the container
-	if err := container.Enter(slave); err != nil {
+	opts := &container.ExecOptions{
+		App:         req.App,
+		Command:     req.Command,
+		Environment: req.Environment,
+		User:        req.User,
+		Group:       req.Group,
+		Stdin:       slave,
+		Stdout:      slave,
+		Stderr:      slave,
+	}
+	if err := c.Enter(opts); err != nil {
 		return err
 	}
 	s.log.Debugf("Enter request finished")
 	return nil
 }
+
+// handleEnterRequest applies a request received on the enter stream to the
+// session's pty, writing its input and handling any control messages.
+func handleEnterRequest(master *os.File, req *pb.EnterRequest) error {
+	if ws := req.WindowSize; ws != nil {
+		if err := setWindowSize(master, ws.Rows, ws.Columns); err != nil {
+			return fmt.Errorf("failed to resize the terminal: %v", err)
+		}
+	}
+	if req.Signal != 0 {
+		if err := ioctl(master, syscall.TIOCSIG, uintptr(req.Signal)); err != nil {
+			return fmt.Errorf("failed to send signal %d: %v", req.Signal, err)
+		}
+	}
+	if len(req.Stdin) > 0 {
+		if _, err := master.Write(req.Stdin); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setWindowSize changes the size of the terminal, which notifies its
+// foreground process with a SIGWINCH.
+func setWindowSize(f *os.File, rows, columns uint32) error {
+	ws := struct {
+		rows    uint16
+		columns uint16
+		xpixel  uint16
+		ypixel  uint16
+	}{
+		rows:    uint16(rows),
+		columns: uint16(columns),
+	}
+	return ioctl(f, syscall.TIOCSWINSZ, uintptr(unsafe.Pointer(&ws)))
+}
+
+func ioctl(f *os.File, request, arg uintptr) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), request, arg)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
@@ -0,0 +1,184 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package server
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"syscall"
+
+	pb "github.com/apcera/kurma/stage1/client"
+	"github.com/apcera/kurma/stage1/container"
+	"github.com/kr/pty"
+	"golang.org/x/net/context"
+)
+
+func (s *rpcServer) Exec(stream pb.Kurma_ExecServer) error {
+	s.log.Debug("Received exec request")
+
+	// The first request describes the process to run, any following it carry
+	// the process's stdin.
+	req, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+
+	// get the container
+	c := s.manager.Container(req.Uuid)
+	if c == nil {
+		return fmt.Errorf("specified container not found")
+	}
+
+	opts := &container.ExecOptions{
+		App:              req.App,
+		Command:          req.Command,
+		Environment:      req.Environment,
+		WorkingDirectory: req.WorkingDirectory,
+		User:             req.User,
+		Group:            req.Group,
+	}
+
+	// Set up the process's stdin, stdout, and stderr. With a tty, they're all
+	// the slave side of a pty. Otherwise each is given its own pipe.
+	var stdin io.WriteCloser
+	var outputs []io.Reader
+	var files []*os.File
+	defer func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}()
+	if req.Tty {
+		master, slave, err := pty.Open()
+		if err != nil {
+			return err
+		}
+		files = append(files, master, slave)
+		opts.Stdin, opts.Stdout, opts.Stderr = slave, slave, slave
+		stdin = master
+		outputs = []io.Reader{master}
+	} else {
+		pipes := make([]*os.File, 6)
+		for i := 0; i < len(pipes); i += 2 {
+			r, w, err := os.Pipe()
+			if err != nil {
+				return err
+			}
+			pipes[i], pipes[i+1] = r, w
+			files = append(files, r, w)
+		}
+		opts.Stdin, opts.Stdout, opts.Stderr = pipes[0], pipes[3], pipes[5]
+		stdin = pipes[1]
+		outputs = []io.Reader{pipes[2], pipes[4]}
+	}
+
+	p, err := c.Exec(opts)
+	if err != nil {
+		return err
+	}
+
+	// Kill the process if the client goes away, since nothing else would stop
+	// a long running command or a shell on a tty.
+	stop := killOnDone(stream.Context(), p)
+	defer stop()
+
+	// The process has its own copies of its ends, so release ours so that its
+	// output hits EOF once it exits.
+	opts.Stdin.Close()
+	opts.Stdout.Close()
+	opts.Stderr.Close()
+
+	// Stream the process's output back. Sends on the stream aren't safe to make
+	// concurrently, so they're serialized.
+	var sendLock sync.Mutex
+	send := func(resp *pb.ExecResponse) error {
+		sendLock.Lock()
+		defer sendLock.Unlock()
+		return stream.Send(resp)
+	}
+	var wg sync.WaitGroup
+	for i, r := range outputs {
+		wg.Add(1)
+		go func(r io.Reader, stderr bool) {
+			defer wg.Done()
+			buf := make([]byte, 32*1024)
+			for {
+				n, err := r.Read(buf)
+				if n > 0 {
+					resp := &pb.ExecResponse{}
+					b := append([]byte(nil), buf[:n]...)
+					if stderr {
+						resp.Stderr = b
+					} else {
+						resp.Stdout = b
+					}
+					if send(resp) != nil {
+						return
+					}
+				}
+				if err != nil {
+					return
+				}
+			}
+		}(r, i == 1)
+	}
+
+	// Copy the client's input to the process's stdin. A pipe is closed once the
+	// client is done sending, but a pty is left open since closing it would
+	// also cut off the output.
+	go func() {
+		for {
+			in, err := stream.Recv()
+			if err != nil {
+				break
+			}
+			if len(in.Stdin) == 0 {
+				continue
+			}
+			if _, err := stdin.Write(in.Stdin); err != nil {
+				break
+			}
+		}
+		if !req.Tty {
+			stdin.Close()
+		}
+	}()
+
+	state, err := p.Wait()
+	if err != nil {
+		return err
+	}
+	wg.Wait()
+
+	exitCode := 0
+	if status, ok := state.Sys().(syscall.WaitStatus); ok {
+		if status.Signaled() {
+			exitCode = 128 + int(status.Signal())
+		} else {
+			exitCode = status.ExitStatus()
+		}
+	}
+	s.log.Debugf("Exec request finished with exit code %d", exitCode)
+	return send(&pb.ExecResponse{Exited: true, ExitCode: int32(exitCode)})
+}
+
+// killOnDone kills the process if the context is done before the returned
+// function is called, such as when the client of a stream disconnects.
+func killOnDone(ctx context.Context, p *os.Process) func() {
+	exited := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		select {
+		case <-ctx.Done():
+			p.Kill()
+		case <-exited:
+		}
+	}()
+	return func() {
+		close(exited)
+		<-done
+	}
+}
@@ -0,0 +1,84 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package server
+
+import (
+	"io"
+	"os"
+	"os/exec"
+	"syscall"
+	"testing"
+	"time"
+
+	pb "github.com/apcera/kurma/stage1/client"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+
+	. "github.com/apcera/util/testtool"
+)
+
+// execStream is an exec stream which sends the given requests.
+type execStream struct {
+	grpc.ServerStream
+	requests []*pb.ExecRequest
+}
+
+func (e *execStream) Recv() (*pb.ExecRequest, error) {
+	if len(e.requests) == 0 {
+		return nil, io.EOF
+	}
+	req := e.requests[0]
+	e.requests = e.requests[1:]
+	return req, nil
+}
+
+func (e *execStream) Send(*pb.ExecResponse) error {
+	return nil
+}
+
+func TestExec_ContainerNotFound(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	s := newTestServer(t)
+	stream := &execStream{requests: []*pb.ExecRequest{{Uuid: "missing", Command: []string{"/bin/true"}}}}
+	if err := s.Exec(stream); err == nil {
+		Fatalf(t, "Expected an error for a container which doesn't exist")
+	}
+}
+
+// startSleep starts a process which runs for the given number of seconds.
+func startSleep(t *testing.T, seconds string) *os.Process {
+	cmd := exec.Command("sleep", seconds)
+	TestExpectSuccess(t, cmd.Start())
+	return cmd.Process
+}
+
+func TestKillOnDone(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	// The process is killed once the context is done.
+	ctx, cancel := context.WithCancel(context.Background())
+	p := startSleep(t, "10")
+	stop := killOnDone(ctx, p)
+	start := time.Now()
+	cancel()
+	state, err := p.Wait()
+	TestExpectSuccess(t, err)
+	stop()
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		Fatalf(t, "Expected the process to be killed, took %v to exit", elapsed)
+	}
+	TestEqual(t, state.Sys().(syscall.WaitStatus).Signal(), syscall.SIGKILL)
+
+	// Once stopped, the process is left alone.
+	ctx, cancel = context.WithCancel(context.Background())
+	p = startSleep(t, "0.2")
+	stop = killOnDone(ctx, p)
+	stop()
+	cancel()
+	state, err = p.Wait()
+	TestExpectSuccess(t, err)
+	TestEqual(t, state.Success(), true)
+}
@@ -0,0 +1,243 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package server
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	pb "github.com/apcera/kurma/stage1/client"
+	"github.com/apcera/kurma/stage1/container"
+)
+
+// logPollInterval is how often the logs are checked for new lines when they're
+// being followed.
+const logPollInterval = 250 * time.Millisecond
+
+func (s *rpcServer) Logs(in *pb.LogsRequest, stream pb.Kurma_LogsServer) error {
+	s.log.Debugf("Received logs request for %q", in.Uuid)
+
+	c := s.manager.Container(in.Uuid)
+	if c == nil {
+		return fmt.Errorf("specified container not found")
+	}
+
+	logs, err := requestedLogs(c, in)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		for _, l := range logs {
+			l.close()
+		}
+	}()
+
+	// send what has already been written to each of the logs, skipping those
+	// which haven't been created yet
+	for _, l := range logs {
+		if opened, err := l.open(); err != nil {
+			return err
+		} else if !opened {
+			continue
+		}
+		if err := l.seekTail(int(in.Tail)); err != nil {
+			return err
+		}
+		var timestamp int64
+		if in.Timestamps {
+			fi, err := l.file.Stat()
+			if err != nil {
+				return err
+			}
+			timestamp = fi.ModTime().UnixNano()
+		}
+		if err := l.send(stream, timestamp, !in.Follow); err != nil {
+			return err
+		}
+	}
+	if !in.Follow {
+		return nil
+	}
+
+	for {
+		// The state is checked before reading so that anything written before
+		// the container finished is still sent.
+		finished := false
+		switch c.State() {
+		case container.STOPPED, container.EXITED, container.FAILED:
+			finished = true
+		}
+
+		var timestamp int64
+		if in.Timestamps {
+			timestamp = time.Now().UnixNano()
+		}
+		for _, l := range logs {
+			// logs for apps which start later are picked up once created
+			if opened, err := l.open(); err != nil {
+				return err
+			} else if !opened {
+				continue
+			}
+			if err := l.send(stream, timestamp, finished); err != nil {
+				return err
+			}
+		}
+		if finished {
+			return nil
+		}
+
+		select {
+		case <-stream.Context().Done():
+			s.log.Debugf("Logs request for %q finished", in.Uuid)
+			return nil
+		case <-time.After(logPollInterval):
+		}
+	}
+}
+
+// logFile tracks the reading of lines from one of a container's logs.
+type logFile struct {
+	app     string
+	stream  pb.LogsRequest_Stream
+	path    string
+	file    *os.File
+	reader  *bufio.Reader
+	partial []byte
+}
+
+// requestedLogs returns each of the container's logs covered by the request.
+// The logs are opened separately, since they aren't created until their app
+// starts.
+func requestedLogs(c *container.Container, in *pb.LogsRequest) ([]*logFile, error) {
+	if in.Stream == pb.LogsRequest_STAGE2 {
+		return []*logFile{{stream: in.Stream, path: c.Stage2LogPath()}}, nil
+	}
+
+	apps := c.AppNames()
+	if in.App != "" {
+		apps = []string{in.App}
+	}
+	var streams []pb.LogsRequest_Stream
+	switch in.Stream {
+	case pb.LogsRequest_ALL:
+		streams = []pb.LogsRequest_Stream{pb.LogsRequest_STDOUT, pb.LogsRequest_STDERR}
+	default:
+		streams = []pb.LogsRequest_Stream{in.Stream}
+	}
+
+	var logs []*logFile
+	for _, app := range apps {
+		for _, stream := range streams {
+			path, err := c.LogPath(app, strings.ToLower(stream.String()))
+			if err != nil {
+				return nil, err
+			}
+			logs = append(logs, &logFile{app: app, stream: stream, path: path})
+		}
+	}
+	return logs, nil
+}
+
+// open opens the log if it isn't open already. It returns false if the log
+// hasn't been created yet.
+func (l *logFile) open() (bool, error) {
+	if l.file != nil {
+		return true, nil
+	}
+	f, err := os.Open(l.path)
+	if os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	l.file = f
+	l.reader = bufio.NewReader(f)
+	return true, nil
+}
+
+// close closes the log if it was opened.
+func (l *logFile) close() {
+	if l.file != nil {
+		l.file.Close()
+	}
+}
+
+// seekTail positions the log so that only the given number of lines from its
+// end will be read. The whole log is read when lines is 0.
+func (l *logFile) seekTail(lines int) error {
+	if lines <= 0 {
+		return nil
+	}
+	fi, err := l.file.Stat()
+	if err != nil {
+		return err
+	}
+
+	// Scan backwards through the file counting newlines. The newline at the end
+	// of the file terminates the last line rather than starting a new one.
+	buf := make([]byte, 4096)
+	count := 0
+	for offset := fi.Size(); offset > 0; {
+		n := int64(len(buf))
+		if offset < n {
+			n = offset
+		}
+		offset -= n
+		if _, err := l.file.ReadAt(buf[:n], offset); err != nil && err != io.EOF {
+			return err
+		}
+		for i := n - 1; i >= 0; i-- {
+			if buf[i] != '\n' || offset+i == fi.Size()-1 {
+				continue
+			}
+			count++
+			if count == lines {
+				_, err := l.file.Seek(offset+i+1, os.SEEK_SET)
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// send reads each of the complete lines available in the log and sends them on
+// the stream. An incomplete final line is held back until the rest of it is
+// written, unless flush is set.
+func (l *logFile) send(stream pb.Kurma_LogsServer, timestamp int64, flush bool) error {
+	for {
+		b, err := l.reader.ReadBytes('\n')
+		l.partial = append(l.partial, b...)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+		if err := l.sendLine(stream, timestamp); err != nil {
+			return err
+		}
+	}
+	if flush && len(l.partial) > 0 {
+		return l.sendLine(stream, timestamp)
+	}
+	return nil
+}
+
+// sendLine sends the line which has been read and resets it.
+func (l *logFile) sendLine(stream pb.Kurma_LogsServer, timestamp int64) error {
+	line := l.partial
+	if n := len(line); n > 0 && line[n-1] == '\n' {
+		line = line[:n-1]
+	}
+	l.partial = nil
+	return stream.Send(&pb.LogLine{
+		App:       l.app,
+		Stream:    l.stream,
+		Line:      line,
+		Timestamp: timestamp,
+	})
+}
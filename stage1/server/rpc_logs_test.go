@@ -0,0 +1,120 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package server
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	pb "github.com/apcera/kurma/stage1/client"
+	"google.golang.org/grpc"
+
+	. "github.com/apcera/util/testtool"
+)
+
+// logsStream collects the lines sent on a logs stream.
+type logsStream struct {
+	grpc.ServerStream
+	lines []string
+}
+
+func (s *logsStream) Send(line *pb.LogLine) error {
+	s.lines = append(s.lines, string(line.Line))
+	return nil
+}
+
+// writeLog creates a log with the given contents and opens it.
+func writeLog(t *testing.T, contents string) *logFile {
+	l := &logFile{path: filepath.Join(TempDir(t), "app.stdout")}
+	TestExpectSuccess(t, ioutil.WriteFile(l.path, []byte(contents), os.FileMode(0644)))
+	opened, err := l.open()
+	TestExpectSuccess(t, err)
+	TestEqual(t, opened, true)
+	return l
+}
+
+func TestLogFile_SeekTail(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	long := strings.Repeat(strings.Repeat("x", 99)+"\n", 100)
+	tests := []struct {
+		contents string
+		lines    int
+		want     string
+	}{
+		{"", 1, ""},
+		{"a\nb\nc\n", 0, "a\nb\nc\n"},
+		{"a\nb\nc\n", 1, "c\n"},
+		{"a\nb\nc\n", 2, "b\nc\n"},
+		{"a\nb\nc\n", 3, "a\nb\nc\n"},
+		{"a\nb\nc\n", 10, "a\nb\nc\n"},
+		{"a\nb\nc", 1, "c"},
+		{"a\nb\nc", 2, "b\nc"},
+		{"a\n\n\n", 2, "\n\n"},
+		{long, 50, long[50*100:]},
+	}
+
+	for i, test := range tests {
+		l := writeLog(t, test.contents)
+		TestExpectSuccess(t, l.seekTail(test.lines))
+		b, err := ioutil.ReadAll(l.file)
+		TestExpectSuccess(t, err)
+		l.close()
+		if string(b) != test.want {
+			Fatalf(t, "Test %d: tail %d of %q returned %q, expected %q",
+				i, test.lines, test.contents, string(b), test.want)
+		}
+	}
+}
+
+func TestLogFile_Send(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	// Each step appends to the log and then sends what is available.
+	type step struct {
+		write string
+		flush bool
+		want  []string
+	}
+	tests := [][]step{
+		// Complete lines are sent as they are.
+		{{"a\nb\n", false, []string{"a", "b"}}},
+		// A partial line is held back until it is completed.
+		{
+			{"a\nb", false, []string{"a"}},
+			{"c", false, []string{"a"}},
+			{"d\n", false, []string{"a", "bcd"}},
+		},
+		// A partial line is sent when flushing.
+		{{"a\nb", true, []string{"a", "b"}}},
+		{
+			{"a", false, nil},
+			{"b", true, []string{"ab"}},
+		},
+		// Blank lines are sent.
+		{{"\n\n", false, []string{"", ""}}},
+	}
+
+	for i, steps := range tests {
+		l := writeLog(t, "")
+		stream := &logsStream{}
+		for j, st := range steps {
+			f, err := os.OpenFile(l.path, os.O_WRONLY|os.O_APPEND, 0)
+			TestExpectSuccess(t, err)
+			_, err = f.WriteString(st.write)
+			TestExpectSuccess(t, err)
+			f.Close()
+
+			TestExpectSuccess(t, l.send(stream, 0, st.flush))
+			if strings.Join(stream.lines, "|") != strings.Join(st.want, "|") || len(stream.lines) != len(st.want) {
+				Fatalf(t, "Test %d, step %d: sent %q, expected %q", i, j, stream.lines, st.want)
+			}
+		}
+		l.close()
+	}
+}
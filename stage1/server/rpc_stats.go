@@ -0,0 +1,91 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package server
+
+import (
+	"fmt"
+	"time"
+
+	pb "github.com/apcera/kurma/stage1/client"
+	"github.com/apcera/kurma/stage1/container"
+	"golang.org/x/net/context"
+)
+
+func (s *rpcServer) Stats(ctx context.Context, in *pb.StatsRequest) (*pb.StatsResponse, error) {
+	s.log.Debugf("Received stats request for %q", in.Uuid)
+	return s.sampleStats(in.Uuid)
+}
+
+func (s *rpcServer) StreamStats(in *pb.StatsRequest, stream pb.Kurma_StreamStatsServer) error {
+	s.log.Debugf("Received stats stream request for %q", in.Uuid)
+
+	interval := time.Second
+	if in.Interval > 0 {
+		interval = time.Duration(in.Interval) * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		resp, err := s.sampleStats(in.Uuid)
+		if err != nil {
+			return err
+		}
+		if err := stream.Send(resp); err != nil {
+			return err
+		}
+
+		select {
+		case <-ticker.C:
+		case <-stream.Context().Done():
+			s.log.Debugf("Stats stream request for %q finished", in.Uuid)
+			return nil
+		}
+	}
+}
+
+// sampleStats samples the resource usage of the specified container, or of all
+// of the running containers if the uuid is blank.
+func (s *rpcServer) sampleStats(uuid string) (*pb.StatsResponse, error) {
+	resp := &pb.StatsResponse{Timestamp: time.Now().UnixNano()}
+
+	if uuid != "" {
+		c := s.manager.Container(uuid)
+		if c == nil {
+			return nil, fmt.Errorf("specified container not found")
+		}
+		stats, err := c.Stats()
+		if err != nil {
+			return nil, err
+		}
+		resp.Containers = append(resp.Containers, pbStats(c, stats))
+		return resp, nil
+	}
+
+	// Containers which aren't running have nothing to sample, so they're left
+	// out rather than failing the request.
+	for _, c := range s.manager.Containers() {
+		stats, err := c.Stats()
+		if err != nil {
+			continue
+		}
+		resp.Containers = append(resp.Containers, pbStats(c, stats))
+	}
+	return resp, nil
+}
+
+// pbStats maps the container's resource usage to its protobuf representation.
+func pbStats(c *container.Container, stats *container.Stats) *pb.ContainerStats {
+	pbs := &pb.ContainerStats{
+		Uuid:        c.UUID(),
+		CpuTime:     int64(stats.CPUTime),
+		MemoryUsed:  stats.MemoryUsed,
+		MemoryLimit: stats.MemoryLimit,
+		DiskUsed:    stats.DiskUsed,
+		Tasks:       int32(stats.Tasks),
+	}
+	if apps := c.Manifest().Apps; len(apps) > 0 {
+		pbs.Name = apps[0].Name.String()
+	}
+	return pbs
+}
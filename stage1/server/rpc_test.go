@@ -0,0 +1,163 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package server
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"testing"
+	"time"
+
+	pb "github.com/apcera/kurma/stage1/client"
+	"github.com/apcera/kurma/stage1/container"
+	"github.com/apcera/kurma/stage1/image"
+	"github.com/apcera/logray"
+	"google.golang.org/grpc"
+
+	. "github.com/apcera/util/testtool"
+)
+
+const (
+	testManifest = `{"acKind":"ImageManifest","acVersion":"0.7.1","name":"example.com/test",` +
+		`"app":{"exec":["/bin/true"],"user":"0","group":"0"}}`
+	otherManifest = `{"acKind":"ImageManifest","acVersion":"0.7.1","name":"example.com/test",` +
+		`"app":{"exec":["/bin/sh","-c","evil"],"user":"0","group":"0"}}`
+)
+
+// buildACI returns an image with the given manifest and an empty root
+// filesystem.
+func buildACI(t *testing.T, manifest string) []byte {
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gw)
+	headers := []*tar.Header{
+		{Name: "manifest", Mode: 0644, Size: int64(len(manifest)), ModTime: time.Now()},
+		{Name: "rootfs/", Mode: 0755, Typeflag: tar.TypeDir, ModTime: time.Now()},
+	}
+	for _, h := range headers {
+		TestExpectSuccess(t, tw.WriteHeader(h))
+		if h.Name == "manifest" {
+			_, err := tw.Write([]byte(manifest))
+			TestExpectSuccess(t, err)
+		}
+	}
+	TestExpectSuccess(t, tw.Close())
+	TestExpectSuccess(t, gw.Close())
+	return buf.Bytes()
+}
+
+func newTestServer(t *testing.T) *rpcServer {
+	imageManager, err := image.NewManager(&image.Options{Directory: TempDir(t)})
+	TestExpectSuccess(t, err)
+	return &rpcServer{
+		log:            logray.New(),
+		manager:        &container.Manager{},
+		imageManager:   imageManager,
+		metrics:        newMetrics(nil),
+		pendingUploads: make(map[string]*pendingUpload),
+	}
+}
+
+// uploadStream is an image upload which sends the given data in one chunk.
+type uploadStream struct {
+	grpc.ServerStream
+	chunks []*pb.ByteChunk
+}
+
+func (u *uploadStream) Recv() (*pb.ByteChunk, error) {
+	if len(u.chunks) == 0 {
+		return nil, io.EOF
+	}
+	chunk := u.chunks[0]
+	u.chunks = u.chunks[1:]
+	return chunk, nil
+}
+
+func (u *uploadStream) SendAndClose(*pb.None) error {
+	return nil
+}
+
+func TestCreate_StoredImageManifestMismatch(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	s := newTestServer(t)
+	hash, _, err := s.imageManager.CreateImage(bytes.NewReader(buildACI(t, testManifest)), nil)
+	TestExpectSuccess(t, err)
+
+	_, err = s.Create(nil, &pb.CreateRequest{
+		Name:     "test",
+		Manifest: []byte(otherManifest),
+		ImageId:  hash,
+	})
+	if err == nil {
+		Fatalf(t, "Expected an error for a manifest which differs from the stored image")
+	}
+}
+
+func TestUploadImage_ManifestMismatch(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	s := newTestServer(t)
+	resp, err := s.Create(nil, &pb.CreateRequest{
+		Name:     "test",
+		Manifest: []byte(otherManifest),
+	})
+	TestExpectSuccess(t, err)
+	if resp.ImageUploadId == "" {
+		Fatalf(t, "Expected the image to need uploading")
+	}
+
+	stream := &uploadStream{chunks: []*pb.ByteChunk{
+		{StreamId: resp.ImageUploadId, Bytes: buildACI(t, testManifest)},
+	}}
+	if err := s.UploadImage(stream); err == nil {
+		Fatalf(t, "Expected an error for a manifest which differs from the uploaded image")
+	}
+}
+
+func TestUploadImage_FailureReleasesContainer(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+
+	// The first app's image is stored, while the other two need uploading.
+	s := newTestServer(t)
+	hash, _, err := s.imageManager.CreateImage(bytes.NewReader(buildACI(t, testManifest)), nil)
+	TestExpectSuccess(t, err)
+	resp, err := s.Create(nil, &pb.CreateRequest{
+		Name:     "stored",
+		Manifest: []byte(testManifest),
+		ImageId:  hash,
+		Apps: []*pb.AppRequest{
+			{Name: "failed", Manifest: []byte(otherManifest)},
+			{Name: "pending", Manifest: []byte(testManifest)},
+		},
+	})
+	TestExpectSuccess(t, err)
+	TestEqual(t, len(resp.AppUploadIds), 2)
+	pc := s.pendingUploads[resp.AppUploadIds[0]].container
+
+	stream := &uploadStream{chunks: []*pb.ByteChunk{
+		{StreamId: resp.AppUploadIds[0], Bytes: buildACI(t, testManifest)},
+	}}
+	if err := s.UploadImage(stream); err == nil {
+		Fatalf(t, "Expected an error for a manifest which differs from the uploaded image")
+	}
+
+	// The stored image is closed and the other app's upload is no longer
+	// expected.
+	if _, err := pc.apps[0].Image.(*os.File).Stat(); err == nil {
+		Fatalf(t, "Expected the stored image to be closed")
+	}
+	TestEqual(t, len(s.pendingUploads), 0)
+	stream = &uploadStream{chunks: []*pb.ByteChunk{
+		{StreamId: resp.AppUploadIds[1], Bytes: buildACI(t, testManifest)},
+	}}
+	if err := s.UploadImage(stream); err == nil {
+		Fatalf(t, "Expected an error for an upload of an abandoned container")
+	}
+}
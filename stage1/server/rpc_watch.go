@@ -0,0 +1,43 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package server
+
+import (
+	"fmt"
+
+	pb "github.com/apcera/kurma/stage1/client"
+)
+
+func (s *rpcServer) Watch(in *pb.ContainerRequest, stream pb.Kurma_WatchServer) error {
+	s.log.Debugf("Received watch request for %q", in.Uuid)
+
+	// if a specific container was requested, ensure it exists
+	if in.Uuid != "" && s.manager.Container(in.Uuid) == nil {
+		return fmt.Errorf("specified container not found")
+	}
+
+	events := s.manager.Subscribe()
+	defer s.manager.Unsubscribe(events)
+
+	for {
+		select {
+		case event := <-events:
+			if in.Uuid != "" && event.UUID != in.Uuid {
+				continue
+			}
+			err := stream.Send(&pb.ContainerEvent{
+				Uuid:      event.UUID,
+				State:     pbState(event.State),
+				Timestamp: event.Time.UnixNano(),
+				Reason:    event.Reason,
+				Oom:       event.OOM,
+			})
+			if err != nil {
+				return err
+			}
+		case <-stream.Context().Done():
+			s.log.Debugf("Watch request for %q finished", in.Uuid)
+			return nil
+		}
+	}
+}
@@ -3,10 +3,12 @@
 package server
 
 import (
+	"fmt"
 	"net"
 
 	pb "github.com/apcera/kurma/stage1/client"
 	"github.com/apcera/kurma/stage1/container"
+	"github.com/apcera/kurma/stage1/image"
 	"github.com/apcera/logray"
 	"google.golang.org/grpc"
 )
@@ -17,6 +19,12 @@ type Options struct {
 	ParentCgroupName   string
 	ContainerDirectory string
 	ContainerManager   *container.Manager
+	StorageDriver      string
+	StorageDirectory   string
+	MetadataPort       int
+	MetricsAddress     string
+	ImageDirectory     string
+	ImageManager       *image.Manager
 }
 
 // Server represents the process that acts as a daemon to receive container
@@ -48,7 +56,7 @@ func (s *Server) Start() error {
 	// create the RPC handler
 	rpc := &rpcServer{
 		log:            s.log.Clone(),
-		pendingUploads: make(map[string]*pendingContainer),
+		pendingUploads: make(map[string]*pendingUpload),
 	}
 
 	// check if we were given an existing manager
@@ -62,9 +70,33 @@ func (s *Server) Start() error {
 		}
 	}
 
+	// check if we were given an existing image manager
+	if s.options.ImageManager != nil {
+		rpc.imageManager = s.options.ImageManager
+	} else {
+		rpc.imageManager, err = s.initializeImageManager()
+		if err != nil {
+			return err
+		}
+	}
+	if rpc.manager.ImageManager == nil {
+		rpc.manager.ImageManager = rpc.imageManager
+	}
+
+	// collect the server's metrics, serving them if an address is configured.
+	// Failing to serve them isn't fatal to the server.
+	rpc.metrics = newMetrics(rpc.manager)
+	if s.options.MetricsAddress != "" {
+		if err := rpc.metrics.listen(s.options.MetricsAddress); err != nil {
+			s.log.Errorf("Failed to serve metrics: %v", err)
+		} else {
+			go rpc.metrics.trackStartups()
+		}
+	}
+
 	// create the gRPC server and run
 	gs := grpc.NewServer()
-	pb.RegisterKurmaServer(gs, rpc)
+	pb.RegisterInstrumentedKurmaServer(gs, rpc, rpc.metrics.observeRPC)
 	s.log.Debug("Server is ready")
 	gs.Serve(l)
 	return nil
@@ -76,6 +108,9 @@ func (s *Server) initializeManager() (*container.Manager, error) {
 	mopts := &container.Options{
 		ParentCgroupName:   s.options.ParentCgroupName,
 		ContainerDirectory: s.options.ContainerDirectory,
+		StorageDriver:      s.options.StorageDriver,
+		StorageDirectory:   s.options.StorageDirectory,
+		MetadataPort:       s.options.MetadataPort,
 	}
 
 	m, err := container.NewManager(mopts)
@@ -83,5 +118,25 @@ func (s *Server) initializeManager() (*container.Manager, error) {
 		return nil, err
 	}
 	m.Log = s.log.Clone()
+
+	// recover any containers from a previous run
+	if err := m.Recover(); err != nil {
+		return nil, fmt.Errorf("failed to recover existing containers: %v", err)
+	}
+	return m, nil
+}
+
+// initializeImageManager creates the image manager which will handle storing
+// the images used to launch containers.
+func (s *Server) initializeImageManager() (*image.Manager, error) {
+	iopts := &image.Options{
+		Directory: s.options.ImageDirectory,
+	}
+
+	m, err := image.NewManager(iopts)
+	if err != nil {
+		return nil, err
+	}
+	m.Log = s.log.Clone()
 	return m, nil
 }
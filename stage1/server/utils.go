@@ -3,13 +3,18 @@
 package server
 
 import (
+	"syscall"
+
 	pb "github.com/apcera/kurma/stage1/client"
 	"github.com/apcera/kurma/stage1/container"
 )
 
 func pbContainer(c *container.Container) (*pb.Container, error) {
 	pbc := &pb.Container{
-		Uuid: c.UUID(),
+		Uuid:         c.UUID(),
+		RestartCount: int32(c.RestartCount()),
+		StartupError: c.StartupError(),
+		OomKills:     int32(c.OOMKills()),
 	}
 
 	// marshal the pod manifest
@@ -20,21 +25,43 @@ func pbContainer(c *container.Container) (*pb.Container, error) {
 	}
 	pbc.Manifest = b
 
+	// add the status of each of the apps. The OOM killer uses SIGKILL, so apps
+	// killed by it once it has been invoked within the container are reported
+	// as having been killed by it.
+	for name, status := range c.AppStatuses() {
+		pbc.AppStatuses = append(pbc.AppStatuses, &pb.AppStatus{
+			Name:      name,
+			Running:   status.Running,
+			Exited:    status.Exited,
+			ExitCode:  int32(status.ExitCode),
+			Signaled:  status.Signaled,
+			Signal:    int32(status.Signal),
+			OomKilled: pbc.OomKills > 0 && status.Signaled && status.Signal == syscall.SIGKILL,
+		})
+	}
+
 	// map the container state
-	switch c.State() {
-	case container.NEW:
-		pbc.State = pb.Container_NEW
+	pbc.State = pbState(c.State())
+
+	return pbc, nil
+}
+
+// pbState maps the container's state to its protobuf representation.
+func pbState(state container.ContainerState) pb.Container_State {
+	switch state {
 	case container.STARTING:
-		pbc.State = pb.Container_STARTING
+		return pb.Container_STARTING
 	case container.RUNNING:
-		pbc.State = pb.Container_RUNNING
+		return pb.Container_RUNNING
 	case container.STOPPING:
-		pbc.State = pb.Container_STOPPING
+		return pb.Container_STOPPING
 	case container.STOPPED:
-		pbc.State = pb.Container_STOPPED
+		return pb.Container_STOPPED
 	case container.EXITED:
-		pbc.State = pb.Container_EXITED
+		return pb.Container_EXITED
+	case container.FAILED:
+		return pb.Container_FAILED
+	default:
+		return pb.Container_NEW
 	}
-
-	return pbc, nil
 }
@@ -24,12 +24,13 @@ const (
 // process. It allows the logic to be centralized here rather than separated
 // everywhere that might use it.
 type Launcher struct {
-	Directory     string
-	BindDirectory string
-	User          string
-	Group         string
-	Uidmap        string
-	Gidmap        string
+	Directory        string
+	BindDirectory    string
+	WorkingDirectory string
+	User             string
+	Group            string
+	Uidmap           string
+	Gidmap           string
 
 	IPCNamespace     int
 	MountNamespace   int
@@ -55,6 +56,10 @@ type Launcher struct {
 	Environment []string
 	Taskfiles   []string
 
+	// DropCapabilities is the list of capabilities which are dropped from the
+	// bounding set before the command is executed.
+	DropCapabilities []int
+
 	Stdin  *os.File
 	Stdout *os.File
 	Stderr *os.File
@@ -78,10 +83,6 @@ func (l *Launcher) SetNS(pid int) {
 // return the arguments as well as the extra files that need to be passed, such
 // as for its stdin, stdout, and stderr.
 func (l *Launcher) generateArgs(cmdargs []string) ([]string, []*os.File) {
-	// Generate the uid and gid maps for user namespaces
-	// uidmap := fmt.Sprintf("0 %d %d\n", c.manager.namespaceUidOffset, c.manager.namespaceUidMaximum)
-	// gidmap := fmt.Sprintf("0 %d %d\n", c.manager.namespaceGidOffset, c.manager.namespaceGidMaximum)
-
 	// Initialize the options that will be passed to spawn the container.
 	var args []string
 
@@ -95,6 +96,9 @@ func (l *Launcher) generateArgs(cmdargs []string) ([]string, []*os.File) {
 	if l.Chroot {
 		args = append(args, "--chroot")
 	}
+	if l.WorkingDirectory != "" {
+		args = append(args, "--working-directory", l.WorkingDirectory)
+	}
 
 	// Pass the user and group, if they're set
 	if l.User != "" {
@@ -120,11 +124,11 @@ func (l *Launcher) generateArgs(cmdargs []string) ([]string, []*os.File) {
 	if l.UTSNamespace > 0 {
 		args = append(args, "--uts-namespace", nsPath(l.UTSNamespace, "uts"))
 	}
-	// FIXME this needs testing when re-enabling username spaces... without
-	// changing user namespaces, setns on it fails.
-	// if l.UserNamespace > 0 {
-	// 	args = append(args, "--user-namespace", nsPath(l.UserNamespace, "user"))
-	// }
+	// Joining the user namespace the process is already in fails, so it is only
+	// joined when the process has its own user namespace.
+	if l.UserNamespace > 0 && !sameNamespace(l.UserNamespace, "user") {
+		args = append(args, "--user-namespace", nsPath(l.UserNamespace, "user"))
+	}
 
 	// Add applicalble new namespace flags
 	if l.NewIPCNamespace {
@@ -146,18 +150,23 @@ func (l *Launcher) generateArgs(cmdargs []string) ([]string, []*os.File) {
 		args = append(args, "--new-user-namespace")
 	}
 
-	// If user namespaces are to be used, then add the parameter to populate it
-	// and the uid and gid maps.
-	// if l.UserNamespace > 0 || l.NewUserNamespace {
-	// 	args = append(args, "--uidmap", l.Uidmap)
-	// 	args = append(args, "--gidmap", l.Gidmap)
-	// }
+	// If a new user namespace is to be created, then add the uid and gid maps
+	// to populate it with.
+	if l.NewUserNamespace {
+		args = append(args, "--uidmap", l.Uidmap)
+		args = append(args, "--gidmap", l.Gidmap)
+	}
 
 	// Check for a privileged isolator
 	if l.HostPrivileged {
 		args = append(args, "--host-privileged")
 	}
 
+	// Add the capabilities to drop
+	for _, c := range l.DropCapabilities {
+		args = append(args, "--drop-capability", strconv.Itoa(c))
+	}
+
 	// Loop and append all the cgroups taskfiles the container should be in.
 	for _, f := range l.Taskfiles {
 		args = append(args, "--taskfile", f)
@@ -198,7 +207,7 @@ func (l *Launcher) generateArgs(cmdargs []string) ([]string, []*os.File) {
 	extraFiles = append(extraFiles, l.Stdin)
 
 	if l.Stdout != nil {
-		args = append(args, "--stdinfd", fmt.Sprintf("%d", len(extraFiles)+3))
+		args = append(args, "--stdoutfd", fmt.Sprintf("%d", len(extraFiles)+3))
 		extraFiles = append(extraFiles, l.Stdout)
 	}
 	if l.Stderr != nil {
@@ -274,3 +283,17 @@ func (l *Launcher) Run(cmdargs ...string) (*os.Process, error) {
 func nsPath(pid int, kind string) string {
 	return fmt.Sprintf("/proc/%d/ns/%s", pid, kind)
 }
+
+// sameNamespace returns whether the specified process is within the same
+// namespace of the given kind as the current process.
+func sameNamespace(pid int, kind string) bool {
+	self, err := os.Readlink(fmt.Sprintf("/proc/self/ns/%s", kind))
+	if err != nil {
+		return false
+	}
+	other, err := os.Readlink(nsPath(pid, kind))
+	if err != nil {
+		return false
+	}
+	return self == other
+}
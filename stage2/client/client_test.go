@@ -0,0 +1,78 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+// +build linux,cgo
+
+package client_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/apcera/kurma/stage2/client"
+
+	. "github.com/apcera/util/testtool"
+)
+
+// capNetRaw is the number of CAP_NET_RAW, which is dropped in the tests.
+const capNetRaw = 13
+
+func TestLauncher_LimitsAndCapabilities(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+	TestRequiresRoot(t)
+
+	dir := TempDir(t)
+	output := path.Join(dir, "output")
+	f, err := os.Create(output)
+	TestExpectSuccess(t, err)
+	defer f.Close()
+
+	// The limits are always passed along with any capabilities being dropped,
+	// so launch with both and ensure each is applied.
+	launcher := &client.Launcher{
+		MaxOpenFiles:     100,
+		MaxProcesses:     200,
+		DropCapabilities: []int{capNetRaw},
+		Stdout:           f,
+		Stderr:           f,
+	}
+	p, err := launcher.Run("/bin/sh", "-c", "cat /proc/self/limits /proc/self/status")
+	TestExpectSuccess(t, err)
+	state, err := p.Wait()
+	TestExpectSuccess(t, err)
+
+	b, err := ioutil.ReadFile(output)
+	TestExpectSuccess(t, err)
+	if !state.Success() {
+		Fatalf(t, "Launch failed with %s: %s", state, string(b))
+	}
+
+	// Find the soft limits and the bounding set within the output.
+	var openFiles, processes, capBnd string
+	for _, line := range strings.Split(string(b), "\n") {
+		fields := strings.Fields(line)
+		switch {
+		case strings.HasPrefix(line, "Max open files") && len(fields) > 3:
+			openFiles = fields[3]
+		case strings.HasPrefix(line, "Max processes") && len(fields) > 2:
+			processes = fields[2]
+		case strings.HasPrefix(line, "CapBnd:") && len(fields) == 2:
+			capBnd = fields[1]
+		}
+	}
+	TestEqual(t, openFiles, "100")
+	TestEqual(t, processes, "200")
+
+	bounding, err := strconv.ParseUint(capBnd, 16, 64)
+	TestExpectSuccess(t, err)
+	if bounding&(1<<capNetRaw) != 0 {
+		Fatalf(t, "CAP_NET_RAW was not dropped: %x", bounding)
+	}
+	if bounding == 0 {
+		Fatalf(t, "Expected the remaining capabilities to be retained")
+	}
+}
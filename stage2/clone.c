@@ -8,6 +8,7 @@
 #define FILENAMESIZE 4096
 
 #include <errno.h>
+#include <grp.h>
 #include <stdlib.h>
 #include <string.h>
 #include <unistd.h>
@@ -78,6 +79,14 @@ static void setup_container(clone_destination_data *args, pid_t uidmap_child) {
 	joinnamespace(args->pidnamespace);
 	joinnamespace(args->mountnamespace);
 
+	// When joining an existing user namespace, become root within it. The
+	// host's root isn't mapped within the namespace, so otherwise the process
+	// would be left without a valid uid and gid.
+	if (args->usernamespace != NULL) {
+		if (setgid(0) < 0 || setgroups(0, NULL) < 0 || setuid(0) < 0)
+			error(1, errno, "Failed to get root within the user namespace");
+	}
+
 	// --------------------------------------------------------------------
 	// Step 6: Drop privledges to just the current user.
 	// --------------------------------------------------------------------
@@ -164,6 +173,11 @@ static void setup_container(clone_destination_data *args, pid_t uidmap_child) {
 			enterroot(args->privileged);
 		}
 
+		// Drop any capabilities from the bounding set that the process isn't
+		// permitted to have.
+		DEBUG("Dropping capabilities\n");
+		dropcapabilities(args->drop_capabilities);
+
 		// --------------------------------------------------------------------
 		// Step 11: Drop privledges down to the specified user
 		// --------------------------------------------------------------------
@@ -190,6 +204,13 @@ static void setup_container(clone_destination_data *args, pid_t uidmap_child) {
 			close(pipe_fd[1]);
 		}
 
+		// Move into the working directory, now that the process is within its
+		// root filesystem and running as the specified user.
+		if (args->working_directory != NULL) {
+			if (chdir(args->working_directory) < 0)
+				error(1, errno, "Failed to change to the working directory");
+		}
+
 		// --------------------------------------------------------------------
 		// Step 12: Remove all existing environment variables.
 		// --------------------------------------------------------------------
@@ -11,9 +11,12 @@
 #include <errno.h>
 #include <fcntl.h>
 #include <sched.h>
+#include <stdlib.h>
 #include <sysexits.h>
 #include <unistd.h>
 
+#include <sys/prctl.h>
+
 #include "spawner.h"
 
 // Calls dup2() on stdin, stdout, and stderr so they use the file descriptor
@@ -128,9 +131,22 @@ void joinnamespace(char *filename) {
 		error(1, errno, "Failed to close");
 }
 
+// Drops each of the capabilities in the NULL terminated list from the bounding
+// set, so they can't be gained by the process once it execs.
+void dropcapabilities(char *capabilities[]) {
+	int i;
+
+	if (capabilities == NULL) { return; }
+
+	for (i = 0; capabilities[i] != NULL; i++) {
+		if (prctl(PR_CAPBSET_DROP, atoi(capabilities[i]), 0, 0, 0) != 0)
+			error(1, errno, "Failed to drop capability %s", capabilities[i]);
+	}
+}
+
 // Returns the flags that should be used for the outermost clone.
 int flags_for_clone(clone_destination_data *args) {
-	int flags;
+	int flags = 0;
 
 	// If the caller wants a new namespace we set it up here.
 	if (args->new_ipc_namespace) { flags |= CLONE_NEWIPC; }
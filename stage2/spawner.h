@@ -95,10 +95,17 @@ typedef struct clone_destination_data {
 	// The directory where the container's filesystem should be bind mounted to
 	char *bind_directory;
 
+	// The directory the command is executed within
+	char *working_directory;
+
 	// The user and to run the stage3 process as
 	char *user;
 	char *group;
 
+	// The capabilities to drop from the bounding set before execing. This is a
+	// NULL terminated array of capability numbers.
+	char **drop_capabilities;
+
 	// True if this process should double fork in order to become a child of
 	// spanwer rather than the calling process.
 	bool detach;
@@ -113,6 +120,7 @@ void dup_filedescriptors(int stdinfd, int stdoutfd, int stderrfd);
 void closefds();
 void joincgroups(char *tasksfiles[]);
 void joinnamespace(char *filename);
+void dropcapabilities(char *capabilities[]);
 int flags_for_clone(clone_destination_data *args);
 
 // util.c
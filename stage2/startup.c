@@ -60,6 +60,10 @@ void cspawner(int argc, char **argv) {
 	args->tasksfiles = NULL;
 	size_t tasksfiles_len = 0;
 
+	// capabilities to drop from the bounding set
+	args->drop_capabilities = NULL;
+	size_t drop_capabilities_len = 0;
+
 	// initialize the fd args to -1 so we know when they weren't specified
 	args->stdinfd = -1;
 	args->stdoutfd = -1;
@@ -101,6 +105,10 @@ void cspawner(int argc, char **argv) {
 				{"max-open-files", required_argument, 0, 'r'},
 				{"max-processes", required_argument, 0, 's'},
 
+				{"drop-capability", required_argument, 0, 't'},
+
+				{"working-directory", required_argument, 0, 'u'},
+
 				{"detach", no_argument, &detach, 1},
 				{"chroot", no_argument, &chroot, 1},
 				{"host-privileged", no_argument, &privileged, 1},
@@ -109,7 +117,7 @@ void cspawner(int argc, char **argv) {
 		/* getopt_long stores the option index here. */
 		int option_index = 0;
 
-		c = getopt_long(argc, argv, "abcdefghijklmnopqrs", long_options, &option_index);
+		c = getopt_long(argc, argv, "abcdefghijklmnopqrstu", long_options, &option_index);
 
 		/* Detect the end of the options. */
 		if (c == -1)
@@ -199,9 +207,24 @@ void cspawner(int argc, char **argv) {
 
 			// limits
 		case 'r':
-		  args->max_open_files = atoi(optarg);
+			args->max_open_files = atoi(optarg);
+			break;
 		case 's':
-		  args->max_processes = atoi(optarg);
+			args->max_processes = atoi(optarg);
+			break;
+
+			// capabilities
+		case 't':
+			args->drop_capabilities = realloc(args->drop_capabilities, sizeof(char*) * (drop_capabilities_len+1));
+			if (!args->drop_capabilities) { error(1, 0, "drop_capabilities was null"); }
+			args->drop_capabilities[drop_capabilities_len] = optarg;
+			drop_capabilities_len++;
+			break;
+
+			// working directory
+		case 'u':
+			args->working_directory = optarg;
+			break;
 
 		case '?':
 			/* getopt_long already printed an error message. */
@@ -228,6 +251,8 @@ void cspawner(int argc, char **argv) {
 	args->environment[env_len] = NULL;
 	args->tasksfiles = realloc(args->tasksfiles, sizeof(char*) * (tasksfiles_len+1));
 	args->tasksfiles[tasksfiles_len] = NULL;
+	args->drop_capabilities = realloc(args->drop_capabilities, sizeof(char*) * (drop_capabilities_len+1));
+	args->drop_capabilities[drop_capabilities_len] = NULL;
 
 	// populate the command args
 	args->command = argv[optind];
@@ -92,6 +92,10 @@ void waitforexit(pid_t child) {
 
 	if (waitpid(child, &status, 0) < 0)
 		error(1, errno, "waitpid");
+	else if (WIFSIGNALED(status))
+		// Follow the shell convention for reporting a process killed by a
+		// signal.
+		exit(128 + WTERMSIG(status));
 	else if (WEXITSTATUS(status) != EXIT_SUCCESS)
 		exit(WEXITSTATUS(status));
 }
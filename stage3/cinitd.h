@@ -132,6 +132,10 @@ void initd_status_request(struct request *r);
 // to "WAIT".
 void initd_wait_request(struct request *r);
 
+// This is called once a request object is found that has a COMMAND element set
+// to "SIGNAL".
+void initd_signal_request(struct request *r);
+
 // ------------------
 // Response handlers.
 // ------------------
@@ -279,6 +283,16 @@ void server_print_time(FILE *fd);
 // separation from the root mount namespace.
 int pivot_root(char *root, bool privileged);
 
+// Enters the root filesystem of an app within the pod. This creates a new mount
+// namespace for the calling process, binds the pod's /dev, /proc, and /sys into
+// the root, and then uses pivot_root to enter it.
+int enter_app_root(char *root);
+
+// Drops the capabilities in the comma separated list of capability numbers
+// from the bounding set of the calling process. This is used on the apps
+// before they're executed, since the initd itself needs to retain them.
+int drop_capabilities(char *capabilities);
+
 // -------
 // Logging
 // -------
@@ -9,8 +9,10 @@ import (
 	"io/ioutil"
 	"net"
 	"os"
+	"strconv"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 )
 
@@ -29,14 +31,18 @@ type Client interface {
 		command []string, env []string, stdout string, stderr string, timeout time.Duration,
 	) error
 
-	// Starts a given named command within the initd server.
+	// Starts a given named command within the initd server. If root is given,
+	// the command will be run with the directory as its root filesystem.
 	Start(
 		name string, command []string, workingDirectory string, env []string,
-		stdout string, stderr, user, group string, timeout time.Duration,
+		stdout string, stderr, user, group, root string, timeout time.Duration,
 	) error
 
+	// Sends the signal to the named command if it is still running.
+	Signal(name string, signal syscall.Signal, timeout time.Duration) error
+
 	// Returns the status of all named commands in the container.
-	Status(timeout time.Duration) (map[string]string, error)
+	Status(timeout time.Duration) (map[string]*ProcessStatus, error)
 
 	// Stops the client, disconnecting all requests and preventing further
 	// requests from succeeding.
@@ -320,7 +326,7 @@ func (c *client) Exec(
 // Issues a request to start a new command.
 func (c *client) Start(
 	name string, command []string, workingDirectory string, env []string, stdout string, stderr,
-	user, group string, timeout time.Duration,
+	user, group, root string, timeout time.Duration,
 ) error {
 	request := [][]string{
 		[]string{"START", name},
@@ -330,6 +336,9 @@ func (c *client) Start(
 		[]string{stdout, stderr},
 		[]string{user, group},
 	}
+	if root != "" {
+		request = append(request, []string{root})
+	}
 
 	// Make the request.
 	response, err := c.request(request, timeout)
@@ -346,8 +355,26 @@ func (c *client) Start(
 	return nil
 }
 
+// Sends a signal to a named process in the container.
+func (c *client) Signal(name string, signal syscall.Signal, timeout time.Duration) error {
+	// Make the request.
+	request := [][]string{[]string{"SIGNAL", name, strconv.Itoa(int(signal))}}
+	response, err := c.request(request, timeout)
+	if err != nil {
+		return err
+	}
+
+	// We expect two lines, ["REQUEST OK", ""]
+	if len(response) != 2 || response[0] != "REQUEST OK" || response[1] != "" {
+		return fmt.Errorf("Invalid response: %#v", response)
+	}
+
+	// Success!
+	return nil
+}
+
 // Get the status of all named processes in the container.
-func (c *client) Status(timeout time.Duration) (map[string]string, error) {
+func (c *client) Status(timeout time.Duration) (map[string]*ProcessStatus, error) {
 	// Make the request.
 	request := [][]string{[]string{"STATUS"}}
 	response, err := c.request(request, timeout)
@@ -371,10 +398,21 @@ func (c *client) Status(timeout time.Duration) (map[string]string, error) {
 		return nil, fmt.Errorf("Invalid response: %#v", response)
 	}
 
-	// Walk the lines containing name/status pairs.
-	results := make(map[string]string, len(response))
+	// Walk the lines containing name/status pairs. The initd reuses the entry
+	// of a terminated process when its name is started again, but a name
+	// started while its previous process is still running is listed twice. The
+	// most recently started process is listed first, so only that entry is
+	// kept.
+	results := make(map[string]*ProcessStatus, len(response))
 	for i := 1; i < len(response)-2; i += 2 {
-		results[response[i]] = response[i+1]
+		if _, exists := results[response[i]]; exists {
+			continue
+		}
+		status, err := ParseProcessStatus(response[i+1])
+		if err != nil {
+			return nil, err
+		}
+		results[response[i]] = status
 	}
 
 	// Success!
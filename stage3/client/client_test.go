@@ -7,6 +7,7 @@ import (
 	"net"
 	"os"
 	"strings"
+	"syscall"
 	"testing"
 	"time"
 
@@ -213,7 +214,7 @@ func TestClient_Start(t *testing.T) {
 	client := New(socketFile)
 	err := client.Start(
 		"echo", []string{"123"}, "dir", []string{"FOO=bar"},
-		"/a", "/b", "123", "456", time.Second,
+		"/a", "/b", "123", "456", "", time.Second,
 	)
 	tt.TestExpectSuccess(t, err)
 
@@ -227,6 +228,33 @@ func TestClient_Start(t *testing.T) {
 	tt.TestEqual(t, startContent, expectedRequest)
 }
 
+func TestClient_StartWithRoot(t *testing.T) {
+	tt.StartTest(t)
+	defer tt.FinishTest(t)
+
+	socketFile, l := createSocketServer(t)
+	defer l.Close()
+
+	var startContent string
+	readChan := setupReadRequest(t, l, &startContent, "REQUEST OK\n")
+
+	client := New(socketFile)
+	err := client.Start(
+		"echo", []string{"123"}, "dir", []string{"FOO=bar"},
+		"/a", "/b", "123", "456", "/apps/echo", time.Second,
+	)
+	tt.TestExpectSuccess(t, err)
+
+	select {
+	case <-readChan:
+	case <-time.After(time.Second):
+		tt.Fatalf(t, "Expected to have read client response within 1 second")
+	}
+
+	expectedRequest := "1\n7\n2\n5\nSTART4\necho1\n3\n1231\n3\ndir1\n7\nFOO=bar2\n2\n/a2\n/b2\n3\n1233\n4561\n10\n/apps/echo"
+	tt.TestEqual(t, startContent, expectedRequest)
+}
+
 func TestClient_Status(t *testing.T) {
 	tt.StartTest(t)
 	defer tt.FinishTest(t)
@@ -235,7 +263,35 @@ func TestClient_Status(t *testing.T) {
 	defer l.Close()
 
 	var statusContent string
-	readChan := setupReadRequest(t, l, &statusContent, "REQUEST OK\nfoo\nrunning\nbar\nexit(1)\nend\n")
+	readChan := setupReadRequest(t, l, &statusContent, "REQUEST OK\nfoo\nrunning\nbar\nexited(1)\nbaz\nsignaled(9)\nend\n")
+
+	client := New(socketFile)
+	status, err := client.Status(time.Second)
+	tt.TestExpectSuccess(t, err)
+
+	select {
+	case <-readChan:
+	case <-time.After(time.Second):
+		tt.Fatalf(t, "Expected to have read client response within 1 second")
+	}
+
+	expectedStatus := map[string]*ProcessStatus{
+		"foo": &ProcessStatus{Running: true},
+		"bar": &ProcessStatus{Exited: true, ExitCode: 1},
+		"baz": &ProcessStatus{Signaled: true, Signal: syscall.SIGKILL},
+	}
+	tt.TestEqual(t, status, expectedStatus)
+}
+
+func TestClient_StatusRestartedProcess(t *testing.T) {
+	tt.StartTest(t)
+	defer tt.FinishTest(t)
+
+	socketFile, l := createSocketServer(t)
+	defer l.Close()
+
+	var statusContent string
+	readChan := setupReadRequest(t, l, &statusContent, "REQUEST OK\nfoo\nrunning\nfoo\nexited(1)\nend\n")
 
 	client := New(socketFile)
 	status, err := client.Status(time.Second)
@@ -247,10 +303,34 @@ func TestClient_Status(t *testing.T) {
 		tt.Fatalf(t, "Expected to have read client response within 1 second")
 	}
 
-	expectedStatus := map[string]string{"foo": "running", "bar": "exit(1)"}
+	expectedStatus := map[string]*ProcessStatus{"foo": &ProcessStatus{Running: true}}
 	tt.TestEqual(t, status, expectedStatus)
 }
 
+func TestClient_Signal(t *testing.T) {
+	tt.StartTest(t)
+	defer tt.FinishTest(t)
+
+	socketFile, l := createSocketServer(t)
+	defer l.Close()
+
+	var signalContent string
+	readChan := setupReadRequest(t, l, &signalContent, "REQUEST OK\n")
+
+	client := New(socketFile)
+	err := client.Signal("foo", syscall.SIGTERM, time.Second)
+	tt.TestExpectSuccess(t, err)
+
+	select {
+	case <-readChan:
+	case <-time.After(time.Second):
+		tt.Fatalf(t, "Expected to have read client response within 1 second")
+	}
+
+	expectedRequest := "1\n1\n3\n6\nSIGNAL3\nfoo2\n15"
+	tt.TestEqual(t, signalContent, expectedRequest)
+}
+
 func TestClient_Wait(t *testing.T) {
 	tt.StartTest(t)
 	defer tt.FinishTest(t)
@@ -5,6 +5,8 @@ package client
 import (
 	"fmt"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	kclient "github.com/apcera/kurma/stage2/client"
@@ -39,6 +41,11 @@ type Launcher struct {
 	HostPrivileged bool
 	Chroot         bool
 
+	// DropCapabilities is the list of capabilities dropped from the bounding set
+	// of the apps started by the init process. The init process itself retains
+	// them, since it needs them to set up the apps.
+	DropCapabilities []int
+
 	Cgroup *cgroups.Cgroup
 
 	Stdin  *os.File
@@ -75,6 +82,14 @@ func (l *Launcher) Run() (Client, error) {
 			fmt.Sprintf("INITD_SOCKET=%s", l.SocketPath),
 		},
 	}
+	if len(l.DropCapabilities) > 0 {
+		caps := make([]string, len(l.DropCapabilities))
+		for i, c := range l.DropCapabilities {
+			caps[i] = strconv.Itoa(c)
+		}
+		launcher.Environment = append(launcher.Environment,
+			fmt.Sprintf("INITD_DROP_CAPABILITIES=%s", strings.Join(caps, ",")))
+	}
 
 	// get the executable path to ourself
 	self, err := os.Readlink("/proc/self/exe")
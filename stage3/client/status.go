@@ -0,0 +1,67 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package client
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// ProcessStatus is the state of a named process within the initd, as reported
+// by a STATUS request. A process which is no longer running will have either
+// exited with an exit code, or have been terminated by a signal.
+type ProcessStatus struct {
+	Running  bool           `json:"running"`
+	Exited   bool           `json:"exited"`
+	ExitCode int            `json:"exit_code"`
+	Signaled bool           `json:"signaled"`
+	Signal   syscall.Signal `json:"signal"`
+}
+
+// ParseProcessStatus converts the status string returned by the initd into a
+// ProcessStatus. The initd returns one of "running", "exited(<CODE>)",
+// "signaled(<SIGNAL>)", or "unknown".
+func ParseProcessStatus(status string) (*ProcessStatus, error) {
+	switch {
+	case status == "running":
+		return &ProcessStatus{Running: true}, nil
+	case status == "unknown":
+		return &ProcessStatus{}, nil
+	case strings.HasPrefix(status, "exited(") && strings.HasSuffix(status, ")"):
+		code, err := strconv.Atoi(status[len("exited(") : len(status)-1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid exit code in status %q", status)
+		}
+		return &ProcessStatus{Exited: true, ExitCode: code}, nil
+	case strings.HasPrefix(status, "signaled(") && strings.HasSuffix(status, ")"):
+		sig, err := strconv.Atoi(status[len("signaled(") : len(status)-1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid signal in status %q", status)
+		}
+		return &ProcessStatus{Signaled: true, Signal: syscall.Signal(sig)}, nil
+	default:
+		return nil, fmt.Errorf("unrecognized status %q", status)
+	}
+}
+
+// Failed returns true if the process is no longer running and did not exit
+// cleanly with an exit code of 0.
+func (s *ProcessStatus) Failed() bool {
+	return !s.Running && !(s.Exited && s.ExitCode == 0)
+}
+
+// String returns the status in the same format used by the initd.
+func (s *ProcessStatus) String() string {
+	switch {
+	case s.Running:
+		return "running"
+	case s.Exited:
+		return fmt.Sprintf("exited(%d)", s.ExitCode)
+	case s.Signaled:
+		return fmt.Sprintf("signaled(%d)", int(s.Signal))
+	default:
+		return "unknown"
+	}
+}
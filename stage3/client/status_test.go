@@ -0,0 +1,55 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+package client
+
+import (
+	"syscall"
+	"testing"
+
+	tt "github.com/apcera/util/testtool"
+)
+
+func TestParseProcessStatus(t *testing.T) {
+	tt.StartTest(t)
+	defer tt.FinishTest(t)
+
+	status, err := ParseProcessStatus("running")
+	tt.TestExpectSuccess(t, err)
+	tt.TestEqual(t, status, &ProcessStatus{Running: true})
+	tt.TestEqual(t, status.Failed(), false)
+
+	status, err = ParseProcessStatus("exited(0)")
+	tt.TestExpectSuccess(t, err)
+	tt.TestEqual(t, status, &ProcessStatus{Exited: true, ExitCode: 0})
+	tt.TestEqual(t, status.Failed(), false)
+
+	status, err = ParseProcessStatus("exited(3)")
+	tt.TestExpectSuccess(t, err)
+	tt.TestEqual(t, status, &ProcessStatus{Exited: true, ExitCode: 3})
+	tt.TestEqual(t, status.Failed(), true)
+
+	status, err = ParseProcessStatus("signaled(15)")
+	tt.TestExpectSuccess(t, err)
+	tt.TestEqual(t, status, &ProcessStatus{Signaled: true, Signal: syscall.SIGTERM})
+	tt.TestEqual(t, status.Failed(), true)
+
+	status, err = ParseProcessStatus("unknown")
+	tt.TestExpectSuccess(t, err)
+	tt.TestEqual(t, status.Failed(), true)
+
+	_, err = ParseProcessStatus("exited(abc)")
+	tt.TestExpectError(t, err)
+	_, err = ParseProcessStatus("bogus")
+	tt.TestExpectError(t, err)
+}
+
+func TestProcessStatus_String(t *testing.T) {
+	tt.StartTest(t)
+	defer tt.FinishTest(t)
+
+	for _, s := range []string{"running", "exited(0)", "exited(42)", "signaled(9)", "unknown"} {
+		status, err := ParseProcessStatus(s)
+		tt.TestExpectSuccess(t, err)
+		tt.TestEqual(t, status.String(), s)
+	}
+}
@@ -3,6 +3,8 @@
 #ifndef INITD_SERVER_HELPERS_C
 #define INITD_SERVER_HELPERS_C
 
+#define _GNU_SOURCE
+
 #include <dirent.h>
 #include <errno.h>
 #include <fcntl.h>
@@ -10,12 +12,15 @@
 #include <limits.h>
 #include <paths.h>
 #include <pwd.h>
+#include <sched.h>
 #include <stdbool.h>
+#include <stdio.h>
 #include <stdlib.h>
 #include <string.h>
 #include <sysexits.h>
 
 #include <sys/mount.h>
+#include <sys/prctl.h>
 #include <sys/stat.h>
 #include <sys/syscall.h>
 #include <sys/types.h>
@@ -221,6 +226,60 @@ int pivot_root(char *root, bool privileged) {
 	return 0;
 }
 
+// Documented in cinitd.h
+int enter_app_root(char *root) {
+	char path[PATH_MAX];
+	char *mounts[] = { "/dev", "/proc", "/sys", NULL };
+	int i;
+
+	// Move into a private mount namespace so the mounts made for the app are
+	// released along with the process.
+	if (unshare(CLONE_NEWNS) < 0)
+		return -1;
+	if (mount(NULL, "/", NULL, MS_REC | MS_PRIVATE, NULL) < 0)
+		return -1;
+
+	// pivot_root requires the new root to be a mount point.
+	if (mount(root, root, NULL, MS_BIND | MS_REC, NULL) < 0)
+		return -1;
+
+	// Share the pod's /dev, /proc and /sys with the app.
+	for (i = 0; mounts[i] != NULL; i++) {
+		if (snprintf(path, sizeof(path), "%s%s", root, mounts[i]) >= sizeof(path)) {
+			errno = ENAMETOOLONG;
+			return -1;
+		}
+		mkdir(path, 0755);
+		if (mount(mounts[i], path, NULL, MS_BIND | MS_REC, NULL) < 0)
+			return -1;
+	}
+
+	return pivot_root(root, false);
+}
+
+// Documented in cinitd.h
+int drop_capabilities(char *capabilities) {
+	char *list, *cap, *saveptr;
+	int ret = 0;
+
+	if (capabilities == NULL || capabilities[0] == '\0')
+		return 0;
+
+	list = strdup(capabilities);
+	if (list == NULL)
+		return -1;
+
+	for (cap = strtok_r(list, ",", &saveptr); cap != NULL; cap = strtok_r(NULL, ",", &saveptr)) {
+		if (prctl(PR_CAPBSET_DROP, atoi(cap), 0, 0, 0) != 0) {
+			ret = -1;
+			break;
+		}
+	}
+
+	free(list);
+	return ret;
+}
+
 int uidforuser2(char *user) {
 	// First, look up the /etc/passwd entry.
 	struct passwd *pwd;
@@ -0,0 +1,72 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+#ifndef INITD_SERVER_SIGNAL_REQUEST_C
+#define INITD_SERVER_SIGNAL_REQUEST_C
+
+#include <errno.h>
+#include <signal.h>
+#include <stdlib.h>
+#include <string.h>
+
+#include <sys/types.h>
+
+#include "cinitd.h"
+
+// Documented in cinitd.h
+void signal_request(struct request *r)
+{
+	struct process *p;
+	char *end;
+	long sig;
+
+	// The expected protocol for a SIGNAL statement looks like this:
+	// {
+	//   { "SIGNAL", "<NAME>", "<SIGNAL>" },
+	// }
+	//
+	// The signal is sent to the named process if it is still running. A name
+	// whose process has already terminated is not an error, since the caller
+	// can't know whether it exited before the request was processed.
+
+	INFO("[%d] SIGNAL request.\n", r->fd);
+
+	// Protocol error conditions.
+	if (
+			(r->outer_len != 1) ||
+			// SIGNAL/NAME/SIGNAL
+			(r->data[0][1] == NULL) ||
+			(r->data[0][2] == NULL) ||
+			(r->data[0][3] != NULL) ||
+			// END
+			(r->data[1] != NULL))
+	{
+		ERROR("[%d] Protocol error.\n", r->fd);
+		initd_response_protocol_error(r);
+		return;
+	}
+
+	errno = 0;
+	sig = strtol(r->data[0][2], &end, 10);
+	if (errno != 0 || *end != '\0' || end == r->data[0][2] || sig <= 0 || sig >= NSIG) {
+		ERROR("[%d] Invalid signal: %s\n", r->fd, r->data[0][2]);
+		initd_response_protocol_error(r);
+		return;
+	}
+
+	for (p = process_head; p != NULL; p = p->next) {
+		if (p->terminated || strcmp(p->name, r->data[0][1]) != 0) {
+			continue;
+		}
+		if (kill(p->pid, (int) sig) != 0 && errno != ESRCH) {
+			ERROR("[%d] Error in kill(%d, %ld): %s\n", r->fd, p->pid, sig, strerror(errno));
+			initd_response_internal_error(r);
+			return;
+		}
+	}
+
+	// Success. Inform the caller.
+	INFO("[%d] Successful signal.\n", r->fd);
+	initd_response_request_ok(r);
+}
+
+#endif
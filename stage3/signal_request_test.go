@@ -0,0 +1,99 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+// +build linux,cgo
+
+package stage3_test
+
+import (
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/apcera/util/testtool"
+)
+
+func TestSignalRequest(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+	TestRequiresRoot(t)
+
+	// Start the initd process.
+	cgroup, socket, _, _ := StartInitd(t)
+
+	// Start two sleeps so that only the signaled one is expected to exit.
+	dir := TempDir(t)
+	for _, name := range []string{"sleep1", "sleep2"} {
+		request := [][]string{
+			[]string{"START", name},
+			[]string{"/bin/sleep", "60"},
+			[]string{""},
+			[]string{},
+			[]string{path.Join(dir, "stdout-"+name), path.Join(dir, "stderr-"+name)},
+			[]string{"99", "99"},
+		}
+		reply, err := MakeRequest(socket, request, 10*time.Second)
+		TestExpectSuccess(t, err)
+		TestEqual(t, reply, "REQUEST OK\n")
+	}
+	Timeout(t, 5*time.Second, time.Second/100, func() bool {
+		tasks, err := cgroup.Tasks()
+		TestExpectSuccess(t, err)
+		return len(tasks) == 4
+	})
+
+	request := [][]string{[]string{"SIGNAL", "sleep1", "15"}}
+	reply, err := MakeRequest(socket, request, 10*time.Second)
+	TestExpectSuccess(t, err)
+	TestEqual(t, reply, "REQUEST OK\n")
+
+	Timeout(t, 5*time.Second, time.Second/100, func() bool {
+		reply, err := MakeRequest(socket, [][]string{[]string{"STATUS"}}, 10*time.Second)
+		TestExpectSuccess(t, err)
+		return strings.Contains(reply, "sleep1\nsignaled(15)\n") &&
+			strings.Contains(reply, "sleep2\nrunning\n")
+	})
+
+	// Signaling a process which has exited, or was never started, succeeds.
+	for _, name := range []string{"sleep1", "missing"} {
+		request := [][]string{[]string{"SIGNAL", name, "15"}}
+		reply, err := MakeRequest(socket, request, 10*time.Second)
+		TestExpectSuccess(t, err)
+		TestEqual(t, reply, "REQUEST OK\n")
+	}
+}
+
+func TestBadSignalRequest(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+	TestRequiresRoot(t)
+
+	tests := [][][]string{
+		// Test 1: The signal is missing.
+		[][]string{
+			[]string{"SIGNAL", "name"},
+		},
+
+		// Test 2: Extra cruft after the signal.
+		[][]string{
+			[]string{"SIGNAL", "name", "15", "EXTRA"},
+		},
+
+		// Test 3: The signal isn't a number.
+		[][]string{
+			[]string{"SIGNAL", "name", "TERM"},
+		},
+
+		// Test 4: The signal is out of range.
+		[][]string{
+			[]string{"SIGNAL", "name", "0"},
+		},
+
+		// Test 5: Request is too long.
+		[][]string{
+			[]string{"SIGNAL", "name", "15"},
+			[]string{},
+		},
+	}
+	BadResultsCheck(t, tests)
+}
@@ -25,7 +25,9 @@ void start_request(struct request *r)
 	pid_t pid;
 	uid_t uid;
 	gid_t gid;
+	char *root;
 	unsigned long int ul;
+	struct process *p;
 
 	// The expected protocol for an start statement looks like this:
 	// {
@@ -35,13 +37,18 @@ void start_request(struct request *r)
 	//   { ["<ENV=VALUE>", ...]},
 	//   { "<STDOUTFILE>", "<STDERRFILE>" },
 	//   { "<UID>", "<GID>" },
+	//   [{ "<ROOT>" }],
 	// }
+	//
+	// The optional ROOT is a directory the process will be pivoted into before
+	// being executed, allowing multiple apps within the pod to each have their
+	// own root filesystem.
 
 	INFO("[%d] START request.\n", r->fd);
 
 	// Protocol error conditions.
 	if (
-			(r->outer_len != 6) ||
+			(r->outer_len != 6 && r->outer_len != 7) ||
 			// START/NAME
 			(r->data[0][1] != NULL && r->data[0][2] != NULL) ||
 			// COMMAND
@@ -57,8 +64,10 @@ void start_request(struct request *r)
 			(r->data[5][0] == NULL) ||
 			(r->data[5][1] == NULL) ||
 			(r->data[5][2] != NULL) ||
+			// ROOT
+			(r->outer_len == 7 && (r->data[6][0] == NULL || r->data[6][1] != NULL)) ||
 			// END
-			(r->data[6] != NULL))
+			(r->data[r->outer_len] != NULL))
 	{
 		ERROR("[%d] Protocol error.\n", r->fd);
 		initd_response_protocol_error(r);
@@ -85,18 +94,23 @@ void start_request(struct request *r)
 		name_len = 0;
 	}
 
-	// Compute the uid and gid
-	uid = (uid_t)uidforuser2(r->data[5][0]);
-	if (uid < 0) {
-		ERROR("[%d] Error in locating UID\n", r->fd);
-		initd_response_internal_error(r);
-		return;
-	}
-	gid = (gid_t)gidforgroup2(r->data[5][1]);
-	if (gid < 0) {
-		ERROR("[%d] Error in locating GID\n", r->fd);
-		initd_response_internal_error(r);
-		return;
+	// Compute the uid and gid. When a root is given, the user and group are
+	// looked up after entering it so the app's own /etc/passwd and /etc/group
+	// are used.
+	root = r->outer_len == 7 ? r->data[6][0] : NULL;
+	if (root == NULL) {
+		uid = (uid_t)uidforuser2(r->data[5][0]);
+		if (uid < 0) {
+			ERROR("[%d] Error in locating UID\n", r->fd);
+			initd_response_internal_error(r);
+			return;
+		}
+		gid = (gid_t)gidforgroup2(r->data[5][1]);
+		if (gid < 0) {
+			ERROR("[%d] Error in locating GID\n", r->fd);
+			initd_response_internal_error(r);
+			return;
+		}
 	}
 
 	fflush(NULL);
@@ -111,6 +125,26 @@ void start_request(struct request *r)
 		close_all_fds();
 		initd_setup_fds(r->data[4][0], r->data[4][1]);
 
+		// Enter the app's root filesystem, if one was given.
+		if (root != NULL) {
+			if (enter_app_root(root) != 0) {
+				ERROR("[%d] Error entering root '%s': %s\n", r->fd, root, strerror(errno));
+				_exit(EX_OSERR);
+			}
+			uid = (uid_t)uidforuser2(r->data[5][0]);
+			gid = (gid_t)gidforgroup2(r->data[5][1]);
+			if (uid < 0 || gid < 0) {
+				ERROR("[%d] Error in locating UID/GID\n", r->fd);
+				_exit(EX_OSERR);
+			}
+		}
+
+		// Drop the capabilities the apps aren't permitted to have.
+		if (drop_capabilities(getenv("INITD_DROP_CAPABILITIES")) != 0) {
+			ERROR("[%d] Error dropping capabilities: %s\n", r->fd, strerror(errno));
+			_exit(EX_OSERR);
+		}
+
 		// Ensure that we are fully root.
 		if (setregid(gid, gid) != 0) { _exit(EX_OSERR); }
 		if (getgid() != gid) { _exit(EX_OSERR); }
@@ -141,9 +175,31 @@ void start_request(struct request *r)
 		_exit(EX_OSERR);
 	}
 
-	// Add this process to the list of tracked processes if necessary.
+	// Add this process to the list of tracked processes if necessary. A
+	// terminated process with the same name, such as an app being restarted, is
+	// reused so that each restart doesn't leave another entry behind. It is
+	// moved to the head of the list to keep the most recent start first.
 	if (name_len > 0) {
-		if (initd_process_new(r->data[0][1], name_len, pid) == NULL) {
+		for (p = process_head; p != NULL; p = p->next) {
+			if (p->terminated && strcmp(p->name, r->data[0][1]) == 0) {
+				break;
+			}
+		}
+		if (p != NULL) {
+			p->pid = pid;
+			p->terminated = false;
+			p->status = 0;
+			if (p != process_head) {
+				p->prev->next = p->next;
+				if (p->next != NULL) {
+					p->next->prev = p->prev;
+				}
+				p->prev = NULL;
+				p->next = process_head;
+				process_head->prev = p;
+				process_head = p;
+			}
+		} else if (initd_process_new(r->data[0][1], name_len, pid) == NULL) {
 			ERROR("[%d] Error in calloc(): %s\n", r->fd, strerror(errno));
 			initd_response_internal_error(r);
 			return;
@@ -9,6 +9,7 @@ import (
 	"io/ioutil"
 	"os"
 	"path"
+	"strings"
 	"testing"
 	"time"
 
@@ -225,6 +226,42 @@ func TestNamedStartRequest(t *testing.T) {
 	TestEqual(t, len(dirs), 3, fmt.Sprintf("Found fds: %#v", dirs))
 }
 
+func TestRestartedStartRequest(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+	TestRequiresRoot(t)
+
+	// Start the initd process.
+	_, socket, _, _ := StartInitd(t)
+
+	// Start the same named process several times, each after the previous one
+	// has exited, as is done when an app is restarted.
+	dir := TempDir(t)
+	for i := 0; i < 3; i++ {
+		request := [][]string{
+			[]string{"START", "RESTARTED"},
+			[]string{"/bin/sh", "-c", fmt.Sprintf("exit %d", i)},
+			[]string{""},
+			[]string{},
+			[]string{path.Join(dir, "stdout"), path.Join(dir, "stderr")},
+			[]string{"99", "99"},
+		}
+		reply, err := MakeRequest(socket, request, 10*time.Second)
+		TestExpectSuccess(t, err)
+		TestEqual(t, reply, "REQUEST OK\n")
+
+		expected := fmt.Sprintf("REQUEST OK\nRESTARTED\nexited(%d)\nEND\n", i)
+		Timeout(t, 5*time.Second, time.Second/100, func() bool {
+			reply, err := MakeRequest(socket, [][]string{[]string{"STATUS"}}, 10*time.Second)
+			TestExpectSuccess(t, err)
+			if strings.Count(reply, "RESTARTED\n") != 1 {
+				Fatalf(t, "Expected the process to be listed once: %q", reply)
+			}
+			return reply == expected
+		})
+	}
+}
+
 func TestBadStartRequest(t *testing.T) {
 	StartTest(t)
 	defer FinishTest(t)
@@ -248,6 +285,7 @@ func TestBadStartRequest(t *testing.T) {
 			[]string{"ENVKEY=ENVVALUE"},
 			[]string{"STDOUT", "STDERR"},
 			[]string{"UID", "GID"},
+			[]string{"ROOT"},
 			[]string{},
 		},
 
@@ -340,6 +378,28 @@ func TestBadStartRequest(t *testing.T) {
 			[]string{"STDOUT", "STDERR"},
 			[]string{},
 		},
+
+		// Test 12: Missing ROOT
+		[][]string{
+			[]string{"START"},
+			[]string{"COMMAND"},
+			[]string{"DIR"},
+			[]string{"ENVKEY=ENVVALUE"},
+			[]string{"STDOUT", "STDERR"},
+			[]string{"UID", "GID"},
+			[]string{},
+		},
+
+		// Test 13: Extra cruft after ROOT
+		[][]string{
+			[]string{"START"},
+			[]string{"COMMAND"},
+			[]string{"DIR"},
+			[]string{"ENVKEY=ENVVALUE"},
+			[]string{"STDOUT", "STDERR"},
+			[]string{"UID", "GID"},
+			[]string{"ROOT", "EXTRA"},
+		},
 	}
 	BadResultsCheck(t, tests)
 }
@@ -296,6 +296,13 @@ func (c *Cgroup) MemoryUsed() (int64, error) {
 	return proc.ReadInt64(filepath.Join(cgroupsDir, "memory", c.name, "memory.usage_in_bytes"))
 }
 
+// MemoryLimit returns the number of bytes the processes in the cgroup are
+// limited to. When no limit has been set, this is the kernel's default, which
+// is effectively unlimited.
+func (c *Cgroup) MemoryLimit() (int64, error) {
+	return proc.ReadInt64(filepath.Join(cgroupsDir, "memory", c.name, memLimit))
+}
+
 // Returns the total number of bytes used in the container for disk.  Keys off
 // of the directory path to the container's root directory.  This runs as IM
 // (root) from outside the container itself.  Since the container root is an LVM
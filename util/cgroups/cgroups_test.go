@@ -1022,6 +1022,70 @@ func TestCgroup_MemoryUsed(t *testing.T) {
 	}
 }
 
+func TestCgroup_MemoryLimit(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+	TestRequiresRoot(t)
+
+	// ------------------
+	// Failure Conditions
+	// ------------------
+
+	// Test 1: File not found.
+	func() {
+		defer func(s string) { cgroupsDir = s }(cgroupsDir)
+		cgroupsDir = TempDir(t)
+		cgroup := Cgroup{name: "foo"}
+
+		if _, err := cgroup.MemoryLimit(); err == nil {
+			Fatalf(t, "Expected error not returned.")
+		}
+	}()
+
+	// Test 2: Not an integer.
+	func() {
+		defer func(s string) { cgroupsDir = s }(cgroupsDir)
+		cgroupsDir = TempDir(t)
+		cgroup := Cgroup{name: "tmp"}
+
+		// Make the directory.
+		fn := path.Join(cgroupsDir, "memory", "tmp")
+		if err := os.MkdirAll(fn, 0755); err != nil {
+			Fatalf(t, "Unexpected error: %s", err)
+		}
+
+		fn = path.Join(fn, memLimit)
+		contents := []byte("xyz\n")
+		if err := ioutil.WriteFile(fn, contents, 0644); err != nil {
+			Fatalf(t, "Unexpected error: %s", err)
+		}
+
+		if _, err := cgroup.MemoryLimit(); err == nil {
+			Fatalf(t, "Expected error not returned.")
+		}
+	}()
+
+	// -------
+	// Success
+	// -------
+
+	_, cgroup := MakeUniqueCgroup(t)
+	defer CleanupCgroup(t, cgroup)
+
+	limit := int64(1024 * 1024)
+	if err := cgroup.LimitMemory(limit); err != nil {
+		Fatalf(t, "Unexpected error: %s", err)
+	}
+
+	actuallimit, err := cgroup.MemoryLimit()
+	if err != nil {
+		Fatalf(t, "Unexpected error: %s", err)
+	}
+	if actuallimit != limit {
+		Fatalf(t, "Read value was not expected: %d", actuallimit)
+	}
+}
+
 func TestCgroup_New(t *testing.T) {
 	StartTest(t)
 	defer FinishTest(t)
@@ -0,0 +1,69 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+// +build linux,cgo
+
+package cgroups
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+const (
+	memOOMControl = "memory.oom_control"
+	eventControl  = "cgroup.event_control"
+)
+
+// NotifyOOM registers for notifications of the kernel's OOM killer being
+// invoked because the processes in the cgroup reached its memory limit. Each
+// value received on the returned channel is the number of times it was invoked
+// since the last. The channel is closed once the cgroup has been destroyed.
+func (c *Cgroup) NotifyOOM() (<-chan int, error) {
+	dir := filepath.Join(cgroupsDir, "memory", c.name)
+
+	oomControl, err := os.Open(filepath.Join(dir, memOOMControl))
+	if err != nil {
+		return nil, err
+	}
+
+	fd, _, errno := syscall.RawSyscall(syscall.SYS_EVENTFD2, 0, syscall.O_CLOEXEC, 0)
+	if errno != 0 {
+		oomControl.Close()
+		return nil, fmt.Errorf("failed to create eventfd: %v", errno)
+	}
+	eventfd := os.NewFile(fd, "eventfd")
+
+	// The registration is in the form of "<event_fd> <fd of memory.oom_control>".
+	registration := fmt.Sprintf("%d %d", eventfd.Fd(), oomControl.Fd())
+	fn := filepath.Join(dir, eventControl)
+	if err := ioutil.WriteFile(fn, []byte(registration), 0644); err != nil {
+		eventfd.Close()
+		oomControl.Close()
+		return nil, err
+	}
+
+	ch := make(chan int)
+	go func() {
+		defer close(ch)
+		defer oomControl.Close()
+		defer eventfd.Close()
+
+		buf := make([]byte, 8)
+		for {
+			if _, err := eventfd.Read(buf); err != nil {
+				return
+			}
+
+			// The eventfd is also signaled when the cgroup is removed.
+			if _, err := osLstat(dir); err != nil {
+				return
+			}
+			ch <- int(binary.LittleEndian.Uint64(buf))
+		}
+	}()
+	return ch, nil
+}
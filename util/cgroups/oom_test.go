@@ -0,0 +1,114 @@
+// Copyright 2015 Apcera Inc. All rights reserved.
+
+// +build linux,cgo
+
+package cgroups
+
+import (
+	"os"
+	"os/exec"
+	"path"
+	"testing"
+	"time"
+
+	. "github.com/apcera/util/testtool"
+)
+
+func TestCgroup_NotifyOOM(t *testing.T) {
+	StartTest(t)
+	defer FinishTest(t)
+	TestRequiresRoot(t)
+
+	// ------------------
+	// Failure Conditions
+	// ------------------
+
+	// Test 1: memory.oom_control doesn't exist.
+	func() {
+		defer func(s string) { cgroupsDir = s }(cgroupsDir)
+		cgroupsDir = TempDir(t)
+		cgroup := Cgroup{name: "foo"}
+
+		if _, err := cgroup.NotifyOOM(); err == nil {
+			Fatalf(t, "Expected error not returned.")
+		}
+	}()
+
+	// Test 2: cgroup.event_control isn't writable.
+	func() {
+		defer func(s string) { cgroupsDir = s }(cgroupsDir)
+		cgroupsDir = TempDir(t)
+		cgroup := Cgroup{name: "tmp"}
+
+		fn := path.Join(cgroupsDir, "memory", "tmp")
+		if err := os.MkdirAll(path.Join(fn, eventControl), 0755); err != nil {
+			Fatalf(t, "Unexpected error: %s", err)
+		}
+		if f, err := os.Create(path.Join(fn, memOOMControl)); err != nil {
+			Fatalf(t, "Unexpected error: %s", err)
+		} else {
+			f.Close()
+		}
+
+		if _, err := cgroup.NotifyOOM(); err == nil {
+			Fatalf(t, "Expected error not returned.")
+		}
+	}()
+
+	// -------
+	// Success
+	// -------
+
+	_, cgroup := MakeUniqueCgroup(t)
+
+	if err := cgroup.LimitMemory(4 * 1024 * 1024); err != nil {
+		Fatalf(t, "Unexpected error: %s", err)
+	}
+	ch, err := cgroup.NotifyOOM()
+	if err != nil {
+		Fatalf(t, "Unexpected error: %s", err)
+	}
+
+	// Start a process which will consume far more memory than the limit once it
+	// is within the cgroup. tail buffers the entire line, and there is none.
+	cmd := exec.Command("/bin/sh", "-c", "read x; head -c 67108864 /dev/zero | tail")
+	writer, err := cmd.StdinPipe()
+	if err != nil {
+		Fatalf(t, "Unexpected error: %s", err)
+	}
+	if err := cmd.Start(); err != nil {
+		Fatalf(t, "Error starting command: %s", err)
+	}
+	if err := cgroup.AddTask(cmd.Process.Pid); err != nil {
+		Fatalf(t, "Unexpected error: %s", err)
+	}
+	writer.Write([]byte("\n"))
+	writer.Close()
+
+	select {
+	case n, ok := <-ch:
+		if !ok {
+			Fatalf(t, "Channel was closed before an OOM was reported.")
+		}
+		if n < 1 {
+			Fatalf(t, "Unexpected OOM count: %d", n)
+		}
+	case <-time.After(10 * time.Second):
+		Fatalf(t, "Timed out waiting for an OOM notification.")
+	}
+	cmd.Wait()
+
+	// Destroying the cgroup should close the channel.
+	CleanupCgroup(t, cgroup)
+	timeout := time.After(10 * time.Second)
+	for {
+		select {
+		case _, ok := <-ch:
+			if !ok {
+				return
+			}
+		case <-timeout:
+			Fatalf(t, "Timed out waiting for the channel to be closed.")
+		}
+	}
+}